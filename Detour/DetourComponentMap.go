@@ -0,0 +1,388 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+/// Options for #DtComponentMap::Init.
+type DtComponentOptions int
+
+const (
+	/// Join the polygons linked by off-mesh connections which can be
+	/// traversed both ways. Without it the connections are left out.
+	DT_COMPONENTS_OFFMESH DtComponentOptions = 0x01
+
+	/// Also join the polygons linked by one-way off-mesh connections, so that
+	/// the polygons of a component are reachable in at least one direction.
+	/// Implies #DT_COMPONENTS_OFFMESH.
+	DT_COMPONENTS_ONEWAY DtComponentOptions = 0x02
+)
+
+/// The component labels of the polygons of one tile.
+type dtComponentTile struct {
+	salt   uint32      ///< Salt of the tile when it was labeled.
+	labels []DtPolyRef ///< Component of each polygon, zero for none.
+}
+
+/// Labels the polygons of a navigation mesh with the connected component, or
+/// island, they belong to, so that reachability can be tested without a
+/// search, for example to avoid spawning items where the player cannot go.
+///
+/// Two polygons are in the same component when a path following the links of
+/// the mesh and passing the filter of the map leads from one to the other.
+/// Polygons excluded by the filter are in no component.
+///
+/// The map follows the tile changes of the mesh through a #DtTileListener:
+/// adding a tile only joins its polygons to the components around it, and
+/// removing one only labels again the components it was part of. Like the
+/// mesh itself, the map can be read from several goroutines as long as no
+/// tile changes at the same time.
+/// @ingroup detour
+type DtComponentMap struct {
+	m_nav     *DtNavMesh
+	m_filter  DtQueryFilterI
+	m_options DtComponentOptions
+	m_tiles   []dtComponentTile         ///< Labels, by tile index.
+	m_members map[DtPolyRef][]DtPolyRef ///< Polygons of each component.
+}
+
+/// Allocates a component map object using the Detour allocator.
+/// @return A component map that is ready to be initialized, or null on failure.
+/// @ingroup detour
+func DtAllocComponentMap() *DtComponentMap {
+	return &DtComponentMap{}
+}
+
+/// Frees the specified component map object, which stops following the tile
+/// changes of its navigation mesh.
+///  @param[in]		components	A component map allocated using #DtAllocComponentMap
+/// @ingroup detour
+func DtFreeComponentMap(components *DtComponentMap) {
+	if components == nil || components.m_nav == nil {
+		return
+	}
+	components.m_nav.RemoveTileListener(components)
+	components.m_nav = nil
+	components.m_tiles = nil
+	components.m_members = nil
+}
+
+/// Labels the polygons of a navigation mesh, and keeps the labels up to date
+/// with its tile changes from now on.
+///  @param[in]		nav			The navigation mesh.
+///  @param[in]		filter		The polygon filter deciding which polygons can be
+///  							walked on.
+///  @param[in]		options		Component options. (see: #DtComponentOptions)
+/// @returns The status flags for the operation.
+/// @par
+///
+/// The filter is kept by the map, so it must not change while the map is in
+/// use. The map can be initialized again, for another mesh or filter.
+func (this *DtComponentMap) Init(nav *DtNavMesh, filter DtQueryFilterI, options DtComponentOptions) DtStatus {
+	if nav == nil || filter == nil {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	if (options & DT_COMPONENTS_ONEWAY) != 0 {
+		options |= DT_COMPONENTS_OFFMESH
+	}
+	if this.m_nav != nil {
+		this.m_nav.RemoveTileListener(this)
+	}
+	this.m_nav = nav
+	this.m_filter = filter
+	this.m_options = options
+	this.m_tiles = make([]dtComponentTile, nav.GetMaxTiles())
+	this.m_members = make(map[DtPolyRef][]DtPolyRef)
+
+	// Label every polygon first, so that each link is only followed once.
+	for i := 0; i < int(nav.GetMaxTiles()); i++ {
+		if tile := nav.GetTile(i); tile.Header != nil {
+			this.labelTile(tile)
+		}
+	}
+	for i := 0; i < int(nav.GetMaxTiles()); i++ {
+		if tile := nav.GetTile(i); tile.Header != nil {
+			this.joinTile(tile)
+		}
+	}
+	nav.AddTileListener(this)
+	return DT_SUCCESS
+}
+
+/// Returns the component of a polygon.
+///  @param[in]		ref		The reference id of the polygon.
+/// @return The reference id of a polygon identifying the component, or zero
+/// if the polygon is not valid or is excluded by the filter.
+/// @par
+///
+/// The identifier of a component is one of its polygons, and may change
+/// with any tile change.
+func (this *DtComponentMap) GetComponent(ref DtPolyRef) DtPolyRef {
+	if this.m_nav == nil || ref == 0 {
+		return 0
+	}
+	var salt, it, ip uint32
+	this.m_nav.DecodePolyId(ref, &salt, &it, &ip)
+	if it >= uint32(len(this.m_tiles)) {
+		return 0
+	}
+	t := &this.m_tiles[it]
+	if t.salt != salt || ip >= uint32(len(t.labels)) {
+		return 0
+	}
+	return t.labels[ip]
+}
+
+/// Returns true if a path leads from one polygon to the other. With
+/// #DT_COMPONENTS_ONEWAY the path may lead the other way round only.
+///  @param[in]		a		The reference id of the first polygon.
+///  @param[in]		b		The reference id of the second polygon.
+/// @return True if both polygons are in the same component.
+func (this *DtComponentMap) IsReachable(a, b DtPolyRef) bool {
+	component := this.GetComponent(a)
+	return component != 0 && component == this.GetComponent(b)
+}
+
+/// The number of components.
+func (this *DtComponentMap) GetComponentCount() int { return len(this.m_members) }
+
+/// Returns the number of polygons of a component, zero if @p component is
+/// not a component identifier.
+func (this *DtComponentMap) GetComponentPolyCount(component DtPolyRef) int {
+	return len(this.m_members[component])
+}
+
+/// The options of the map.
+func (this *DtComponentMap) GetOptions() DtComponentOptions { return this.m_options }
+
+/// TileAdded implements #DtTileListener.
+func (this *DtComponentMap) TileAdded(nav *DtNavMesh, ref DtTileRef, tile *DtMeshTile) {
+	this.labelTile(tile)
+	this.joinTile(tile)
+	if (this.m_options & DT_COMPONENTS_ONEWAY) == 0 {
+		return
+	}
+
+	// The one-way links into the tile are only found from the tiles they
+	// start from, which are the neighbours of the tile, or the tiles of the
+	// runtime off-mesh connections.
+	const MAX_NEIS int = 32
+	var neis [MAX_NEIS]*DtMeshTile
+	nneis := nav.GetTilesAt(tile.Header.X, tile.Header.Y, neis[:], MAX_NEIS)
+	for side := 0; side < 8; side++ {
+		nneis += nav.GetNeighbourTilesAt(tile.Header.X, tile.Header.Y, side, neis[nneis:], MAX_NEIS-nneis)
+	}
+	from := append(neis[:nneis:nneis], nav.m_offMeshConTiles...)
+	it := nav.DecodePolyIdTile(nav.GetPolyRefBase(tile))
+	for _, nei := range from {
+		if nei == tile {
+			continue
+		}
+		base := nav.GetPolyRefBase(nei)
+		for i := 0; i < int(nei.Header.PolyCount); i++ {
+			poly := &nei.Polys[i]
+			for j := poly.FirstLink; j != DT_NULL_LINK; j = nei.Links[j].Next {
+				if target := nei.Links[j].Ref; target != 0 && nav.DecodePolyIdTile(target) == it {
+					this.join(base|DtPolyRef(i), target)
+				}
+			}
+		}
+	}
+}
+
+/// TileRemoved implements #DtTileListener.
+func (this *DtComponentMap) TileRemoved(nav *DtNavMesh, ref DtTileRef, tile *DtMeshTile) {
+	it := nav.DecodePolyIdTile(nav.GetPolyRefBase(tile))
+	t := &this.m_tiles[it]
+
+	// The components of the tile may be split, take them apart.
+	var polys []DtPolyRef
+	for _, component := range t.labels {
+		if members, ok := this.m_members[component]; ok {
+			for _, member := range members {
+				if nav.DecodePolyIdTile(member) != it {
+					polys = append(polys, member)
+				}
+			}
+			delete(this.m_members, component)
+		}
+	}
+	t.labels = nil
+
+	// Join what remains again. The links to the tile are already removed.
+	for _, member := range polys {
+		this.setComponent(member, member)
+		this.m_members[member] = []DtPolyRef{member}
+	}
+	for _, member := range polys {
+		var memberTile *DtMeshTile
+		var memberPoly *DtPoly
+		nav.GetTileAndPolyByRefUnsafe(member, &memberTile, &memberPoly)
+		for i := memberPoly.FirstLink; i != DT_NULL_LINK; i = memberTile.Links[i].Next {
+			this.join(member, memberTile.Links[i].Ref)
+		}
+	}
+}
+
+/// Puts every polygon of a tile in a component of its own, or in none.
+func (this *DtComponentMap) labelTile(tile *DtMeshTile) {
+	base := this.m_nav.GetPolyRefBase(tile)
+	t := &this.m_tiles[this.m_nav.DecodePolyIdTile(base)]
+	t.salt = this.m_nav.DecodePolyIdSalt(base)
+	t.labels = make([]DtPolyRef, tile.Header.PolyCount)
+	for i := range t.labels {
+		ref := base | DtPolyRef(i)
+		poly := &tile.Polys[i]
+		if poly.GetType() == DT_POLYTYPE_OFFMESH_CONNECTION && (this.m_options&DT_COMPONENTS_OFFMESH) == 0 {
+			continue
+		}
+		if !this.m_filter.PassFilter(ref, tile, poly) {
+			continue
+		}
+		t.labels[i] = ref
+		this.m_members[ref] = []DtPolyRef{ref}
+	}
+}
+
+/// Joins the components of the polygons of a tile and of the polygons they
+/// link to.
+func (this *DtComponentMap) joinTile(tile *DtMeshTile) {
+	base := this.m_nav.GetPolyRefBase(tile)
+	for i := 0; i < int(tile.Header.PolyCount); i++ {
+		poly := &tile.Polys[i]
+		for j := poly.FirstLink; j != DT_NULL_LINK; j = tile.Links[j].Next {
+			this.join(base|DtPolyRef(i), tile.Links[j].Ref)
+		}
+	}
+}
+
+/// Joins the components of two polygons, the first one linking to the
+/// second, unless the link only goes one way and the options leave such
+/// links out.
+func (this *DtComponentMap) join(from, to DtPolyRef) {
+	a := this.GetComponent(from)
+	b := this.GetComponent(to)
+	if a == 0 || b == 0 || a == b {
+		return
+	}
+	if (this.m_options&DT_COMPONENTS_ONEWAY) == 0 && !this.linksTo(to, from) {
+		return
+	}
+
+	// Label the polygons of the smaller component, so that each polygon is
+	// labeled again at most log2(n) times.
+	if len(this.m_members[a]) < len(this.m_members[b]) {
+		a, b = b, a
+	}
+	for _, member := range this.m_members[b] {
+		this.setComponent(member, a)
+	}
+	this.m_members[a] = append(this.m_members[a], this.m_members[b]...)
+	delete(this.m_members, b)
+}
+
+/// Returns true if a polygon has a link to another.
+func (this *DtComponentMap) linksTo(from, to DtPolyRef) bool {
+	var tile *DtMeshTile
+	var poly *DtPoly
+	this.m_nav.GetTileAndPolyByRefUnsafe(from, &tile, &poly)
+	for i := poly.FirstLink; i != DT_NULL_LINK; i = tile.Links[i].Next {
+		if tile.Links[i].Ref == to {
+			return true
+		}
+	}
+	return false
+}
+
+/// Sets the component of a labeled polygon.
+func (this *DtComponentMap) setComponent(ref, component DtPolyRef) {
+	var salt, it, ip uint32
+	this.m_nav.DecodePolyId(ref, &salt, &it, &ip)
+	this.m_tiles[it].labels[ip] = component
+}
+
+/// Returns random location in a component of the navigation mesh.
+/// Polygons are chosen weighted by area. The search runs in linear related to
+/// the number of polygons of the component.
+///  @param[in]		components		The component map.
+///  @param[in]		component		The component, as returned by #DtComponentMap::GetComponent.
+///  @param[in]		filter			The polygon filter to apply to the query.
+///  @param[in]		frand			Function returning a random number [0..1).
+///  @param[out]	randomRef		The reference id of the random location.
+///  @param[out]	randomPt		The random location. [(x, y, z)]
+/// @returns The status flags for the query.
+/// @par
+///
+/// This is #FindRandomPoint constrained to a component, for example to spawn
+/// items only where the player can go:
+/// @code
+/// status := query.FindRandomPointInComponent(components,
+/// 	components.GetComponent(playerRef), filter, frand, &ref, pt[:])
+/// @endcode
+///
+/// Unlike #FindRandomPoint, the polygons are weighted by area over the whole
+/// component rather than within a random tile.
+func (this *DtNavMeshQuery) FindRandomPointInComponent(components *DtComponentMap, component DtPolyRef,
+	filter DtQueryFilterI, frand func() float32, randomRef *DtPolyRef, randomPt []float32) DtStatus {
+	DtAssert(this.m_nav != nil)
+
+	// Validate input
+	if components == nil || components.m_nav != this.m_nav || filter == nil || frand == nil ||
+		randomRef == nil || randomPt == nil {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	members, ok := components.m_members[component]
+	if !ok {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+
+	// Randomly pick one polygon weighted by polygon area.
+	var tile *DtMeshTile
+	var poly *DtPoly
+	var polyRef DtPolyRef
+	var areaSum float32
+	for _, ref := range members {
+		var t *DtMeshTile
+		var p *DtPoly
+		this.m_nav.GetTileAndPolyByRefUnsafe(ref, &t, &p)
+		// Do not return off-mesh connection polygons.
+		if p.GetType() != DT_POLYTYPE_GROUND || !filter.PassFilter(ref, t, p) {
+			continue
+		}
+		// Calc area of the polygon.
+		var polyArea float32
+		for j := 2; j < int(p.VertCount); j++ {
+			va := t.Verts[p.Verts[0]*3:]
+			vb := t.Verts[p.Verts[j-1]*3:]
+			vc := t.Verts[p.Verts[j]*3:]
+			polyArea += DtTriArea2D(va, vb, vc)
+		}
+
+		// Choose random polygon weighted by area, using reservoi sampling.
+		areaSum += polyArea
+		u := frand()
+		if u*areaSum <= polyArea {
+			tile = t
+			poly = p
+			polyRef = ref
+		}
+	}
+	if poly == nil {
+		return DT_FAILURE
+	}
+	return this.randomPointInPoly(polyRef, tile, poly, frand, randomRef, randomPt)
+}
@@ -0,0 +1,220 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+/// Flags of the polygons of a cost overlay.
+/// @see DtCostOverlay
+type DtOverlayFlags uint8
+
+const (
+	DT_OVERLAY_BLOCKED DtOverlayFlags = 0x01 ///< The polygon cannot be visited.
+)
+
+type dtOverlayEntry struct {
+	cost  float32
+	flags DtOverlayFlags
+}
+
+/// Per-polygon cost multipliers and blocked polygons, kept apart from the
+/// navigation mesh so a mesh shared by several map instances or teams can be
+/// seen differently by each of them.
+/// @par
+///
+/// The overlay is consulted by queries through a #DtOverlayFilter. Polygons
+/// are keyed by reference, so the entries of a tile no longer apply once the
+/// tile is removed or replaced; see #Prune.
+///
+/// Like the navigation mesh, an overlay may be read by several queries at
+/// once, but must not be changed while a query using it runs.
+/// @see DtAllocCostOverlay, DtOverlayFilter
+type DtCostOverlay struct {
+	m_entries map[DtPolyRef]dtOverlayEntry
+}
+
+/// Allocates a cost overlay object using the Detour allocator.
+/// @return An allocated cost overlay, or null on failure.
+/// @ingroup detour
+func DtAllocCostOverlay() *DtCostOverlay {
+	return &DtCostOverlay{m_entries: make(map[DtPolyRef]dtOverlayEntry)}
+}
+
+/// Frees the specified cost overlay object using the Detour allocator.
+///  @param[in]		overlay		A cost overlay allocated using #DtAllocCostOverlay
+/// @ingroup detour
+func DtFreeCostOverlay(overlay *DtCostOverlay) {
+	if overlay == nil {
+		return
+	}
+	overlay.m_entries = nil
+}
+
+/// Sets the cost multiplier of a polygon.
+///  @param[in]		ref		The reference id of the polygon.
+///  @param[in]		cost	The multiplier of the cost of moving through the polygon. [Limit: > 0]
+/// @return The status flags for the operation.
+/// @par
+///
+/// #FindPath estimates the remaining cost by distance, so multipliers below 1
+/// may make it miss the cheapest path, like area costs below 1 do.
+func (this *DtCostOverlay) SetPolyCost(ref DtPolyRef, cost float32) DtStatus {
+	if ref == 0 || !(cost > 0) {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	entry := this.m_entries[ref]
+	entry.cost = cost
+	this.set(ref, entry)
+	return DT_SUCCESS
+}
+
+/// Gets the cost multiplier of a polygon.
+///  @param[in]		ref		The reference id of the polygon.
+/// @return The multiplier, 1 for polygons without one.
+func (this *DtCostOverlay) GetPolyCost(ref DtPolyRef) float32 {
+	if entry, ok := this.m_entries[ref]; ok {
+		return entry.cost
+	}
+	return 1
+}
+
+/// Sets the overlay flags of a polygon.
+///  @param[in]		ref		The reference id of the polygon.
+///  @param[in]		flags	The new flags. (See: #DtOverlayFlags)
+/// @return The status flags for the operation.
+func (this *DtCostOverlay) SetPolyFlags(ref DtPolyRef, flags DtOverlayFlags) DtStatus {
+	if ref == 0 {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	entry, ok := this.m_entries[ref]
+	if !ok {
+		entry.cost = 1
+	}
+	entry.flags = flags
+	this.set(ref, entry)
+	return DT_SUCCESS
+}
+
+/// Gets the overlay flags of a polygon.
+///  @param[in]		ref		The reference id of the polygon.
+/// @return The flags, 0 for polygons without any.
+func (this *DtCostOverlay) GetPolyFlags(ref DtPolyRef) DtOverlayFlags {
+	return this.m_entries[ref].flags
+}
+
+/// Blocks or unblocks a polygon, leaving its cost multiplier unchanged.
+///  @param[in]		ref		The reference id of the polygon.
+///  @param[in]		blocked	True to block the polygon.
+/// @return The status flags for the operation.
+func (this *DtCostOverlay) SetPolyBlocked(ref DtPolyRef, blocked bool) DtStatus {
+	flags := this.GetPolyFlags(ref) &^ DT_OVERLAY_BLOCKED
+	if blocked {
+		flags |= DT_OVERLAY_BLOCKED
+	}
+	return this.SetPolyFlags(ref, flags)
+}
+
+/// Returns true if the polygon is blocked.
+///  @param[in]		ref		The reference id of the polygon.
+func (this *DtCostOverlay) IsPolyBlocked(ref DtPolyRef) bool {
+	return this.GetPolyFlags(ref)&DT_OVERLAY_BLOCKED != 0
+}
+
+/// Removes the cost multiplier and flags of a polygon.
+///  @param[in]		ref		The reference id of the polygon.
+func (this *DtCostOverlay) ResetPoly(ref DtPolyRef) {
+	delete(this.m_entries, ref)
+}
+
+/// Removes every entry of the overlay.
+func (this *DtCostOverlay) Clear() {
+	this.m_entries = make(map[DtPolyRef]dtOverlayEntry)
+}
+
+/// Gets the number of polygons with a cost multiplier or flags.
+func (this *DtCostOverlay) GetPolyCount() int {
+	return len(this.m_entries)
+}
+
+/// Removes the entries of polygons which are no longer valid in a navigation
+/// mesh, such as those of removed or rebuilt tiles.
+///  @param[in]		nav		The navigation mesh.
+/// @return The number of entries removed.
+func (this *DtCostOverlay) Prune(nav *DtNavMesh) int {
+	n := 0
+	for ref := range this.m_entries {
+		if !nav.IsValidPolyRef(ref) {
+			delete(this.m_entries, ref)
+			n++
+		}
+	}
+	return n
+}
+
+// set stores an entry, dropping it when it changes nothing.
+func (this *DtCostOverlay) set(ref DtPolyRef, entry dtOverlayEntry) {
+	if entry.cost == 1 && entry.flags == 0 {
+		delete(this.m_entries, ref)
+		return
+	}
+	this.m_entries[ref] = entry
+}
+
+/// A query filter applying a cost overlay on top of another filter.
+/// @par
+///
+/// Polygons blocked by the overlay do not pass the filter, and the cost of
+/// moving through a polygon is the cost given by the wrapped filter times the
+/// multiplier of the polygon. Overlay filters can be stacked, for example a
+/// team overlay over a map instance overlay.
+/// @see DtCostOverlay
+type DtOverlayFilter struct {
+	m_filter  DtQueryFilterI ///< The wrapped filter.
+	m_overlay *DtCostOverlay ///< The overlay applied.
+}
+
+/// Allocates an overlay filter.
+///  @param[in]		filter		The filter to wrap.
+///  @param[in]		overlay		The overlay to apply.
+/// @return An allocated filter.
+/// @ingroup detour
+func DtAllocOverlayFilter(filter DtQueryFilterI, overlay *DtCostOverlay) *DtOverlayFilter {
+	DtAssert(filter != nil)
+	DtAssert(overlay != nil)
+	return &DtOverlayFilter{m_filter: filter, m_overlay: overlay}
+}
+
+/// Gets the wrapped filter.
+func (this *DtOverlayFilter) GetFilter() DtQueryFilterI { return this.m_filter }
+
+/// Gets the overlay applied.
+func (this *DtOverlayFilter) GetOverlay() *DtCostOverlay { return this.m_overlay }
+
+func (this *DtOverlayFilter) PassFilter(ref DtPolyRef, tile *DtMeshTile, poly *DtPoly) bool {
+	return !this.m_overlay.IsPolyBlocked(ref) && this.m_filter.PassFilter(ref, tile, poly)
+}
+
+func (this *DtOverlayFilter) GetCost(pa, pb []float32,
+	prevRef DtPolyRef, prevTile *DtMeshTile, prevPoly *DtPoly,
+	curRef DtPolyRef, curTile *DtMeshTile, curPoly *DtPoly,
+	nextRef DtPolyRef, nextTile *DtMeshTile, nextPoly *DtPoly) float32 {
+	cost := this.m_filter.GetCost(pa, pb,
+		prevRef, prevTile, prevPoly,
+		curRef, curTile, curPoly,
+		nextRef, nextTile, nextPoly)
+	return cost * this.m_overlay.GetPolyCost(curRef)
+}
@@ -0,0 +1,457 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+/// Options for #DtNavMeshQuery::BuildDistanceField.
+type DtDistanceFieldOptions int
+
+const (
+	DT_DISTANCEFIELD_FROM_SOURCE DtDistanceFieldOptions = 0 ///< Costs of the paths from the source.
+	DT_DISTANCEFIELD_TO_SOURCE   DtDistanceFieldOptions = 1 ///< Costs of the paths to the source, following the links in reverse.
+)
+
+/// A polygon of a distance field.
+type dtFieldPoly struct {
+	cost   float32    ///< Cost between the source and the node position.
+	parent DtPolyRef  ///< Next polygon toward the source, zero for the source.
+	pos    [3]float32 ///< Node position, on the edge shared with the parent.
+}
+
+/// The costs between one source polygon and every polygon reached from it,
+/// built by #DtNavMeshQuery::BuildDistanceField.
+///
+/// A distance field answers path and direction queries toward or from the
+/// source for any number of agents, without a search per agent, for example
+/// to move a horde toward a single player.
+///
+/// The field does not change once built, so it can be read from several
+/// goroutines. It is not updated when tiles change: queries fail with
+/// #DT_INVALID_PARAM for the polygons of removed tiles, and the field must
+/// be built again to cover new ones.
+/// @ingroup detour
+type DtDistanceField struct {
+	m_nav       *DtNavMesh
+	m_source    DtPolyRef
+	m_sourcePos [3]float32
+	m_options   DtDistanceFieldOptions
+	m_polys     map[DtPolyRef]*dtFieldPoly
+}
+
+/// Allocates a distance field object using the Detour allocator.
+/// @return A distance field that is ready to be built, or null on failure.
+/// @ingroup detour
+func DtAllocDistanceField() *DtDistanceField {
+	return &DtDistanceField{}
+}
+
+/// Builds a distance field with a Dijkstra search from the source polygon.
+///  @param[in]		startRef	The reference id of the source polygon.
+///  @param[in]		startPos	A position within the source polygon. [(x, y, z)]
+///  @param[in]		maxCost		Polygons costing more than this are left out.
+///  							[Limit: > 0, FLT_MAX for no limit]
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[in]		options		The direction of the paths. (see: #DtDistanceFieldOptions)
+///  @param[out]	field		The distance field to build.
+/// @returns The status flags for the query.
+/// @par
+///
+/// Like #FindPolysAroundCircle without the radius, the search expands from
+/// the source until every reachable polygon is found, or @p maxCost is
+/// reached. The costs add up the filter costs between the node positions,
+/// like those of #FindPath, so they are approximate: a node is placed at the
+/// middle of the edge it is first reached through and is not moved
+/// afterwards. The cost from a position, as given by #GetCostAt, can differ
+/// by a few percent either way from that of the corridor #FindPath returns
+/// from there.
+///
+/// With #DT_DISTANCEFIELD_TO_SOURCE the costs are those of the paths from
+/// each polygon to the source, which is what agents moving to the source
+/// need when the costs or the off-mesh connections are not symmetric.
+///
+/// The search uses the node pool of the query, #DT_OUT_OF_NODES is set when
+/// it was too small to reach every polygon. The field keeps its own copy of
+/// the result, so the query can be used for other searches afterwards.
+func (this *DtNavMeshQuery) BuildDistanceField(startRef DtPolyRef, startPos []float32, maxCost float32,
+	filter DtQueryFilterI, options DtDistanceFieldOptions, field *DtDistanceField) DtStatus {
+	DtAssert(this.m_nav != nil)
+	DtAssert(this.m_nodePool != nil)
+	DtAssert(this.m_openList != nil)
+
+	// Validate input
+	if field == nil || !this.m_nav.IsValidPolyRef(startRef) || startPos == nil || filter == nil || !(maxCost > 0) ||
+		(options != DT_DISTANCEFIELD_FROM_SOURCE && options != DT_DISTANCEFIELD_TO_SOURCE) {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	toSource := options == DT_DISTANCEFIELD_TO_SOURCE
+
+	this.m_nodePool.Clear()
+	this.m_openList.Clear()
+
+	startNode := this.m_nodePool.GetNode(startRef, 0)
+	DtVcopy(startNode.Pos[:], startPos)
+	startNode.Pidx = 0
+	startNode.Cost = 0
+	startNode.Total = 0
+	startNode.Id = startRef
+	startNode.Flags = DT_NODE_OPEN
+	this.m_openList.Push(startNode)
+
+	status := DT_SUCCESS
+
+	var (
+		bestNode             *DtNode
+		bestRef, parentRef   DtPolyRef
+		bestTile, parentTile *DtMeshTile
+		bestPoly, parentPoly *DtPoly
+	)
+
+	// Relaxes the edge between bestNode and a neighbour polygon, in the
+	// direction of the paths.
+	relax := func(neighbourRef DtPolyRef, crossSide uint8) {
+		// Skip invalid neighbours and do not follow back to parent.
+		if neighbourRef == 0 || neighbourRef == parentRef {
+			return
+		}
+		// Expand to neighbour
+		var neighbourTile *DtMeshTile
+		var neighbourPoly *DtPoly
+		this.m_nav.GetTileAndPolyByRefUnsafe(neighbourRef, &neighbourTile, &neighbourPoly)
+
+		// Do not advance if the polygon is excluded by the filter.
+		if !filter.PassFilter(neighbourRef, neighbourTile, neighbourPoly) {
+			return
+		}
+		neighbourNode := this.m_nodePool.GetNode(neighbourRef, crossSide)
+		if neighbourNode == nil {
+			status |= DT_OUT_OF_NODES
+			return
+		}
+
+		// If the node is visited the first time, calculate node position.
+		var cost float32
+		if toSource {
+			if neighbourNode.Flags == 0 {
+				this.getEdgeMidPoint2(neighbourRef, neighbourPoly, neighbourTile,
+					bestRef, bestPoly, bestTile,
+					neighbourNode.Pos[:])
+			}
+			cost = filter.GetCost(neighbourNode.Pos[:], bestNode.Pos[:],
+				neighbourRef, neighbourTile, neighbourPoly,
+				bestRef, bestTile, bestPoly,
+				parentRef, parentTile, parentPoly)
+		} else {
+			if neighbourNode.Flags == 0 {
+				this.getEdgeMidPoint2(bestRef, bestPoly, bestTile,
+					neighbourRef, neighbourPoly, neighbourTile,
+					neighbourNode.Pos[:])
+			}
+			cost = filter.GetCost(bestNode.Pos[:], neighbourNode.Pos[:],
+				parentRef, parentTile, parentPoly,
+				bestRef, bestTile, bestPoly,
+				neighbourRef, neighbourTile, neighbourPoly)
+		}
+
+		total := bestNode.Total + cost
+		if total > maxCost {
+			return
+		}
+		// The node is already visited, and the new result is worse, skip.
+		if (neighbourNode.Flags&(DT_NODE_OPEN|DT_NODE_CLOSED)) != 0 && total >= neighbourNode.Total {
+			return
+		}
+		neighbourNode.Id = neighbourRef
+		neighbourNode.Pidx = this.m_nodePool.GetNodeIdx(bestNode)
+		neighbourNode.Flags = (neighbourNode.Flags & ^DT_NODE_CLOSED)
+		neighbourNode.Cost = total
+		neighbourNode.Total = total
+
+		if (neighbourNode.Flags & DT_NODE_OPEN) != 0 {
+			this.m_openList.Modify(neighbourNode)
+		} else {
+			neighbourNode.Flags |= DT_NODE_OPEN
+			this.m_openList.Push(neighbourNode)
+		}
+	}
+
+	for !this.m_openList.Empty() {
+		bestNode = this.m_openList.Pop()
+		bestNode.Flags &= ^DT_NODE_OPEN
+		bestNode.Flags |= DT_NODE_CLOSED
+
+		// Get poly and tile.
+		// The API input has been cheked already, skip checking internal data.
+		bestRef = bestNode.Id
+		this.m_nav.GetTileAndPolyByRefUnsafe(bestRef, &bestTile, &bestPoly)
+
+		// Get parent poly and tile.
+		parentRef = 0
+		parentTile = nil
+		parentPoly = nil
+		if bestNode.Pidx != 0 {
+			parentRef = this.m_nodePool.GetNodeAtIdx(bestNode.Pidx).Id
+		}
+		if parentRef != 0 {
+			this.m_nav.GetTileAndPolyByRefUnsafe(parentRef, &parentTile, &parentPoly)
+		}
+
+		if toSource {
+			this.forEachLinkInto(bestRef, bestTile, bestPoly, relax)
+			continue
+		}
+		for i := bestPoly.FirstLink; i != DT_NULL_LINK; i = bestTile.Links[i].Next {
+			link := &bestTile.Links[i]
+			// deal explicitly with crossing tile boundaries
+			var crossSide uint8
+			if link.Side != 0xff {
+				crossSide = link.Side >> 1
+			}
+			relax(link.Ref, crossSide)
+		}
+	}
+
+	// Keep the cheapest node of every polygon. The parent of a node is
+	// cheaper than the node, so following the parents always leads to the
+	// source.
+	field.m_nav = this.m_nav
+	field.m_source = startRef
+	DtVcopy(field.m_sourcePos[:], startPos)
+	field.m_options = options
+	field.m_polys = make(map[DtPolyRef]*dtFieldPoly, this.m_nodePool.GetNodeCount())
+	for i := uint32(1); i <= this.m_nodePool.GetNodeCount(); i++ {
+		node := this.m_nodePool.GetNodeAtIdx(i)
+		if (node.Flags & DT_NODE_CLOSED) == 0 {
+			continue
+		}
+		if poly, ok := field.m_polys[node.Id]; ok && poly.cost <= node.Cost {
+			continue
+		}
+		poly := &dtFieldPoly{cost: node.Cost}
+		if parent := this.m_nodePool.GetNodeAtIdx(node.Pidx); parent != nil {
+			poly.parent = parent.Id
+		}
+		DtVcopy(poly.pos[:], node.Pos[:])
+		field.m_polys[node.Id] = poly
+	}
+
+	return status
+}
+
+/// The reference id of the source polygon.
+func (this *DtDistanceField) GetSource() DtPolyRef { return this.m_source }
+
+/// The position the field was built from. [(x, y, z)]
+func (this *DtDistanceField) GetSourcePos() []float32 { return this.m_sourcePos[:] }
+
+/// The direction of the paths of the field.
+func (this *DtDistanceField) GetOptions() DtDistanceFieldOptions { return this.m_options }
+
+/// The number of polygons reached from the source.
+func (this *DtDistanceField) GetPolyCount() int { return len(this.m_polys) }
+
+/// Gets the cost between the source and a polygon.
+///  @param[in]		ref		The reference id of the polygon.
+///  @param[out]	cost	The cost between the source position and the
+///  						position where the path enters or leaves @p ref.
+/// @returns The status flags for the query.
+func (this *DtDistanceField) GetCost(ref DtPolyRef, cost *float32) DtStatus {
+	poly := this.getPoly(ref)
+	if poly == nil || cost == nil {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	*cost = poly.cost
+	return DT_SUCCESS
+}
+
+/// Gets the cost between the source position and a position in a polygon.
+///  @param[in]		ref		The reference id of the polygon.
+///  @param[in]		pos		A position within the polygon. [(x, y, z)]
+///  @param[in]		filter	The filter the field was built with.
+///  @param[out]	cost	The cost between the source position and @p pos,
+///  						in the direction of the field.
+/// @returns The status flags for the query.
+func (this *DtDistanceField) GetCostAt(ref DtPolyRef, pos []float32, filter DtQueryFilterI, cost *float32) DtStatus {
+	poly := this.getPoly(ref)
+	if poly == nil || pos == nil || filter == nil || cost == nil {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	var tile *DtMeshTile
+	var p *DtPoly
+	this.m_nav.GetTileAndPolyByRefUnsafe(ref, &tile, &p)
+
+	// Within the source polygon, from the source position, otherwise from
+	// where the path enters or leaves the polygon.
+	from := poly.pos[:]
+	if ref == this.m_source {
+		from = this.m_sourcePos[:]
+	}
+	if this.m_options == DT_DISTANCEFIELD_TO_SOURCE {
+		*cost = poly.cost + filter.GetCost(pos, from, 0, nil, nil, ref, tile, p, 0, nil, nil)
+	} else {
+		*cost = poly.cost + filter.GetCost(from, pos, 0, nil, nil, ref, tile, p, 0, nil, nil)
+	}
+	return DT_SUCCESS
+}
+
+/// Gets the next polygon toward the source.
+///  @param[in]		ref		The reference id of the polygon.
+/// @returns The reference id of the parent polygon, zero for the source or
+/// for polygons which are not part of the field.
+func (this *DtDistanceField) GetParent(ref DtPolyRef) DtPolyRef {
+	if poly := this.getPoly(ref); poly != nil {
+		return poly.parent
+	}
+	return 0
+}
+
+/// Gets the path between the source and a polygon, in the direction of the
+/// field.
+///  @param[in]		ref			The reference id of the polygon.
+///  @param[out]	path		The polygons of the path. [(polyRef) * @p pathCount]
+///  @param[out]	pathCount	The number of polygons returned in the @p path array.
+///  @param[in]		maxPath		The maximum number of polygons the @p path array can hold. [Limit: >= 1]
+/// @returns The status flags for the query.
+/// @par
+///
+/// For #DT_DISTANCEFIELD_FROM_SOURCE the path leads from the source to @p ref,
+/// like #GetPathFromDijkstraSearch. For #DT_DISTANCEFIELD_TO_SOURCE it leads
+/// from @p ref to the source.
+///
+/// If the path array is too small, it is filled from @p ref toward the
+/// source for #DT_DISTANCEFIELD_TO_SOURCE, and from the source otherwise.
+func (this *DtDistanceField) GetPath(ref DtPolyRef, path []DtPolyRef, pathCount *int, maxPath int) DtStatus {
+	if path == nil || pathCount == nil || maxPath <= 0 || len(path) < maxPath {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	*pathCount = 0
+	if this.getPoly(ref) == nil {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+
+	// From ref to the source.
+	length := 0
+	for cur := ref; cur != 0; cur = this.m_polys[cur].parent {
+		if length < maxPath {
+			path[length] = cur
+		}
+		length++
+	}
+
+	n := length
+	status := DT_SUCCESS
+	if length > maxPath {
+		n = maxPath
+		status |= DT_BUFFER_TOO_SMALL
+	}
+	if this.m_options == DT_DISTANCEFIELD_FROM_SOURCE {
+		if length > maxPath {
+			// Keep the polygons nearest to the source.
+			cur := ref
+			for i := 0; i < length-maxPath; i++ {
+				cur = this.m_polys[cur].parent
+			}
+			for i := 0; i < n; i++ {
+				path[i] = cur
+				cur = this.m_polys[cur].parent
+			}
+		}
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			path[i], path[j] = path[j], path[i]
+		}
+	}
+	*pathCount = n
+	return status
+}
+
+/// Returns the polygon of the field, or nil when the polygon is not part of
+/// the field or no longer valid.
+func (this *DtDistanceField) getPoly(ref DtPolyRef) *dtFieldPoly {
+	if this.m_nav == nil || !this.m_nav.IsValidPolyRef(ref) {
+		return nil
+	}
+	return this.m_polys[ref]
+}
+
+/// Finds the direction to move in from a position, toward the source of a
+/// distance field.
+///  @param[in]		field		The distance field, built with #DT_DISTANCEFIELD_TO_SOURCE.
+///  @param[in]		ref			The reference id of the polygon containing @p pos.
+///  @param[in]		pos			The current position. [(x, y, z)]
+///  @param[in]		lookAhead	The maximum number of polygons toward the source the
+///  							direction accounts for. [Limit: >= 2]
+///  @param[out]	dir			The normalized direction, or zero at the source. [(x, y, z)]
+///  @param[out]	corner		The first corner of the path toward the source. [(x, y, z)] [opt]
+/// @returns The status flags for the query.
+/// @par
+///
+/// The direction points to the first corner of the straight path along the
+/// next @p lookAhead polygons toward the source, so agents following it do
+/// not cut the corners of the walls. The corner is the end of the last of
+/// these polygons' edges toward the source when the source is further away.
+func (this *DtNavMeshQuery) GetFlowDirection(field *DtDistanceField, ref DtPolyRef, pos []float32,
+	lookAhead int, dir, corner []float32) DtStatus {
+	DtAssert(this.m_nav != nil)
+	if field == nil || field.m_options != DT_DISTANCEFIELD_TO_SOURCE || field.m_nav != this.m_nav ||
+		pos == nil || dir == nil || lookAhead < 2 {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	DtVset(dir, 0, 0, 0)
+
+	const MAX_LOOK_AHEAD = 32
+	if lookAhead > MAX_LOOK_AHEAD {
+		lookAhead = MAX_LOOK_AHEAD
+	}
+	var path [MAX_LOOK_AHEAD]DtPolyRef
+	var count int
+	status := field.GetPath(ref, path[:], &count, lookAhead)
+	if DtStatusFailed(status) {
+		return status
+	}
+
+	// Head for the source, or for the entry of the last polygon.
+	var endPos [3]float32
+	last := path[count-1]
+	if last == field.m_source {
+		DtVcopy(endPos[:], field.m_sourcePos[:])
+	} else {
+		DtVcopy(endPos[:], field.m_polys[last].pos[:])
+	}
+
+	var points [2 * 3]float32
+	var n int
+	status = this.FindStraightPath(pos, endPos[:], path[:], count, points[:], nil, nil, &n, 2, 0)
+	if DtStatusFailed(status) {
+		return status
+	}
+	if n < 2 {
+		if corner != nil {
+			DtVcopy(corner, endPos[:])
+		}
+		return DT_SUCCESS
+	}
+	if corner != nil {
+		DtVcopy(corner, points[3:])
+	}
+	DtVsub(dir, points[3:], points[:])
+	if d := DtVlen(dir); d > 1e-6 {
+		DtVscale(dir, dir, 1/d)
+	} else {
+		DtVset(dir, 0, 0, 0)
+	}
+	return DT_SUCCESS
+}
@@ -18,14 +18,6 @@
 
 package detour
 
-/// A handle to a polygon within a navigation mesh tile.
-/// @ingroup detour
-type DtPolyRef uint32
-
-/// A handle to a tile within a navigation mesh.
-/// @ingroup detour
-type DtTileRef uint32
-
 /// The maximum number of vertices per navigation polygon.
 /// @ingroup detour
 const DT_VERTS_PER_POLYGON int32 = 6
@@ -71,6 +63,9 @@ type DtTileFlags int
 const (
 	/// The navigation mesh owns the tile memory and is responsible for freeing it.
 	DT_TILE_FREE_DATA DtTileFlags = 0x01
+
+	/// The tile holds an off-mesh connection added by dtNavMesh::addOffMeshConnection().
+	DT_TILE_OFFMESH_CONNECTION DtTileFlags = 0x02
 )
 
 /// Vertex flags returned by dtNavMeshQuery::findStraightPath.
@@ -299,6 +294,10 @@ type DtNavMesh struct {
 	m_saltBits uint32 ///< Number of salt bits in the tile ID.
 	m_tileBits uint32 ///< Number of tile bits in the tile ID.
 	m_polyBits uint32 ///< Number of poly bits in the tile ID.
+
+	m_listeners []DtTileListener ///< Notified of tile changes.
+
+	m_offMeshConTiles []*DtMeshTile ///< Tiles of the off-mesh connections added at runtime.
 }
 
 /// @{
@@ -311,7 +310,7 @@ type DtNavMesh struct {
 ///  @param[in]	it		The index of the tile.
 ///  @param[in]	ip		The index of the polygon within the tile.
 func (this *DtNavMesh) EncodePolyId(salt, it, ip uint32) DtPolyRef {
-	return DtPolyRef((salt << (this.m_polyBits + this.m_tileBits)) | (it << this.m_polyBits) | ip)
+	return (DtPolyRef(salt) << (this.m_polyBits + this.m_tileBits)) | (DtPolyRef(it) << this.m_polyBits) | DtPolyRef(ip)
 }
 
 /// Decodes a standard polygon reference.
@@ -322,12 +321,12 @@ func (this *DtNavMesh) EncodePolyId(salt, it, ip uint32) DtPolyRef {
 ///  @param[out]	ip		The index of the polygon within the tile.
 ///  @see #encodePolyId
 func (this *DtNavMesh) DecodePolyId(ref DtPolyRef, salt, it, ip *uint32) {
-	saltMask := (uint32(1) << this.m_saltBits) - 1
-	tileMask := (uint32(1) << this.m_tileBits) - 1
-	polyMask := (uint32(1) << this.m_polyBits) - 1
-	*salt = ((uint32(ref) >> (this.m_polyBits + this.m_tileBits)) & saltMask)
-	*it = ((uint32(ref) >> this.m_polyBits) & tileMask)
-	*ip = (uint32(ref) & polyMask)
+	saltMask := (DtPolyRef(1) << this.m_saltBits) - 1
+	tileMask := (DtPolyRef(1) << this.m_tileBits) - 1
+	polyMask := (DtPolyRef(1) << this.m_polyBits) - 1
+	*salt = uint32((ref >> (this.m_polyBits + this.m_tileBits)) & saltMask)
+	*it = uint32((ref >> this.m_polyBits) & tileMask)
+	*ip = uint32(ref & polyMask)
 }
 
 /// Extracts a tile's salt value from the specified polygon reference.
@@ -335,8 +334,8 @@ func (this *DtNavMesh) DecodePolyId(ref DtPolyRef, salt, it, ip *uint32) {
 ///  @param[in]	ref		The polygon reference.
 ///  @see #encodePolyId
 func (this *DtNavMesh) DecodePolyIdSalt(ref DtPolyRef) uint32 {
-	saltMask := (uint32(1) << this.m_saltBits) - 1
-	return ((uint32(ref) >> (this.m_polyBits + this.m_tileBits)) & saltMask)
+	saltMask := (DtPolyRef(1) << this.m_saltBits) - 1
+	return uint32((ref >> (this.m_polyBits + this.m_tileBits)) & saltMask)
 }
 
 /// Extracts the tile's index from the specified polygon reference.
@@ -344,8 +343,8 @@ func (this *DtNavMesh) DecodePolyIdSalt(ref DtPolyRef) uint32 {
 ///  @param[in]	ref		The polygon reference.
 ///  @see #encodePolyId
 func (this *DtNavMesh) DecodePolyIdTile(ref DtPolyRef) uint32 {
-	tileMask := (uint32(1) << this.m_tileBits) - 1
-	return ((uint32(ref) >> this.m_polyBits) & tileMask)
+	tileMask := (DtPolyRef(1) << this.m_tileBits) - 1
+	return uint32((ref >> this.m_polyBits) & tileMask)
 }
 
 /// Extracts the polygon's index (within its tile) from the specified polygon reference.
@@ -353,8 +352,8 @@ func (this *DtNavMesh) DecodePolyIdTile(ref DtPolyRef) uint32 {
 ///  @param[in]	ref		The polygon reference.
 ///  @see #encodePolyId
 func (this *DtNavMesh) DecodePolyIdPoly(ref DtPolyRef) uint32 {
-	polyMask := (uint32(1) << this.m_polyBits) - 1
-	return (uint32(ref) & polyMask)
+	polyMask := (DtPolyRef(1) << this.m_polyBits) - 1
+	return uint32(ref & polyMask)
 }
 
 /// @}
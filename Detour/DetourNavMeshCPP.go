@@ -192,6 +192,7 @@ func (this *DtNavMesh) Init(params *DtNavMeshParams) DtStatus {
 	}
 
 	this.m_nextFree = nil
+	this.m_offMeshConTiles = nil
 	for i := int(this.m_maxTiles - 1); i >= 0; i-- {
 		this.m_tiles[i].Salt = 1
 		this.m_tiles[i].Next = this.m_nextFree
@@ -201,8 +202,11 @@ func (this *DtNavMesh) Init(params *DtNavMeshParams) DtStatus {
 	// Init ID generator values.
 	this.m_tileBits = DtIlog2(DtNextPow2(params.MaxTiles))
 	this.m_polyBits = DtIlog2(DtNextPow2(params.MaxPolys))
-	// Only allow 31 salt bits, since the salt mask is calculated using 32bit uint and it will overflow.
-	this.m_saltBits = DtMinUInt32(31, 32-this.m_tileBits-this.m_polyBits)
+	// Only allow 31 salt bits, since the salt is stored in a 32bit uint and it will overflow.
+	if this.m_tileBits+this.m_polyBits >= DT_REF_BITS {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	this.m_saltBits = DtMinUInt32(31, DT_REF_BITS-this.m_tileBits-this.m_polyBits)
 
 	if this.m_saltBits < 10 {
 		return DT_FAILURE | DT_INVALID_PARAM
@@ -811,6 +815,24 @@ func (this *DtNavMesh) queryPolygonsInTile(tile *DtMeshTile, qmin, qmax []float3
 /// @see dtCreateNavMeshData, #removeTile
 func (this *DtNavMesh) AddTile(data []byte, dataSize int, flags DtTileFlags,
 	lastRef DtTileRef, result *DtTileRef) DtStatus {
+	return this.addTile(data, dataSize, flags, lastRef, result, false)
+}
+
+// dtTileDataSize returns the size of the tile data described by header.
+func dtTileDataSize(header *DtMeshHeader) int {
+	return DtAlign4(int(unsafe.Sizeof(DtMeshHeader{}))) +
+		DtAlign4(int(unsafe.Sizeof(float32(1.0)))*3*int(header.VertCount)) +
+		DtAlign4(int(unsafe.Sizeof(DtPoly{}))*int(header.PolyCount)) +
+		DtAlign4(int(unsafe.Sizeof(DtLink{}))*int(header.MaxLinkCount)) +
+		DtAlign4(int(unsafe.Sizeof(DtPolyDetail{}))*int(header.DetailMeshCount)) +
+		DtAlign4(int(unsafe.Sizeof(float32(1.0)))*3*int(header.DetailVertCount)) +
+		DtAlign4(int(unsafe.Sizeof(uint8(1)))*4*int(header.DetailTriCount)) +
+		DtAlign4(int(unsafe.Sizeof(DtBVNode{}))*int(header.BvNodeCount)) +
+		DtAlign4(int(unsafe.Sizeof(DtOffMeshConnection{}))*int(header.OffMeshConCount))
+}
+
+func (this *DtNavMesh) addTile(data []byte, dataSize int, flags DtTileFlags,
+	lastRef DtTileRef, result *DtTileRef, shared bool) DtStatus {
 
 	// Make sure the data is in right format.
 	if dataSize < DtAlign4(int(unsafe.Sizeof(DtMeshHeader{}))) {
@@ -823,6 +845,11 @@ func (this *DtNavMesh) AddTile(data []byte, dataSize int, flags DtTileFlags,
 	if header.Version != DT_NAVMESH_VERSION {
 		return DT_FAILURE | DT_WRONG_VERSION
 	}
+	// Make sure the data holds every section. The links of data built with
+	// references of another size have another size.
+	if dataSize > len(data) || dataSize < dtTileDataSize(header) {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
 
 	// Make sure the location is free.
 	if this.GetTileAt(header.X, header.Y, header.Layer) != nil {
@@ -950,6 +977,11 @@ func (this *DtNavMesh) AddTile(data []byte, dataSize int, flags DtTileFlags,
 		tile.BvTree = nil
 	}
 
+	// Shared tiles keep the dynamic portion of the data in private memory.
+	if shared {
+		this.privatizeTileData(tile)
+	}
+
 	// Build links freelist
 	tile.LinksFreeList = 0
 	tile.Links[header.MaxLinkCount-1].Next = DT_NULL_LINK
@@ -997,6 +1029,13 @@ func (this *DtNavMesh) AddTile(data []byte, dataSize int, flags DtTileFlags,
 		}
 	}
 
+	// Connect the off-mesh connections added at runtime landing on the tile.
+	this.connectOffMeshConnections(tile)
+
+	for _, listener := range this.m_listeners {
+		listener.TileAdded(this, this.GetTileRef(tile), tile)
+	}
+
 	if result != nil {
 		*result = this.GetTileRef(tile)
 	}
@@ -1268,6 +1307,14 @@ func (this *DtNavMesh) RemoveTile(ref DtTileRef, data *[]byte, dataSize *int) Dt
 		}
 	}
 
+	// Disconnect from the off-mesh connections added at runtime, which may
+	// link tiles anywhere on the mesh.
+	this.unconnectOffMeshConnections(tile)
+
+	for _, listener := range this.m_listeners {
+		listener.TileRemoved(this, ref, tile)
+	}
+
 	// Reset tile.
 	if (tile.Flags & DT_TILE_FREE_DATA) != 0 {
 		// Owns data
@@ -0,0 +1,61 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+/// Receives notifications when tiles are added to or removed from a
+/// navigation mesh, for structures derived from the mesh that must be kept up
+/// to date, such as those rebuilt by a tile cache.
+/// @see DtNavMesh::AddTileListener
+type DtTileListener interface {
+	/// Called at the end of #DtNavMesh::AddTile, once the tile is connected to
+	/// its neighbours.
+	///  @param[in]		nav		The navigation mesh.
+	///  @param[in]		ref		The reference of the new tile.
+	///  @param[in]		tile	The new tile.
+	TileAdded(nav *DtNavMesh, ref DtTileRef, tile *DtMeshTile)
+
+	/// Called by #DtNavMesh::RemoveTile once the tile is disconnected from its
+	/// neighbours and can no longer be found by location, but before its data
+	/// is released.
+	///  @param[in]		nav		The navigation mesh.
+	///  @param[in]		ref		The reference of the removed tile.
+	///  @param[in]		tile	The removed tile.
+	TileRemoved(nav *DtNavMesh, ref DtTileRef, tile *DtMeshTile)
+}
+
+/// Registers a listener notified of every tile added or removed from now on.
+///  @param[in]		listener	The listener.
+/// @par
+///
+/// Listeners are called synchronously by the goroutine changing the mesh, in
+/// the order they were added. They must not add or remove tiles themselves.
+func (this *DtNavMesh) AddTileListener(listener DtTileListener) {
+	this.m_listeners = append(this.m_listeners, listener)
+}
+
+/// Unregisters a listener added with #AddTileListener.
+///  @param[in]		listener	The listener.
+func (this *DtNavMesh) RemoveTileListener(listener DtTileListener) {
+	for i, l := range this.m_listeners {
+		if l == listener {
+			this.m_listeners = append(this.m_listeners[:i:i], this.m_listeners[i+1:]...)
+			return
+		}
+	}
+}
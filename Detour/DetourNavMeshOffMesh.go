@@ -0,0 +1,285 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+/// Adds an off-mesh connection to the navigation mesh at runtime.
+///  @param[in]		start		The start position of the connection. [(x, y, z)]
+///  @param[in]		end			The end position of the connection. [(x, y, z)]
+///  @param[in]		radius		The radius of the end points, within which they are
+///  							connected to the mesh. [Limit: > 0]
+///  @param[in]		bidir		True if the connection can be traversed from end to start.
+///  @param[in]		area		The area id of the connection. [Limit: < #DT_MAX_AREAS]
+///  @param[in]		flags		The user defined flags of the connection.
+///  @param[in]		userId		The user defined id of the connection.
+/// @return The polygon reference of the connection, and the status flags for
+/// the operation.
+/// @par
+///
+/// This is for the teleporters, ladders and bridges created by the game,
+/// without rebuilding the tiles under them. Unlike the connections built
+/// into the tile data, the end points can be any distance apart.
+///
+/// The connection is stored in a tile of its own, flagged with
+/// #DT_TILE_OFFMESH_CONNECTION, so the mesh must have been initialized with
+/// room for it in dtNavMeshParams::maxTiles, and #DT_OUT_OF_MEMORY is
+/// returned otherwise. The tile is taken from the tiles freed first, so that
+/// the tiles removed last can be added again with their last reference. The
+/// tile is not part of the tile grid, and the tile listeners are notified of
+/// it as of any other tile.
+///
+/// Each end point is linked to the nearest polygon within @p radius, and
+/// moved onto it, like the end points of the connections in the tile data.
+/// An end point on a tile which is not loaded is linked when the tile is
+/// added, and unlinked again when it is removed, while the polygon reference
+/// of the connection stays valid until #removeOffMeshConnection.
+///
+/// @see #removeOffMeshConnection
+func (this *DtNavMesh) AddOffMeshConnection(start, end []float32, radius float32, bidir bool,
+	area uint8, flags uint16, userId uint32) (DtPolyRef, DtStatus) {
+	if len(start) < 3 || len(end) < 3 || !(radius > 0) || int(area) >= DT_MAX_AREAS {
+		return 0, DT_FAILURE | DT_INVALID_PARAM
+	}
+
+	// Allocate a tile, from the end of the free list: the tiles removed last
+	// are at the start, and are likely to be added again with their last
+	// reference.
+	if this.m_nextFree == nil {
+		return 0, DT_FAILURE | DT_OUT_OF_MEMORY
+	}
+	var prev *DtMeshTile
+	tile := this.m_nextFree
+	for tile.Next != nil {
+		prev = tile
+		tile = tile.Next
+	}
+	if prev == nil {
+		this.m_nextFree = nil
+	} else {
+		prev.Next = nil
+	}
+
+	header := &DtMeshHeader{
+		Magic:           DT_NAVMESH_MAGIC,
+		Version:         DT_NAVMESH_VERSION,
+		Layer:           -1,
+		UserId:          userId,
+		PolyCount:       1,
+		VertCount:       2,
+		OffMeshConCount: 1,
+		OffMeshBase:     0,
+	}
+	// The tile is located at the start point, to be found with its
+	// neighbours, but it is not in the position lookup.
+	this.CalcTileLoc(start, &header.X, &header.Y)
+	DtVcopy(header.Bmin[:], start)
+	DtVmin(header.Bmin[:], end)
+	DtVcopy(header.Bmax[:], start)
+	DtVmax(header.Bmax[:], end)
+	for i := 0; i < 3; i += 2 {
+		header.Bmin[i] -= radius
+		header.Bmax[i] += radius
+	}
+
+	tile.Header = header
+	tile.Flags = DT_TILE_OFFMESH_CONNECTION
+	tile.Verts = make([]float32, 6)
+	DtVcopy(tile.Verts[0:3], start)
+	DtVcopy(tile.Verts[3:6], end)
+
+	tile.Polys = make([]DtPoly, 1)
+	poly := &tile.Polys[0]
+	poly.FirstLink = DT_NULL_LINK
+	poly.Verts[0] = 0
+	poly.Verts[1] = 1
+	poly.VertCount = 2
+	poly.Flags = flags
+	poly.SetArea(area)
+	poly.SetType(DT_POLYTYPE_OFFMESH_CONNECTION)
+
+	tile.OffMeshCons = make([]DtOffMeshConnection, 1)
+	con := &tile.OffMeshCons[0]
+	DtVcopy(con.Pos[0:3], start)
+	DtVcopy(con.Pos[3:6], end)
+	con.Rad = radius
+	con.Poly = 0
+	con.Side = 0xff
+	if bidir {
+		con.Flags = DT_OFFMESH_CON_BIDIR
+	}
+	con.UserId = userId
+
+	// The links are allocated as they are made.
+	tile.Links = nil
+	tile.LinksFreeList = DT_NULL_LINK
+
+	this.m_offMeshConTiles = append(this.m_offMeshConTiles, tile)
+
+	// Connect both end points to the tiles already loaded.
+	const MAX_NEIS int = 32
+	var neis [MAX_NEIS]*DtMeshTile
+	for k := 0; k < 2; k++ {
+		var tx, ty int32
+		this.CalcTileLoc(con.Pos[k*3:], &tx, &ty)
+		nneis := this.GetTilesAt(tx, ty, neis[:], MAX_NEIS)
+		for j := 0; j < nneis; j++ {
+			this.connectOffMeshEnd(tile, k, neis[j])
+		}
+	}
+
+	ref := this.GetTileRef(tile)
+	for _, listener := range this.m_listeners {
+		listener.TileAdded(this, ref, tile)
+	}
+	return this.GetPolyRefBase(tile), DT_SUCCESS
+}
+
+/// Removes an off-mesh connection added by #addOffMeshConnection.
+///  @param[in]		ref		The polygon reference of the connection.
+/// @return The status flags for the operation.
+/// @par
+///
+/// The connection is unlinked from the mesh, and its tile is released like
+/// by #removeTile, which invalidates the polygon reference.
+func (this *DtNavMesh) RemoveOffMeshConnection(ref DtPolyRef) DtStatus {
+	var tile *DtMeshTile
+	var poly *DtPoly
+	if DtStatusFailed(this.GetTileAndPolyByRef(ref, &tile, &poly)) || (tile.Flags&DT_TILE_OFFMESH_CONNECTION) == 0 {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	return this.RemoveTile(this.GetTileRef(tile), nil, nil)
+}
+
+/// Links the end points of the runtime off-mesh connections which land on a
+/// tile.
+func (this *DtNavMesh) connectOffMeshConnections(tile *DtMeshTile) {
+	for _, conTile := range this.m_offMeshConTiles {
+		con := &conTile.OffMeshCons[0]
+		for k := 0; k < 2; k++ {
+			var tx, ty int32
+			this.CalcTileLoc(con.Pos[k*3:], &tx, &ty)
+			if tx == tile.Header.X && ty == tile.Header.Y {
+				this.connectOffMeshEnd(conTile, k, tile)
+			}
+		}
+	}
+}
+
+/// Removes the links between a tile and the runtime off-mesh connections.
+/// The tile may be the tile of a connection.
+func (this *DtNavMesh) unconnectOffMeshConnections(tile *DtMeshTile) {
+	if (tile.Flags & DT_TILE_OFFMESH_CONNECTION) == 0 {
+		for _, conTile := range this.m_offMeshConTiles {
+			this.unconnectLinks(conTile, tile)
+		}
+		return
+	}
+
+	// Remove the links of the land polygons to the connection.
+	poly := &tile.Polys[0]
+	for i := poly.FirstLink; i != DT_NULL_LINK; i = tile.Links[i].Next {
+		var landTile *DtMeshTile
+		var landPoly *DtPoly
+		if DtStatusSucceed(this.GetTileAndPolyByRef(tile.Links[i].Ref, &landTile, &landPoly)) {
+			this.unconnectLinks(landTile, tile)
+		}
+	}
+	for i, conTile := range this.m_offMeshConTiles {
+		if conTile == tile {
+			this.m_offMeshConTiles = append(this.m_offMeshConTiles[:i], this.m_offMeshConTiles[i+1:]...)
+			break
+		}
+	}
+}
+
+/// Links an end point of a runtime off-mesh connection to the nearest
+/// polygon of a tile, unless it is linked already.
+///  @param[in]		tile	The tile of the connection.
+///  @param[in]		end		The end point, 0 for the start and 1 for the end.
+///  @param[in]		target	The tile to link to.
+func (this *DtNavMesh) connectOffMeshEnd(tile *DtMeshTile, end int, target *DtMeshTile) {
+	con := &tile.OffMeshCons[0]
+	poly := &tile.Polys[0]
+	for i := poly.FirstLink; i != DT_NULL_LINK; i = tile.Links[i].Next {
+		if int(tile.Links[i].Edge) == end {
+			return
+		}
+	}
+
+	halfExtents := [3]float32{con.Rad, target.Header.WalkableClimb, con.Rad}
+
+	// Find polygon to connect to.
+	p := con.Pos[end*3 : end*3+3]
+	var nearestPt [3]float32
+	ref := this.findNearestPolyInTile(target, p, halfExtents[:], nearestPt[:])
+	if ref == 0 {
+		return
+	}
+	// findNearestPoly may return too optimistic results, further check to make sure.
+	if DtSqrFloat32(nearestPt[0]-p[0])+DtSqrFloat32(nearestPt[2]-p[2]) > DtSqrFloat32(con.Rad) {
+		return
+	}
+	// Make sure the location is on current mesh.
+	DtVcopy(tile.Verts[poly.Verts[end]*3:], nearestPt[:])
+
+	// Link off-mesh connection to target poly.
+	idx := allocLinkGrow(tile)
+	link := &tile.Links[idx]
+	link.Ref = ref
+	link.Edge = uint8(end)
+	link.Side = 0xff
+	link.Bmin = 0
+	link.Bmax = 0
+	// Add to linked list.
+	link.Next = poly.FirstLink
+	poly.FirstLink = idx
+
+	// The start is always connected back to the connection, the end only
+	// when the connection is bidirectional.
+	if end == 1 && (con.Flags&DT_OFFMESH_CON_BIDIR) == 0 {
+		return
+	}
+	tidx := allocLinkGrow(target)
+	landPoly := &target.Polys[this.DecodePolyIdPoly(ref)]
+	link = &target.Links[tidx]
+	link.Ref = this.GetPolyRefBase(tile) | DtPolyRef(con.Poly)
+	link.Edge = 0xff
+	link.Side = 0xff
+	link.Bmin = 0
+	link.Bmax = 0
+	// Add to linked list.
+	link.Next = landPoly.FirstLink
+	landPoly.FirstLink = tidx
+}
+
+/// Allocates a link of a tile, growing the links of the tile when none is
+/// free. The links are then kept in memory owned by the nav mesh.
+func allocLinkGrow(tile *DtMeshTile) uint32 {
+	if tile.LinksFreeList == DT_NULL_LINK {
+		n := len(tile.Links)
+		links := make([]DtLink, n+n/2+4)
+		copy(links, tile.Links)
+		for i := n; i < len(links)-1; i++ {
+			links[i].Next = uint32(i + 1)
+		}
+		links[len(links)-1].Next = DT_NULL_LINK
+		tile.Links = links
+		tile.LinksFreeList = uint32(n)
+	}
+	return allocLink(tile)
+}
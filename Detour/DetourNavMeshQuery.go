@@ -19,6 +19,37 @@
 package detour
 
 /// Defines polygon filtering and traversal costs for navigation mesh query operations.
+/// This is the Go counterpart of building Detour with DT_VIRTUAL_QUERYFILTER:
+/// every query accepts any implementation, and #DtQueryFilter is the default one.
+/// Implementations can wrap a #DtQueryFilter to extend its behaviour.
+/// @ingroup detour
+type DtQueryFilterI interface {
+	/// Returns true if the polygon can be visited.  (I.e. Is traversable.)
+	///  @param[in]		ref		The reference id of the polygon test.
+	///  @param[in]		tile	The tile containing the polygon.
+	///  @param[in]		poly  The polygon to test.
+	PassFilter(ref DtPolyRef, tile *DtMeshTile, poly *DtPoly) bool
+
+	/// Returns cost to move from the beginning to the end of a line segment
+	/// that is fully contained within a polygon.
+	///  @param[in]		pa			The start position on the edge of the previous and current polygon. [(x, y, z)]
+	///  @param[in]		pb			The end position on the edge of the current and next polygon. [(x, y, z)]
+	///  @param[in]		prevRef		The reference id of the previous polygon. [opt]
+	///  @param[in]		prevTile	The tile containing the previous polygon. [opt]
+	///  @param[in]		prevPoly	The previous polygon. [opt]
+	///  @param[in]		curRef		The reference id of the current polygon.
+	///  @param[in]		curTile		The tile containing the current polygon.
+	///  @param[in]		curPoly		The current polygon.
+	///  @param[in]		nextRef		The refernece id of the next polygon. [opt]
+	///  @param[in]		nextTile	The tile containing the next polygon. [opt]
+	///  @param[in]		nextPoly	The next polygon. [opt]
+	GetCost(pa, pb []float32,
+		prevRef DtPolyRef, prevTile *DtMeshTile, prevPoly *DtPoly,
+		curRef DtPolyRef, curTile *DtMeshTile, curPoly *DtPoly,
+		nextRef DtPolyRef, nextTile *DtMeshTile, nextPoly *DtPoly) float32
+}
+
+/// The default query filter, using per area costs and include/exclude flags.
 /// @ingroup detour
 type DtQueryFilter struct {
 	m_areaCost     [DT_MAX_AREAS]float32 ///< Cost per area type. (Used by default implementation.)
@@ -115,17 +146,21 @@ type dtQueryData struct {
 	endRef           DtPolyRef
 	startPos         [3]float32
 	endPos           [3]float32
-	filter           *DtQueryFilter
+	filter           DtQueryFilterI
 	options          DtFindPathOptions
 	raycastLimitSqr  float32
 }
 
 type DtNavMeshQuery struct {
-	m_nav          *DtNavMesh   ///< Pointer to navmesh data.
-	m_query        dtQueryData  ///< Sliced query state.
-	m_tinyNodePool *DtNodePool  ///< Pointer to small node pool.
-	m_nodePool     *DtNodePool  ///< Pointer to node pool.
-	m_openList     *DtNodeQueue ///< Pointer to open list queue.
+	m_nav           *DtNavMesh   ///< Pointer to navmesh data.
+	m_query         dtQueryData  ///< Sliced query state.
+	m_tinyNodePool  *DtNodePool  ///< Pointer to small node pool.
+	m_nodePool      *DtNodePool  ///< Pointer to node pool.
+	m_openList      *DtNodeQueue ///< Pointer to open list queue.
+	m_backNodePool  *DtNodePool  ///< Node pool of the backward search. (Allocated on first use.)
+	m_backOpenList  *DtNodeQueue ///< Open list of the backward search. (Allocated on first use.)
+	m_openCosts     dtCostHeap   ///< Costs of the forward open nodes, for #FindPathBidirectional.
+	m_backOpenCosts dtCostHeap   ///< Costs of the backward open nodes, for #FindPathBidirectional.
 }
 
 /// Gets the node pool.
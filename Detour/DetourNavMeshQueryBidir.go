@@ -0,0 +1,510 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+import (
+	"math"
+)
+
+/// Finds a path from the start polygon to the end polygon, searching from both
+/// ends at the same time.
+///  @param[in]		startRef	The refrence id of the start polygon.
+///  @param[in]		endRef		The reference id of the end polygon.
+///  @param[in]		startPos	A position within the start polygon. [(x, y, z)]
+///  @param[in]		endPos		A position within the end polygon. [(x, y, z)]
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[out]	path		An ordered list of polygon references representing the path. (Start to end.)
+///  							[(polyRef) * @p pathCount]
+///  @param[out]	pathCount	The number of polygons returned in the @p path array.
+///  @param[in]		maxPath		The maximum number of polygons the @p path array can hold. [Limit: >= 1]
+/// @returns The status flags for the query.
+/// @par
+///
+/// The result is in the same format as #FindPath, and uses the same costs.
+/// Once the two searches meet, they go on until no cheaper join can be found:
+/// until the best open node of either search costs at least the cheapest
+/// join in total, or the lowest costs of the two open lists add up to it.
+///
+/// The path costs about the same as the one of #FindPath, but not always
+/// exactly: a node is placed at the middle of the edge it is first reached
+/// through, and the two searches reach the edges from opposite sides, so
+/// they do not measure the same distances. The path may come out a few
+/// percent more or less expensive.
+///
+/// Each direction has its own node pool of the size given to #Init, and the
+/// backward pool is allocated on the first call, so long paths that run
+/// #FindPath out of nodes can still be completed. When the backward pool runs
+/// out, the search finishes from the start only, joining the backward nodes
+/// found so far, like #FindPath.
+///
+/// The backward search follows links in reverse, so one-way off-mesh
+/// connections are only crossed from their start to their end.
+///
+/// If the end polygon cannot be reached, the path to the polygon closest to the
+/// end found by the forward search is returned with #DT_PARTIAL_RESULT.
+func (this *DtNavMeshQuery) FindPathBidirectional(startRef, endRef DtPolyRef,
+	startPos, endPos []float32,
+	filter DtQueryFilterI,
+	path []DtPolyRef, pathCount *int, maxPath int) DtStatus {
+	DtAssert(this.m_nav != nil)
+	DtAssert(this.m_nodePool != nil)
+	DtAssert(this.m_openList != nil)
+
+	if pathCount != nil {
+		*pathCount = 0
+	}
+	// Validate input
+	if !this.m_nav.IsValidPolyRef(startRef) || !this.m_nav.IsValidPolyRef(endRef) ||
+		startPos == nil || endPos == nil || filter == nil || maxPath <= 0 || path == nil || pathCount == nil {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	if startRef == endRef {
+		path[0] = startRef
+		*pathCount = 1
+		return DT_SUCCESS
+	}
+	if DtStatusFailed(this.initBackwardSearch()) {
+		return DT_FAILURE | DT_OUT_OF_MEMORY
+	}
+
+	this.m_nodePool.Clear()
+	this.m_openList.Clear()
+	this.m_backNodePool.Clear()
+	this.m_backOpenList.Clear()
+	this.m_openCosts.clear()
+	this.m_backOpenCosts.clear()
+
+	startNode := this.m_nodePool.GetNode(startRef, 0)
+	DtVcopy(startNode.Pos[:], startPos)
+	startNode.Pidx = 0
+	startNode.Cost = 0
+	startNode.Total = DtVdist(startPos, endPos) * H_SCALE
+	startNode.Id = startRef
+	startNode.Flags = DT_NODE_OPEN
+	this.m_openList.Push(startNode)
+	this.m_openCosts.push(startNode)
+
+	endNode := this.m_backNodePool.GetNode(endRef, 0)
+	DtVcopy(endNode.Pos[:], endPos)
+	endNode.Pidx = 0
+	endNode.Cost = 0
+	endNode.Total = startNode.Total
+	endNode.Id = endRef
+	endNode.Flags = DT_NODE_OPEN
+	this.m_backOpenList.Push(endNode)
+	this.m_backOpenCosts.push(endNode)
+
+	lastBestNode := startNode
+	lastBestNodeCost := startNode.Total
+
+	// The cheapest join of the two searches goes through meetNode of the
+	// forward search and meetBackNode of the backward search, on the same
+	// polygon.
+	bestCost := float32(math.MaxFloat32)
+	var meetNode, meetBackNode *DtNode
+
+	outOfNodes := false
+	backOutOfNodes := false
+
+	var (
+		forward              bool
+		pool, otherPool      *DtNodePool
+		openList             *DtNodeQueue
+		openCosts            *dtCostHeap
+		target               []float32
+		bestNode             *DtNode
+		bestRef, parentRef   DtPolyRef
+		bestTile, parentTile *DtMeshTile
+		bestPoly, parentPoly *DtPoly
+		others               [DT_MAX_STATES_PER_NODE]*DtNode
+	)
+
+	// Relaxes the edge from bestNode to a neighbour polygon, in the direction
+	// of the current search.
+	relax := func(neighbourRef DtPolyRef, crossSide uint8) {
+		// Do not expand back to where we came from.
+		if neighbourRef == 0 || neighbourRef == parentRef {
+			return
+		}
+		// Get neighbour poly and tile.
+		// The API input has been cheked already, skip checking internal data.
+		var neighbourTile *DtMeshTile
+		var neighbourPoly *DtPoly
+		this.m_nav.GetTileAndPolyByRefUnsafe(neighbourRef, &neighbourTile, &neighbourPoly)
+
+		if !filter.PassFilter(neighbourRef, neighbourTile, neighbourPoly) {
+			return
+		}
+		neighbourNode := pool.GetNode(neighbourRef, crossSide)
+		if neighbourNode == nil {
+			if forward {
+				outOfNodes = true
+			} else {
+				backOutOfNodes = true
+			}
+			return
+		}
+
+		// If the node is visited the first time, calculate node position.
+		var curCost float32
+		if forward {
+			if neighbourNode.Flags == 0 {
+				this.getEdgeMidPoint2(bestRef, bestPoly, bestTile,
+					neighbourRef, neighbourPoly, neighbourTile,
+					neighbourNode.Pos[:])
+			}
+			curCost = filter.GetCost(bestNode.Pos[:], neighbourNode.Pos[:],
+				parentRef, parentTile, parentPoly,
+				bestRef, bestTile, bestPoly,
+				neighbourRef, neighbourTile, neighbourPoly)
+		} else {
+			// The backward search walks the links in reverse, from the
+			// neighbour into the best polygon.
+			if neighbourNode.Flags == 0 {
+				this.getEdgeMidPoint2(neighbourRef, neighbourPoly, neighbourTile,
+					bestRef, bestPoly, bestTile,
+					neighbourNode.Pos[:])
+			}
+			curCost = filter.GetCost(neighbourNode.Pos[:], bestNode.Pos[:],
+				neighbourRef, neighbourTile, neighbourPoly,
+				bestRef, bestTile, bestPoly,
+				parentRef, parentTile, parentPoly)
+		}
+		cost := bestNode.Cost + curCost
+		heuristic := DtVdist(neighbourNode.Pos[:], target) * H_SCALE
+		total := cost + heuristic
+
+		// The node is already in open list and the new result is worse, skip.
+		if (neighbourNode.Flags&DT_NODE_OPEN) != 0 && total >= neighbourNode.Total {
+			return
+		}
+		// The node is already visited and process, and the new result is worse, skip.
+		if (neighbourNode.Flags&DT_NODE_CLOSED) != 0 && total >= neighbourNode.Total {
+			return
+		}
+		// Add or update the node.
+		neighbourNode.Pidx = pool.GetNodeIdx(bestNode)
+		neighbourNode.Id = neighbourRef
+		neighbourNode.Flags = (neighbourNode.Flags & ^DT_NODE_CLOSED)
+		neighbourNode.Cost = cost
+		neighbourNode.Total = total
+
+		if (neighbourNode.Flags & DT_NODE_OPEN) != 0 {
+			// Already in open, update node location.
+			openList.Modify(neighbourNode)
+		} else {
+			// Put the node in open list.
+			neighbourNode.Flags |= DT_NODE_OPEN
+			openList.Push(neighbourNode)
+		}
+		openCosts.push(neighbourNode)
+
+		// Update nearest node to target so far.
+		if forward && heuristic < lastBestNodeCost {
+			lastBestNodeCost = heuristic
+			lastBestNode = neighbourNode
+		}
+
+		// Join with the nodes the other search has on the same polygon.
+		n := otherPool.FindNodes(neighbourRef, others[:], uint32(DT_MAX_STATES_PER_NODE))
+		for i := uint32(0); i < n; i++ {
+			fwdNode, backNode := neighbourNode, others[i]
+			if !forward {
+				fwdNode, backNode = backNode, fwdNode
+			}
+			joinCost := fwdNode.Cost + backNode.Cost +
+				this.joinCost(fwdNode, backNode, neighbourRef, neighbourTile, neighbourPoly, filter)
+			if joinCost < bestCost {
+				bestCost = joinCost
+				meetNode = fwdNode
+				meetBackNode = backNode
+			}
+		}
+	}
+
+	for !this.m_openList.Empty() && (backOutOfNodes || !this.m_backOpenList.Empty()) {
+		// Stop when no open node can lead to a cheaper join.
+		if meetNode != nil {
+			if this.m_openList.Top().Total >= bestCost {
+				break
+			}
+			if !backOutOfNodes && (this.m_backOpenList.Top().Total >= bestCost ||
+				this.m_openCosts.min()+this.m_backOpenCosts.min() >= bestCost) {
+				break
+			}
+		}
+
+		// Expand the smaller frontier, or only the forward one once the
+		// backward search is out of nodes.
+		forward = backOutOfNodes || this.m_openList.m_size <= this.m_backOpenList.m_size
+		if forward {
+			pool, otherPool, openList, openCosts, target = this.m_nodePool, this.m_backNodePool, this.m_openList, &this.m_openCosts, endPos
+		} else {
+			pool, otherPool, openList, openCosts, target = this.m_backNodePool, this.m_nodePool, this.m_backOpenList, &this.m_backOpenCosts, startPos
+		}
+
+		// Remove node from open list and put it in closed list.
+		bestNode = openList.Pop()
+		bestNode.Flags &= ^DT_NODE_OPEN
+		bestNode.Flags |= DT_NODE_CLOSED
+
+		// Get current poly and tile.
+		// The API input has been cheked already, skip checking internal data.
+		bestRef = bestNode.Id
+		this.m_nav.GetTileAndPolyByRefUnsafe(bestRef, &bestTile, &bestPoly)
+
+		// Get parent poly and tile. For the backward search the parent is
+		// the next polygon towards the end.
+		parentRef = 0
+		parentTile = nil
+		parentPoly = nil
+		if bestNode.Pidx != 0 {
+			parentRef = pool.GetNodeAtIdx(bestNode.Pidx).Id
+		}
+		if parentRef != 0 {
+			this.m_nav.GetTileAndPolyByRefUnsafe(parentRef, &parentTile, &parentPoly)
+		}
+
+		if forward {
+			for i := bestPoly.FirstLink; i != DT_NULL_LINK; i = bestTile.Links[i].Next {
+				link := &bestTile.Links[i]
+				// deal explicitly with crossing tile boundaries
+				var crossSide uint8
+				if link.Side != 0xff {
+					crossSide = link.Side >> 1
+				}
+				relax(link.Ref, crossSide)
+			}
+			continue
+		}
+
+		this.forEachLinkInto(bestRef, bestTile, bestPoly, relax)
+	}
+
+	var status DtStatus
+	if meetNode != nil {
+		status = this.getPathThroughNodes(meetNode, meetBackNode, path, pathCount, maxPath)
+	} else {
+		status = this.getPathToNode(lastBestNode, path, pathCount, maxPath)
+		status |= DT_PARTIAL_RESULT
+	}
+	if outOfNodes {
+		status |= DT_OUT_OF_NODES
+	}
+	return status
+}
+
+// dtCostEntry is a node of an open list, with its cost when it was added.
+type dtCostEntry struct {
+	node *DtNode
+	cost float32
+}
+
+// dtCostHeap keeps the lowest cost of the nodes of an open list, which is
+// ordered by total cost. A node is added again each time its cost changes,
+// and the entries of nodes since closed or made cheaper are dropped when
+// they come on top.
+type dtCostHeap struct {
+	m_entries []dtCostEntry
+}
+
+func (this *dtCostHeap) clear() { this.m_entries = this.m_entries[:0] }
+
+// push adds a node with its current cost.
+func (this *dtCostHeap) push(node *DtNode) {
+	this.m_entries = append(this.m_entries, dtCostEntry{node, node.Cost})
+	i := len(this.m_entries) - 1
+	for i > 0 {
+		parent := (i - 1) / 2
+		if this.m_entries[parent].cost <= this.m_entries[i].cost {
+			break
+		}
+		this.m_entries[parent], this.m_entries[i] = this.m_entries[i], this.m_entries[parent]
+		i = parent
+	}
+}
+
+// min returns the lowest cost of the open nodes, or FLT_MAX if there are none.
+func (this *dtCostHeap) min() float32 {
+	for len(this.m_entries) > 0 {
+		top := &this.m_entries[0]
+		if (top.node.Flags&DT_NODE_OPEN) != 0 && top.node.Cost == top.cost {
+			return top.cost
+		}
+		this.pop()
+	}
+	return math.MaxFloat32
+}
+
+// pop removes the entry with the lowest cost.
+func (this *dtCostHeap) pop() {
+	n := len(this.m_entries) - 1
+	this.m_entries[0] = this.m_entries[n]
+	this.m_entries = this.m_entries[:n]
+	i := 0
+	for {
+		child := i*2 + 1
+		if child >= n {
+			break
+		}
+		if child+1 < n && this.m_entries[child+1].cost < this.m_entries[child].cost {
+			child++
+		}
+		if this.m_entries[i].cost <= this.m_entries[child].cost {
+			break
+		}
+		this.m_entries[i], this.m_entries[child] = this.m_entries[child], this.m_entries[i]
+		i = child
+	}
+}
+
+// initBackwardSearch allocates the node pool and open list of the backward
+// search, as large as those of the forward search.
+func (this *DtNavMeshQuery) initBackwardSearch() DtStatus {
+	maxNodes := this.m_nodePool.GetMaxNodes()
+	if this.m_backNodePool == nil || this.m_backNodePool.GetMaxNodes() < maxNodes {
+		this.m_backNodePool = DtAllocNodePool(maxNodes, this.m_nodePool.GetHashSize())
+		if this.m_backNodePool == nil {
+			return DT_FAILURE | DT_OUT_OF_MEMORY
+		}
+	}
+	if this.m_backOpenList == nil || this.m_backOpenList.GetCapacity() < int(maxNodes) {
+		this.m_backOpenList = DtAllocNodeQueue(int(maxNodes))
+		if this.m_backOpenList == nil {
+			return DT_FAILURE | DT_OUT_OF_MEMORY
+		}
+	}
+	return DT_SUCCESS
+}
+
+// joinCost returns the cost of crossing the polygon where the forward and the
+// backward search meet, from the entry of the forward node to the exit of the
+// backward node.
+func (this *DtNavMeshQuery) joinCost(fwdNode, backNode *DtNode,
+	ref DtPolyRef, tile *DtMeshTile, poly *DtPoly, filter DtQueryFilterI) float32 {
+	var prevRef, nextRef DtPolyRef
+	var prevTile, nextTile *DtMeshTile
+	var prevPoly, nextPoly *DtPoly
+	if fwdNode.Pidx != 0 {
+		prevRef = this.m_nodePool.GetNodeAtIdx(fwdNode.Pidx).Id
+		this.m_nav.GetTileAndPolyByRefUnsafe(prevRef, &prevTile, &prevPoly)
+	}
+	if backNode.Pidx != 0 {
+		nextRef = this.m_backNodePool.GetNodeAtIdx(backNode.Pidx).Id
+		this.m_nav.GetTileAndPolyByRefUnsafe(nextRef, &nextTile, &nextPoly)
+	}
+	return filter.GetCost(fwdNode.Pos[:], backNode.Pos[:],
+		prevRef, prevTile, prevPoly,
+		ref, tile, poly,
+		nextRef, nextTile, nextPoly)
+}
+
+// getPathThroughNodes stores the path from the start to the end through the
+// polygon where the forward and the backward search meet.
+func (this *DtNavMeshQuery) getPathThroughNodes(fwdNode, backNode *DtNode,
+	path []DtPolyRef, pathCount *int, maxPath int) DtStatus {
+	// The forward half, from the start up to and including the meeting polygon.
+	status := this.getPathToNode(fwdNode, path, pathCount, maxPath)
+	n := *pathCount
+	if (status & DT_BUFFER_TOO_SMALL) != 0 {
+		return status
+	}
+
+	// The backward half, from the next polygon to the end.
+	for node := this.m_backNodePool.GetNodeAtIdx(backNode.Pidx); node != nil; node = this.m_backNodePool.GetNodeAtIdx(node.Pidx) {
+		// Cut the loop if the halves cross each other.
+		for i := 0; i < n; i++ {
+			if path[i] == node.Id {
+				n = i
+				break
+			}
+		}
+		if n >= maxPath {
+			*pathCount = n
+			return DT_SUCCESS | DT_BUFFER_TOO_SMALL
+		}
+		path[n] = node.Id
+		n++
+	}
+	*pathCount = n
+	return DT_SUCCESS
+}
+
+// forEachLinkInto calls fn for every polygon with a link into the polygon
+// ref, with the tile side of that link. Links are symmetric, except for
+// those of off-mesh connections.
+func (this *DtNavMeshQuery) forEachLinkInto(ref DtPolyRef, tile *DtMeshTile, poly *DtPoly,
+	fn func(from DtPolyRef, crossSide uint8)) {
+	for i := poly.FirstLink; i != DT_NULL_LINK; i = tile.Links[i].Next {
+		neighbourRef := tile.Links[i].Ref
+		if neighbourRef == 0 {
+			continue
+		}
+		var neighbourTile *DtMeshTile
+		var neighbourPoly *DtPoly
+		this.m_nav.GetTileAndPolyByRefUnsafe(neighbourRef, &neighbourTile, &neighbourPoly)
+		if back := findLinkTo(neighbourTile, neighbourPoly, ref); back != nil {
+			var crossSide uint8
+			if back.Side != 0xff {
+				crossSide = back.Side >> 1
+			}
+			fn(neighbourRef, crossSide)
+		}
+	}
+	// One-way off-mesh connections landing on the polygon, which has no link
+	// back to them. They can live in any neighbour tile, or in the tiles of
+	// the runtime connections, which are not in the position lookup.
+	if poly.GetType() != DT_POLYTYPE_GROUND {
+		return
+	}
+	var neighbourTiles [32]*DtMeshTile
+	h := tile.Header
+	ntiles := this.m_nav.GetTilesAt(h.X, h.Y, neighbourTiles[:], len(neighbourTiles))
+	for side := 0; side < 8; side++ {
+		ntiles += this.m_nav.GetNeighbourTilesAt(h.X, h.Y, side, neighbourTiles[ntiles:], len(neighbourTiles)-ntiles)
+	}
+	from := append(neighbourTiles[:ntiles:ntiles], this.m_nav.m_offMeshConTiles...)
+	for _, nei := range from {
+		if nei.Header.OffMeshConCount == 0 {
+			continue
+		}
+		base := this.m_nav.GetPolyRefBase(nei)
+		for k := int32(0); k < nei.Header.OffMeshConCount; k++ {
+			idx := nei.Header.OffMeshBase + k
+			conRef := base | DtPolyRef(idx)
+			if findLinkTo(nei, &nei.Polys[idx], ref) != nil && findLinkTo(tile, poly, conRef) == nil {
+				fn(conRef, 0)
+			}
+		}
+	}
+}
+
+// findLinkTo returns the link of a polygon to another polygon, or nil.
+func findLinkTo(tile *DtMeshTile, poly *DtPoly, ref DtPolyRef) *DtLink {
+	for i := poly.FirstLink; i != DT_NULL_LINK; i = tile.Links[i].Next {
+		if tile.Links[i].Ref == ref {
+			return &tile.Links[i]
+		}
+	}
+	return nil
+}
+
+/// Gets the node pool of the backward search of #FindPathBidirectional.
+/// @returns The node pool, or nil before the first bidirectional search.
+func (this *DtNavMeshQuery) GetBackNodePool() *DtNodePool { return this.m_backNodePool }
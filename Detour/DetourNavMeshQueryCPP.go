@@ -19,6 +19,7 @@
 package detour
 
 import (
+	"context"
 	"math"
 	"unsafe"
 )
@@ -115,6 +116,14 @@ func (this *DtNavMeshQuery) destructor() {
 		DtFreeNodeQueue(this.m_openList)
 		this.m_openList = nil
 	}
+	if this.m_backNodePool != nil {
+		DtFreeNodePool(this.m_backNodePool)
+		this.m_backNodePool = nil
+	}
+	if this.m_backOpenList != nil {
+		DtFreeNodeQueue(this.m_backOpenList)
+		this.m_backOpenList = nil
+	}
 }
 
 /// Initializes the query object.
@@ -178,7 +187,7 @@ func (this *DtNavMeshQuery) Init(nav *DtNavMesh, maxNodes int) DtStatus {
 ///  @param[out]	randomRef		The reference id of the random location.
 ///  @param[out]	randomPt		The random location.
 /// @returns The status flags for the query.
-func (this *DtNavMeshQuery) FindRandomPoint(filter *DtQueryFilter, frand func() float32,
+func (this *DtNavMeshQuery) FindRandomPoint(filter DtQueryFilterI, frand func() float32,
 	randomRef *DtPolyRef, randomPt []float32) DtStatus {
 	DtAssert(this.m_nav != nil)
 
@@ -187,7 +196,7 @@ func (this *DtNavMeshQuery) FindRandomPoint(filter *DtQueryFilter, frand func()
 	var tsum float32
 	for i := 0; i < int(this.m_nav.GetMaxTiles()); i++ {
 		t := this.m_nav.GetTile(i)
-		if t == nil || t.Header == nil {
+		if t == nil || t.Header == nil || (t.Flags&DT_TILE_OFFMESH_CONNECTION) != 0 {
 			continue
 		}
 
@@ -240,6 +249,12 @@ func (this *DtNavMeshQuery) FindRandomPoint(filter *DtQueryFilter, frand func()
 	if poly == nil {
 		return DT_FAILURE
 	}
+	return this.randomPointInPoly(polyRef, tile, poly, frand, randomRef, randomPt)
+}
+
+/// Picks a random location on a ground polygon, for the random point queries.
+func (this *DtNavMeshQuery) randomPointInPoly(polyRef DtPolyRef, tile *DtMeshTile, poly *DtPoly,
+	frand func() float32, randomRef *DtPolyRef, randomPt []float32) DtStatus {
 	// Randomly pick point on polygon.
 	v := tile.Verts[poly.Verts[0]*3:]
 	var verts [3 * DT_VERTS_PER_POLYGON]float32
@@ -280,7 +295,7 @@ func (this *DtNavMeshQuery) FindRandomPoint(filter *DtQueryFilter, frand func()
 ///  @param[out]	randomPt		The random location. [(x, y, z)]
 /// @returns The status flags for the query.
 func (this *DtNavMeshQuery) FindRandomPointAroundCircle(startRef DtPolyRef, centerPos []float32, maxRadius float32,
-	filter *DtQueryFilter, frand func() float32,
+	filter DtQueryFilterI, frand func() float32,
 	randomRef *DtPolyRef, randomPt []float32) DtStatus {
 	DtAssert(this.m_nav != nil)
 	DtAssert(this.m_nodePool != nil)
@@ -726,7 +741,7 @@ func (this *dtFindNearestPolyQuery) Process(tile *DtMeshTile, polys []*DtPoly, r
 /// @p nearestRef before using @p nearestPt.
 ///
 func (this *DtNavMeshQuery) FindNearestPoly(center, halfExtents []float32,
-	filter *DtQueryFilter,
+	filter DtQueryFilterI,
 	nearestRef *DtPolyRef, nearestPt []float32) DtStatus {
 	DtAssert(this.m_nav != nil)
 
@@ -751,7 +766,7 @@ func (this *DtNavMeshQuery) FindNearestPoly(center, halfExtents []float32,
 
 /// Queries polygons within a tile.
 func (this *DtNavMeshQuery) queryPolygonsInTile(tile *DtMeshTile, qmin, qmax []float32,
-	filter *DtQueryFilter, query DtPolyQuery) {
+	filter DtQueryFilterI, query DtPolyQuery) {
 	DtAssert(this.m_nav != nil)
 	const batchSize int = 32
 	var polyRefs [batchSize]DtPolyRef
@@ -900,7 +915,7 @@ func (this *dtCollectPolysQuery) Process(tile *DtMeshTile, polys []*DtPoly, refs
 /// full set are included in the partial result set is undefined.
 ///
 func (this *DtNavMeshQuery) QueryPolygons(center, halfExtents []float32,
-	filter *DtQueryFilter,
+	filter DtQueryFilterI,
 	polys []DtPolyRef, polyCount *int, maxPolys int) DtStatus {
 	if polys == nil || polyCount == nil || maxPolys < 0 {
 		return DT_FAILURE | DT_INVALID_PARAM
@@ -933,7 +948,7 @@ func (this *DtNavMeshQuery) QueryPolygons(center, halfExtents []float32,
 /// times until all overlapping polygons have been processed.
 ///
 func (this *DtNavMeshQuery) QueryPolygons2(center, halfExtents []float32,
-	filter *DtQueryFilter, query DtPolyQuery) DtStatus {
+	filter DtQueryFilterI, query DtPolyQuery) DtStatus {
 	DtAssert(this.m_nav != nil)
 
 	if center == nil || halfExtents == nil || filter == nil || query == nil {
@@ -986,7 +1001,15 @@ func (this *DtNavMeshQuery) QueryPolygons2(center, halfExtents []float32,
 ///
 func (this *DtNavMeshQuery) FindPath(startRef, endRef DtPolyRef,
 	startPos, endPos []float32,
-	filter *DtQueryFilter,
+	filter DtQueryFilterI,
+	path []DtPolyRef, pathCount *int, maxPath int) DtStatus {
+	return this.findPath(nil, startRef, endRef, startPos, endPos, filter, path, pathCount, maxPath)
+}
+
+// findPath implements FindPath, and FindPathContext when ctx is not nil.
+func (this *DtNavMeshQuery) findPath(ctx context.Context, startRef, endRef DtPolyRef,
+	startPos, endPos []float32,
+	filter DtQueryFilterI,
 	path []DtPolyRef, pathCount *int, maxPath int) DtStatus {
 	DtAssert(this.m_nav != nil)
 	DtAssert(this.m_nodePool != nil)
@@ -1022,8 +1045,15 @@ func (this *DtNavMeshQuery) FindPath(startRef, endRef DtPolyRef,
 	lastBestNodeCost := startNode.Total
 
 	outOfNodes := false
+	iter := 0
 
 	for !this.m_openList.Empty() {
+		// Give up with the best partial path when the context is done.
+		if ctx != nil && iter%DT_CONTEXT_CHECK_ITERS == 0 && ctx.Err() != nil {
+			break
+		}
+		iter++
+
 		// Remove node from open list and put it in closed list.
 		bestNode := this.m_openList.Pop()
 		bestNode.Flags &= ^DT_NODE_OPEN
@@ -1214,7 +1244,7 @@ func (this *DtNavMeshQuery) getPathToNode(endNode *DtNode, path []DtPolyRef, pat
 ///
 func (this *DtNavMeshQuery) InitSlicedFindPath(startRef, endRef DtPolyRef,
 	startPos, endPos []float32,
-	filter *DtQueryFilter, options DtFindPathOptions) DtStatus {
+	filter DtQueryFilterI, options DtFindPathOptions) DtStatus {
 	DtAssert(this.m_nav != nil)
 	DtAssert(this.m_nodePool != nil)
 	DtAssert(this.m_openList != nil)
@@ -1779,6 +1809,17 @@ func (this *DtNavMeshQuery) FindStraightPath(startPos, endPos []float32,
 	path []DtPolyRef, pathSize int,
 	straightPath []float32, straightPathFlags []DtStraightPathFlags, straightPathRefs []DtPolyRef,
 	straightPathCount *int, maxStraightPath int, options DtStraightPathOptions) DtStatus {
+	return this.findStraightPath(startPos, endPos, path, pathSize, 0,
+		straightPath, straightPathFlags, straightPathRefs,
+		straightPathCount, maxStraightPath, options)
+}
+
+/// Implements FindStraightPath, with the portals between ground polygons
+/// shrunk by @p radius. See FindStraightPathRadius.
+func (this *DtNavMeshQuery) findStraightPath(startPos, endPos []float32,
+	path []DtPolyRef, pathSize int, radius float32,
+	straightPath []float32, straightPathFlags []DtStraightPathFlags, straightPathRefs []DtPolyRef,
+	straightPathCount *int, maxStraightPath int, options DtStraightPathOptions) DtStatus {
 	DtAssert(this.m_nav != nil)
 
 	*straightPathCount = 0
@@ -1827,7 +1868,7 @@ func (this *DtNavMeshQuery) FindStraightPath(startPos, endPos []float32,
 			var toType DtPolyTypes
 
 			if i+1 < pathSize {
-				var fromType DtPolyTypes // fromType is ignored.
+				var fromType DtPolyTypes
 
 				// Next portal.
 				if DtStatusFailed(this.getPortalPoints(path[i], path[i+1], left[:], right[:], &fromType, &toType)) {
@@ -1859,6 +1900,11 @@ func (this *DtNavMeshQuery) FindStraightPath(startPos, endPos []float32,
 					}
 				}
 
+				// Keep the corners away from the walls.
+				if radius > 0 && fromType == DT_POLYTYPE_GROUND && toType == DT_POLYTYPE_GROUND {
+					dtShrinkPortal(left[:], right[:], radius)
+				}
+
 				// If starting really close the portal, advance.
 				if i == 0 {
 					var t float32
@@ -2033,7 +2079,7 @@ func (this *DtNavMeshQuery) FindStraightPath(startPos, endPos []float32,
 /// position.
 ///
 func (this *DtNavMeshQuery) MoveAlongSurface(startRef DtPolyRef, startPos, endPos []float32,
-	filter *DtQueryFilter,
+	filter DtQueryFilterI,
 	resultPos []float32, visited []DtPolyRef, visitedCount *int, maxVisitedSize int,
 	bHit *bool) DtStatus {
 	DtAssert(this.m_nav != nil)
@@ -2383,7 +2429,7 @@ func (this *DtNavMeshQuery) getEdgeMidPoint2(from DtPolyRef, fromPoly *DtPoly, f
 /// this method is meant for short distance checks.
 ///
 func (this *DtNavMeshQuery) Raycast(startRef DtPolyRef, startPos, endPos []float32,
-	filter *DtQueryFilter,
+	filter DtQueryFilterI,
 	t *float32, hitNormal []float32, path []DtPolyRef, pathCount *int, maxPath int) DtStatus {
 	var hit DtRaycastHit
 	hit.Path = path
@@ -2451,7 +2497,7 @@ func (this *DtNavMeshQuery) Raycast(startRef DtPolyRef, startPos, endPos []float
 /// this method is meant for short distance checks.
 ///
 func (this *DtNavMeshQuery) Raycast2(startRef DtPolyRef, startPos, endPos []float32,
-	filter *DtQueryFilter, options DtRaycastOptions,
+	filter DtQueryFilterI, options DtRaycastOptions,
 	hit *DtRaycastHit, prevRef DtPolyRef) DtStatus {
 	DtAssert(this.m_nav != nil)
 
@@ -2714,7 +2760,7 @@ func (this *DtNavMeshQuery) Raycast2(startRef DtPolyRef, startPos, endPos []floa
 /// filled to capacity.
 ///
 func (this *DtNavMeshQuery) FindPolysAroundCircle(startRef DtPolyRef, centerPos []float32, radius float32,
-	filter *DtQueryFilter,
+	filter DtQueryFilterI,
 	resultRef, resultParent []DtPolyRef, resultCost []float32,
 	resultCount *int, maxResult int) DtStatus {
 	DtAssert(this.m_nav != nil)
@@ -2888,7 +2934,7 @@ func (this *DtNavMeshQuery) FindPolysAroundCircle(startRef DtPolyRef, centerPos
 /// be filled to capacity.
 ///
 func (this *DtNavMeshQuery) FindPolysAroundShape(startRef DtPolyRef, verts []float32, nverts int,
-	filter *DtQueryFilter,
+	filter DtQueryFilterI,
 	resultRef, resultParent []DtPolyRef, resultCost []float32,
 	resultCount *int, maxResult int) DtStatus {
 	DtAssert(this.m_nav != nil)
@@ -3052,12 +3098,20 @@ func (this *DtNavMeshQuery) GetPathFromDijkstraSearch(endRef DtPolyRef, path []D
 	}
 	*pathCount = 0
 
-	var endNode [1]*DtNode
-	if this.m_nodePool.FindNodes(endRef, endNode[:], 1) != 1 ||
-		(endNode[0].Flags&DT_NODE_CLOSED) == 0 {
+	// Searches entering polygons from several tile sides have a node per
+	// side, use the cheapest.
+	var nodes [DT_MAX_STATES_PER_NODE]*DtNode
+	n := this.m_nodePool.FindNodes(endRef, nodes[:], uint32(DT_MAX_STATES_PER_NODE))
+	var endNode *DtNode
+	for i := uint32(0); i < n; i++ {
+		if (nodes[i].Flags&DT_NODE_CLOSED) != 0 && (endNode == nil || nodes[i].Total < endNode.Total) {
+			endNode = nodes[i]
+		}
+	}
+	if endNode == nil {
 		return DT_FAILURE | DT_INVALID_PARAM
 	}
-	return this.getPathToNode(endNode[0], path, pathCount, maxPath)
+	return this.getPathToNode(endNode, path, pathCount, maxPath)
 }
 
 /// Finds the non-overlapping navigation polygons in the local neighbourhood around the center position.
@@ -3094,7 +3148,7 @@ func (this *DtNavMeshQuery) GetPathFromDijkstraSearch(endRef DtPolyRef, path []D
 /// be filled to capacity.
 ///
 func (this *DtNavMeshQuery) FindLocalNeighbourhood(startRef DtPolyRef, centerPos []float32, radius float32,
-	filter *DtQueryFilter,
+	filter DtQueryFilterI,
 	resultRef, resultParent []DtPolyRef,
 	resultCount *int, maxResult int) DtStatus {
 	DtAssert(this.m_nav != nil)
@@ -3311,7 +3365,7 @@ func insertInterval(ints []dtSegInterval, nints *int, maxInts int,
 /// The @p segmentVerts and @p segmentRefs buffers should normally be sized for the
 /// maximum segments per polygon of the source navigation mesh.
 ///
-func (this *DtNavMeshQuery) GetPolyWallSegments(ref DtPolyRef, filter *DtQueryFilter,
+func (this *DtNavMeshQuery) GetPolyWallSegments(ref DtPolyRef, filter DtQueryFilterI,
 	segmentVerts []float32, segmentRefs []DtPolyRef, segmentCount *int,
 	maxSegments int) DtStatus {
 	DtAssert(this.m_nav != nil)
@@ -3454,7 +3508,7 @@ func (this *DtNavMeshQuery) GetPolyWallSegments(ref DtPolyRef, filter *DtQueryFi
 /// The normal will become unpredicable if @p hitDist is a very small number.
 ///
 func (this *DtNavMeshQuery) FindDistanceToWall(startRef DtPolyRef, centerPos []float32, maxRadius float32,
-	filter *DtQueryFilter,
+	filter DtQueryFilterI,
 	hitDist *float32, hitPos []float32, hitNormal []float32) DtStatus {
 	DtAssert(this.m_nav != nil)
 	DtAssert(this.m_nodePool != nil)
@@ -3629,7 +3683,7 @@ func (this *DtNavMeshQuery) FindDistanceToWall(startRef DtPolyRef, centerPos []f
 /// Returns true if the polygon reference is valid and passes the filter restrictions.
 ///  @param[in]		ref			The polygon reference to check.
 ///  @param[in]		filter		The filter to apply.
-func (this *DtNavMeshQuery) IsValidPolyRef(ref DtPolyRef, filter *DtQueryFilter) bool {
+func (this *DtNavMeshQuery) IsValidPolyRef(ref DtPolyRef, filter DtQueryFilterI) bool {
 	var tile *DtMeshTile
 	var poly *DtPoly
 	status := this.m_nav.GetTileAndPolyByRef(ref, &tile, &poly)
@@ -0,0 +1,80 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+/// Finds the straight path from the start to the end position within the
+/// polygon corridor, keeping the corners at least @p radius away from the
+/// walls.
+///  @param[in]		startPos			Path start position. [(x, y, z)]
+///  @param[in]		endPos				Path end position. [(x, y, z)]
+///  @param[in]		path				An array of polygon references that represent the path corridor.
+///  @param[in]		pathSize			The number of polygons in the @p path array.
+///  @param[in]		radius				The agent radius. [Limit: >= 0]
+///  @param[out]	straightPath		Points describing the straight path. [(x, y, z) * @p straightPathCount].
+///  @param[out]	straightPathFlags	Flags describing each point. (See: #dtStraightPathFlags) [opt]
+///  @param[out]	straightPathRefs	The reference id of the polygon that is being entered at each point. [opt]
+///  @param[out]	straightPathCount	The number of points in the straight path.
+///  @param[in]		maxStraightPath		The maximum number of points the straight path arrays can hold.  [Limit: > 0]
+///  @param[in]		options				Query options. (see: #dtStraightPathOptions)
+/// @returns The status flags for the query.
+/// @par
+///
+/// This is #FindStraightPath with every portal between two ground polygons
+/// shrunk by @p radius at both ends before string pulling, so the path turns
+/// around a corner at @p radius from it instead of touching it. Portals
+/// narrower than twice the radius collapse to their middle point. Portals of
+/// off-mesh connections are not shrunk.
+///
+/// Both ends of a portal are shrunk, even when one of them is not a wall
+/// corner, so the path may keep away from some vertices it did not need to.
+/// The start and end positions are not moved away from the walls.
+///
+/// With a zero radius the result is the one of #FindStraightPath.
+func (this *DtNavMeshQuery) FindStraightPathRadius(startPos, endPos []float32,
+	path []DtPolyRef, pathSize int, radius float32,
+	straightPath []float32, straightPathFlags []DtStraightPathFlags, straightPathRefs []DtPolyRef,
+	straightPathCount *int, maxStraightPath int, options DtStraightPathOptions) DtStatus {
+	if !(radius >= 0) { // Also rejects NaN.
+		*straightPathCount = 0
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	return this.findStraightPath(startPos, endPos, path, pathSize, radius,
+		straightPath, straightPathFlags, straightPathRefs,
+		straightPathCount, maxStraightPath, options)
+}
+
+/// Moves both ends of the portal (@p left, @p right) toward each other by
+/// @p radius on the xz-plane, or to its middle when it is narrower than
+/// twice the radius.
+func dtShrinkPortal(left, right []float32, radius float32) {
+	width := DtVdist2D(left, right)
+	if width <= 2*radius {
+		var mid [3]float32
+		DtVlerp(mid[:], left, right, 0.5)
+		DtVcopy(left, mid[:])
+		DtVcopy(right, mid[:])
+		return
+	}
+	t := radius / width
+	var l, r [3]float32
+	DtVlerp(l[:], left, right, t)
+	DtVlerp(r[:], right, left, t)
+	DtVcopy(left, l[:])
+	DtVcopy(right, r[:])
+}
@@ -0,0 +1,93 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+import (
+	"context"
+)
+
+/// The number of node expansions between two checks of the context in
+/// #FindPathContext.
+const DT_CONTEXT_CHECK_ITERS int = 64
+
+/// Updates an in-progress sliced path query until it completes, @p maxIter
+/// iterations were done, or @p ctx is done.
+///  @param[in]		ctx			The context checked for cancellation.
+///  @param[in]		maxIter		The maximum number of iterations to perform.
+///  @param[in]		checkIters	The number of iterations between two checks of @p ctx. [Limit: > 0]
+///  @param[out]	doneIters	The actual number of iterations completed. [opt]
+/// @returns The status flags for the query.
+/// @par
+///
+/// @p ctx is checked before the first iteration and then every @p checkIters
+/// iterations. When it is done the query stays in progress: call
+/// #FinalizeSlicedFindPath to get the path to the node closest to the end,
+/// flagged #DT_PARTIAL_RESULT, and ctx.Err() to tell why.
+func (this *DtNavMeshQuery) UpdateSlicedFindPathContext(ctx context.Context, maxIter, checkIters int, doneIters *int) DtStatus {
+	if checkIters <= 0 {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	total := 0
+	status := this.m_query.status
+	for DtStatusInProgress(status) && total < maxIter {
+		if ctx.Err() != nil {
+			break
+		}
+		n := checkIters
+		if maxIter-total < n {
+			n = maxIter - total
+		}
+		var done int
+		status = this.UpdateSlicedFindPath(n, &done)
+		total += done
+		if done < n {
+			break
+		}
+	}
+	if doneIters != nil {
+		*doneIters = total
+	}
+	return status
+}
+
+/// Finds a path from the start polygon to the end polygon, like #FindPath,
+/// giving up when @p ctx is done.
+///  @param[in]		ctx			The context checked for cancellation.
+///  @param[in]		startRef	The refrence id of the start polygon.
+///  @param[in]		endRef		The reference id of the end polygon.
+///  @param[in]		startPos	A position within the start polygon. [(x, y, z)]
+///  @param[in]		endPos		A position within the end polygon. [(x, y, z)]
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[out]	path		An ordered list of polygon references representing the path. (Start to end.)
+///  							[(polyRef) * @p pathCount]
+///  @param[out]	pathCount	The number of polygons returned in the @p path array.
+///  @param[in]		maxPath		The maximum number of polygons the @p path array can hold. [Limit: >= 1]
+/// @returns The status flags for the query.
+/// @par
+///
+/// @p ctx is checked every #DT_CONTEXT_CHECK_ITERS node expansions. When it
+/// is done before the end is reached, the path to the polygon closest to the
+/// end found so far is returned with #DT_PARTIAL_RESULT, as when the search
+/// runs out of nodes. Otherwise the result is the same as #FindPath.
+func (this *DtNavMeshQuery) FindPathContext(ctx context.Context, startRef, endRef DtPolyRef,
+	startPos, endPos []float32,
+	filter DtQueryFilterI,
+	path []DtPolyRef, pathCount *int, maxPath int) DtStatus {
+	return this.findPath(ctx, startRef, endRef, startPos, endPos, filter, path, pathCount, maxPath)
+}
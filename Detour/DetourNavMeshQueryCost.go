@@ -0,0 +1,253 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+import (
+	"math"
+)
+
+/// Gets the cost of the path to a polygon found by the last search.
+///  @param[in]		ref		The reference id of the polygon. Usually the last
+///  						polygon of the path.
+///  @param[out]	cost	The accumulated cost from the start position.
+/// @returns The status flags for the query.
+/// @par
+///
+/// Reads the cost of the node of @p ref left in the node pool by #FindPath,
+/// #FindPathContext, a sliced path query or #FindPathCostsToMany, so the path
+/// does not need to be walked again.
+///
+/// When @p ref is the end polygon of a complete #FindPath, the cost includes
+/// the segment to the end position, and equals the total of its node. For
+/// other polygons it is the cost to the node position, on the edge through
+/// which the polygon was entered. When the polygon was reached in several
+/// states, the cheapest is returned.
+///
+/// Fails with #DT_INVALID_PARAM when @p ref was not reached by the search.
+func (this *DtNavMeshQuery) GetPathCost(ref DtPolyRef, cost *float32) DtStatus {
+	DtAssert(this.m_nodePool != nil)
+	if ref == 0 || cost == nil {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	var nodes [DT_MAX_STATES_PER_NODE]*DtNode
+	n := this.m_nodePool.FindNodes(ref, nodes[:], uint32(DT_MAX_STATES_PER_NODE))
+	best := float32(math.MaxFloat32)
+	for i := uint32(0); i < n; i++ {
+		if nodes[i].Flags&(DT_NODE_OPEN|DT_NODE_CLOSED) == 0 {
+			continue
+		}
+		if nodes[i].Cost < best {
+			best = nodes[i].Cost
+		}
+	}
+	if best == math.MaxFloat32 {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	*cost = best
+	return DT_SUCCESS
+}
+
+/// Calculates the cost of the paths from the start position to many targets
+/// with a single Dijkstra search.
+///  @param[in]		startRef	The reference id of the start polygon.
+///  @param[in]		startPos	A position within the start polygon. [(x, y, z)]
+///  @param[in]		targetRefs	The reference ids of the target polygons. [(polyRef) * @p targetCount]
+///  @param[in]		targetPos	A position within each target polygon. [(x, y, z) * @p targetCount]
+///  @param[in]		targetCount	The number of targets.
+///  @param[in]		maxCost		The search does not expand polygons costing more than this.
+///  							[Limit: > 0, FLT_MAX for no limit]
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[out]	costs		The path cost to each target, FLT_MAX for targets which were
+///  							not reached. [(cost) * @p targetCount]
+/// @returns The status flags for the query.
+/// @par
+///
+/// The costs are computed the way #FindPath computes them, so with the
+/// default area costs they are path lengths through the polygon edge
+/// midpoints. The search stops once every target is reached at its lowest
+/// cost, so one call is much cheaper than a #FindPath per target.
+///
+/// The path to a reached target can be read afterwards with
+/// #GetPathFromDijkstraSearch.
+///
+/// #DT_OUT_OF_NODES is set when the node pool was exhausted before every
+/// reachable target was found.
+func (this *DtNavMeshQuery) FindPathCostsToMany(startRef DtPolyRef, startPos []float32,
+	targetRefs []DtPolyRef, targetPos []float32, targetCount int, maxCost float32,
+	filter DtQueryFilterI, costs []float32) DtStatus {
+	DtAssert(this.m_nav != nil)
+	DtAssert(this.m_nodePool != nil)
+	DtAssert(this.m_openList != nil)
+
+	// Validate input
+	if !this.m_nav.IsValidPolyRef(startRef) || startPos == nil || filter == nil || targetCount < 0 ||
+		len(targetRefs) < targetCount || len(targetPos) < targetCount*3 || len(costs) < targetCount || !(maxCost > 0) {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+
+	// Targets of each polygon.
+	targets := make(map[DtPolyRef][]int, targetCount)
+	remaining := 0
+	for i := 0; i < targetCount; i++ {
+		costs[i] = math.MaxFloat32
+		if targetRefs[i] != 0 {
+			targets[targetRefs[i]] = append(targets[targetRefs[i]], i)
+			remaining++
+		}
+	}
+
+	this.m_nodePool.Clear()
+	this.m_openList.Clear()
+
+	startNode := this.m_nodePool.GetNode(startRef, 0)
+	DtVcopy(startNode.Pos[:], startPos)
+	startNode.Pidx = 0
+	startNode.Cost = 0
+	startNode.Total = 0
+	startNode.Id = startRef
+	startNode.Flags = DT_NODE_OPEN
+	this.m_openList.Push(startNode)
+
+	status := DT_SUCCESS
+
+	// Like #FindPath, a polygon gets a node per tile side it is entered
+	// from, and closed nodes are opened again when reached more cheaply.
+	// The search ends once every target was reached, and no open node can
+	// lead to a target more cheaply.
+	reached := float32(0)
+	for !this.m_openList.Empty() {
+		if remaining == 0 && this.m_openList.Top().Total >= reached {
+			break
+		}
+		bestNode := this.m_openList.Pop()
+		bestNode.Flags &= ^DT_NODE_OPEN
+		bestNode.Flags |= DT_NODE_CLOSED
+
+		// Get poly and tile.
+		// The API input has been cheked already, skip checking internal data.
+		bestRef := bestNode.Id
+		var bestTile *DtMeshTile
+		var bestPoly *DtPoly
+		this.m_nav.GetTileAndPolyByRefUnsafe(bestRef, &bestTile, &bestPoly)
+
+		// Get parent poly and tile.
+		var parentRef DtPolyRef
+		var parentTile *DtMeshTile
+		var parentPoly *DtPoly
+		if bestNode.Pidx != 0 {
+			parentRef = this.m_nodePool.GetNodeAtIdx(bestNode.Pidx).Id
+		}
+		if parentRef != 0 {
+			this.m_nav.GetTileAndPolyByRefUnsafe(parentRef, &parentTile, &parentPoly)
+		}
+
+		// Reached targets, add the way to the target position.
+		if indices, ok := targets[bestRef]; ok {
+			for _, i := range indices {
+				endCost := filter.GetCost(bestNode.Pos[:], targetPos[i*3:i*3+3],
+					parentRef, parentTile, parentPoly,
+					bestRef, bestTile, bestPoly,
+					0, nil, nil)
+				if costs[i] == math.MaxFloat32 {
+					remaining--
+				}
+				if cost := bestNode.Total + endCost; cost < costs[i] {
+					costs[i] = cost
+				}
+				if costs[i] > reached {
+					reached = costs[i]
+				}
+			}
+		}
+
+		for i := bestPoly.FirstLink; i != DT_NULL_LINK; i = bestTile.Links[i].Next {
+			neighbourRef := bestTile.Links[i].Ref
+			// Skip invalid neighbours and do not follow back to parent.
+			if neighbourRef == 0 || neighbourRef == parentRef {
+				continue
+			}
+			// Expand to neighbour
+			var neighbourTile *DtMeshTile
+			var neighbourPoly *DtPoly
+			this.m_nav.GetTileAndPolyByRefUnsafe(neighbourRef, &neighbourTile, &neighbourPoly)
+
+			// Do not advance if the polygon is excluded by the filter.
+			if !filter.PassFilter(neighbourRef, neighbourTile, neighbourPoly) {
+				continue
+			}
+			// deal explicitly with crossing tile boundaries
+			var crossSide uint8
+			if bestTile.Links[i].Side != 0xff {
+				crossSide = (bestTile.Links[i].Side >> 1)
+			}
+			neighbourNode := this.m_nodePool.GetNode(neighbourRef, crossSide)
+			if neighbourNode == nil {
+				status |= DT_OUT_OF_NODES
+				continue
+			}
+			// If the node is visited the first time, calculate node position.
+			if neighbourNode.Flags == 0 {
+				this.getEdgeMidPoint2(bestRef, bestPoly, bestTile,
+					neighbourRef, neighbourPoly, neighbourTile,
+					neighbourNode.Pos[:])
+			}
+			cost := filter.GetCost(
+				bestNode.Pos[:], neighbourNode.Pos[:],
+				parentRef, parentTile, parentPoly,
+				bestRef, bestTile, bestPoly,
+				neighbourRef, neighbourTile, neighbourPoly)
+
+			total := bestNode.Total + cost
+			if total > maxCost {
+				continue
+			}
+
+			// The node is already visited, and the new result is worse, skip.
+			if (neighbourNode.Flags&(DT_NODE_OPEN|DT_NODE_CLOSED)) != 0 && total >= neighbourNode.Total {
+				continue
+			}
+			neighbourNode.Id = neighbourRef
+			neighbourNode.Pidx = this.m_nodePool.GetNodeIdx(bestNode)
+			neighbourNode.Flags = (neighbourNode.Flags & ^DT_NODE_CLOSED)
+			neighbourNode.Cost = total
+			neighbourNode.Total = total
+
+			if (neighbourNode.Flags & DT_NODE_OPEN) != 0 {
+				this.m_openList.Modify(neighbourNode)
+			} else {
+				neighbourNode.Flags |= DT_NODE_OPEN
+				this.m_openList.Push(neighbourNode)
+			}
+		}
+	}
+
+	return status
+}
+
+/// Returns the length of a straight path on the xz-plane and in height.
+///  @param[in]		straightPath		Points describing the straight path. [(x, y, z) * @p straightPathCount]
+///  @param[in]		straightPathCount	The number of points in the straight path.
+/// @returns The sum of the distances between consecutive points.
+func DtStraightPathLength(straightPath []float32, straightPathCount int) float32 {
+	var length float32
+	for i := 1; i < straightPathCount; i++ {
+		length += DtVdist(straightPath[(i-1)*3:], straightPath[i*3:])
+	}
+	return length
+}
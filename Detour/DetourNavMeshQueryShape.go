@@ -0,0 +1,258 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+import "math"
+
+/// Finds the polygons crossed by a segment on the xz-plane.
+///  @param[in]		startPos	The start position of the segment. [(x, y, z)]
+///  @param[in]		endPos		The end position of the segment. [(x, y, z)]
+///  @param[in]		halfHeight	The vertical distance within which polygons are crossed. [Limit: >= 0]
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[out]	polys		The reference ids of the polygons crossed.
+///  @param[out]	polyCount	The number of polygons in the search result.
+///  @param[in]		maxPolys	The maximum number of polygons the search result can hold.
+/// @returns The status flags for the query.
+/// @par
+///
+/// Unlike #Raycast the segment does not follow the polygon links, so it
+/// finds polygons across disconnected parts of the mesh, such as the floors
+/// on both sides of a gap. A polygon is crossed when the segment crosses it on
+/// the xz-plane, and the part of the segment over it is within @p halfHeight
+/// of the height range of its vertices.
+///
+/// Off-mesh connections are never returned. The results are unordered, and
+/// filled as for #QueryPolygons when @p polys is too small.
+func (this *DtNavMeshQuery) QueryPolygonsSegment(startPos, endPos []float32, halfHeight float32,
+	filter DtQueryFilterI, polys []DtPolyRef, polyCount *int, maxPolys int) DtStatus {
+	if len(startPos) < 3 || len(endPos) < 3 || !(halfHeight >= 0) {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	var bmin, bmax [3]float32
+	DtVcopy(bmin[:], startPos)
+	DtVcopy(bmax[:], startPos)
+	DtVmin(bmin[:], endPos)
+	DtVmax(bmax[:], endPos)
+	bmin[1] -= halfHeight
+	bmax[1] += halfHeight
+
+	overlap := func(verts []float32, nverts int, ymin, ymax float32) bool {
+		var tmin, tmax float32
+		var segMin, segMax int
+		if !DtIntersectSegmentPoly2D(startPos, endPos, verts, nverts, &tmin, &tmax, &segMin, &segMax) {
+			return false
+		}
+		y0 := startPos[1] + (endPos[1]-startPos[1])*tmin
+		y1 := startPos[1] + (endPos[1]-startPos[1])*tmax
+		return overlapRange(DtMinFloat32(y0, y1)-halfHeight, DtMaxFloat32(y0, y1)+halfHeight, ymin, ymax, 0)
+	}
+	return this.queryPolygonsInShape(bmin[:], bmax[:], overlap, filter, polys, polyCount, maxPolys)
+}
+
+/// Finds the polygons overlapping a box rotated around the y-axis.
+///  @param[in]		center		The center of the box. [(x, y, z)]
+///  @param[in]		halfExtents	The half size of the box along its own axes. [(x, y, z)]
+///  @param[in]		yaw			The rotation of the box around the y-axis, in radians.
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[out]	polys		The reference ids of the polygons overlapping the box.
+///  @param[out]	polyCount	The number of polygons in the search result.
+///  @param[in]		maxPolys	The maximum number of polygons the search result can hold.
+/// @returns The status flags for the query.
+/// @par
+///
+/// The x-axis of the box points along (cos(yaw), 0, sin(yaw)) and its z-axis
+/// along (-sin(yaw), 0, cos(yaw)). A polygon overlaps the box when it
+/// overlaps its rectangle on the xz-plane, and the height range of its
+/// vertices overlaps the one of the box. With a zero @p yaw the box is the
+/// one of #QueryPolygons, but polygons are tested by their outline instead of
+/// their bounds.
+///
+/// Off-mesh connections are never returned. The results are unordered, and
+/// filled as for #QueryPolygons when @p polys is too small.
+func (this *DtNavMeshQuery) QueryPolygonsBox(center, halfExtents []float32, yaw float32,
+	filter DtQueryFilterI, polys []DtPolyRef, polyCount *int, maxPolys int) DtStatus {
+	if len(center) < 3 || len(halfExtents) < 3 ||
+		!(halfExtents[0] >= 0) || !(halfExtents[1] >= 0) || !(halfExtents[2] >= 0) {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	c := float32(math.Cos(float64(yaw)))
+	s := float32(math.Sin(float64(yaw)))
+	ux, uz := c*halfExtents[0], s*halfExtents[0]
+	vx, vz := -s*halfExtents[2], c*halfExtents[2]
+	rect := [12]float32{
+		center[0] - ux - vx, center[1], center[2] - uz - vz,
+		center[0] + ux - vx, center[1], center[2] + uz - vz,
+		center[0] + ux + vx, center[1], center[2] + uz + vz,
+		center[0] - ux + vx, center[1], center[2] - uz + vz,
+	}
+	var bmin, bmax [3]float32
+	DtVcopy(bmin[:], rect[0:])
+	DtVcopy(bmax[:], rect[0:])
+	for i := 1; i < 4; i++ {
+		DtVmin(bmin[:], rect[i*3:])
+		DtVmax(bmax[:], rect[i*3:])
+	}
+	bmin[1] = center[1] - halfExtents[1]
+	bmax[1] = center[1] + halfExtents[1]
+
+	overlap := func(verts []float32, nverts int, ymin, ymax float32) bool {
+		return overlapRange(bmin[1], bmax[1], ymin, ymax, 0) &&
+			DtOverlapPolyPoly2D(rect[:], 4, verts, nverts)
+	}
+	return this.queryPolygonsInShape(bmin[:], bmax[:], overlap, filter, polys, polyCount, maxPolys)
+}
+
+/// Finds the polygons overlapping a sphere.
+///  @param[in]		center		The center of the sphere. [(x, y, z)]
+///  @param[in]		radius		The radius of the sphere. [Limit: >= 0]
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[out]	polys		The reference ids of the polygons overlapping the sphere.
+///  @param[out]	polyCount	The number of polygons in the search result.
+///  @param[in]		maxPolys	The maximum number of polygons the search result can hold.
+/// @returns The status flags for the query.
+/// @par
+///
+/// A polygon overlaps the sphere when the distance from the center to the
+/// polygon on the xz-plane, combined with the vertical distance from the
+/// center to the height range of its vertices, is within @p radius. For
+/// sloped polygons this may include polygons slightly out of the sphere.
+///
+/// Off-mesh connections are never returned. The results are unordered, and
+/// filled as for #QueryPolygons when @p polys is too small.
+func (this *DtNavMeshQuery) QueryPolygonsSphere(center []float32, radius float32,
+	filter DtQueryFilterI, polys []DtPolyRef, polyCount *int, maxPolys int) DtStatus {
+	if len(center) < 3 || !(radius >= 0) {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	var bmin, bmax [3]float32
+	for i := 0; i < 3; i++ {
+		bmin[i] = center[i] - radius
+		bmax[i] = center[i] + radius
+	}
+
+	overlap := func(verts []float32, nverts int, ymin, ymax float32) bool {
+		dy := DtMaxFloat32(DtMaxFloat32(ymin-center[1], center[1]-ymax), 0)
+		if dy > radius {
+			return false
+		}
+		dsqr := float32(0)
+		if !DtPointInPolygon(center, verts, nverts) {
+			dsqr = math.MaxFloat32
+			for i, j := 0, nverts-1; i < nverts; j, i = i, i+1 {
+				var t float32
+				dsqr = DtMinFloat32(dsqr, DtDistancePtSegSqr2D(center, verts[j*3:], verts[i*3:], &t))
+			}
+		}
+		return dsqr+dy*dy <= radius*radius
+	}
+	return this.queryPolygonsInShape(bmin[:], bmax[:], overlap, filter, polys, polyCount, maxPolys)
+}
+
+/// Finds the polygons overlapping a convex polygon on the xz-plane.
+///  @param[in]		verts		The vertices of the convex polygon. [(x, y, z) * @p nverts]
+///  @param[in]		nverts		The number of vertices. [Limit: >= 3]
+///  @param[in]		halfHeight	The vertical distance, below the lowest and above the highest
+///  							vertex, within which polygons overlap. [Limit: >= 0]
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[out]	polys		The reference ids of the polygons overlapping the shape.
+///  @param[out]	polyCount	The number of polygons in the search result.
+///  @param[in]		maxPolys	The maximum number of polygons the search result can hold.
+/// @returns The status flags for the query.
+/// @par
+///
+/// Unlike #FindPolysAroundShape no start polygon is needed, and polygons are
+/// found across disconnected parts of the mesh. A polygon overlaps the shape
+/// when it overlaps it on the xz-plane, and the height range of its vertices
+/// overlaps the one of the shape vertices extended by @p halfHeight.
+///
+/// Off-mesh connections are never returned. The results are unordered, and
+/// filled as for #QueryPolygons when @p polys is too small.
+func (this *DtNavMeshQuery) QueryPolygonsConvex(verts []float32, nverts int, halfHeight float32,
+	filter DtQueryFilterI, polys []DtPolyRef, polyCount *int, maxPolys int) DtStatus {
+	if nverts < 3 || len(verts) < nverts*3 || !(halfHeight >= 0) {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	var bmin, bmax [3]float32
+	DtVcopy(bmin[:], verts)
+	DtVcopy(bmax[:], verts)
+	for i := 1; i < nverts; i++ {
+		DtVmin(bmin[:], verts[i*3:])
+		DtVmax(bmax[:], verts[i*3:])
+	}
+	bmin[1] -= halfHeight
+	bmax[1] += halfHeight
+
+	overlap := func(pverts []float32, npverts int, ymin, ymax float32) bool {
+		return overlapRange(bmin[1], bmax[1], ymin, ymax, 0) &&
+			DtOverlapPolyPoly2D(verts, nverts, pverts, npverts)
+	}
+	return this.queryPolygonsInShape(bmin[:], bmax[:], overlap, filter, polys, polyCount, maxPolys)
+}
+
+// queryPolygonsInShape collects the polygons whose bounds overlap the box
+// from bmin to bmax, found through the BV trees, and which pass the exact
+// overlap test of a shape.
+func (this *DtNavMeshQuery) queryPolygonsInShape(bmin, bmax []float32,
+	overlap func(verts []float32, nverts int, ymin, ymax float32) bool,
+	filter DtQueryFilterI, polys []DtPolyRef, polyCount *int, maxPolys int) DtStatus {
+	if polys == nil || polyCount == nil || maxPolys < 0 || filter == nil {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	var center, halfExtents [3]float32
+	for i := 0; i < 3; i++ {
+		center[i] = (bmin[i] + bmax[i]) * 0.5
+		halfExtents[i] = (bmax[i] - bmin[i]) * 0.5
+	}
+	query := dtShapePolysQuery{m_overlap: overlap}
+	query.constructor(polys, maxPolys)
+
+	status := this.QueryPolygons2(center[:], halfExtents[:], filter, &query)
+	if DtStatusFailed(status) {
+		return status
+	}
+	*polyCount = query.numCollected()
+	if query.overflowed() {
+		return DT_SUCCESS | DT_BUFFER_TOO_SMALL
+	}
+	return DT_SUCCESS
+}
+
+// dtShapePolysQuery collects the polygons of the batches which overlap a shape.
+type dtShapePolysQuery struct {
+	dtCollectPolysQuery
+	m_overlap func(verts []float32, nverts int, ymin, ymax float32) bool
+}
+
+func (this *dtShapePolysQuery) Process(tile *DtMeshTile, polys []*DtPoly, refs []DtPolyRef, count int) {
+	var verts [DT_VERTS_PER_POLYGON * 3]float32
+	for i := 0; i < count; i++ {
+		poly := polys[i]
+		nverts := int(poly.VertCount)
+		ymin := float32(math.MaxFloat32)
+		ymax := float32(-math.MaxFloat32)
+		for j := 0; j < nverts; j++ {
+			DtVcopy(verts[j*3:], tile.Verts[int(poly.Verts[j])*3:])
+			ymin = DtMinFloat32(ymin, verts[j*3+1])
+			ymax = DtMaxFloat32(ymax, verts[j*3+1])
+		}
+		if this.m_overlap(verts[:], nverts, ymin, ymax) {
+			this.dtCollectPolysQuery.Process(tile, polys[i:i+1], refs[i:i+1], 1)
+		}
+	}
+}
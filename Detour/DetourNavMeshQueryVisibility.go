@@ -0,0 +1,298 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+import (
+	"math"
+	"sort"
+)
+
+/// The number of segments the circle bounding a visibility polygon is
+/// approximated with, where no wall is in view.
+/// @see dtNavMeshQuery::findVisibilityPolygon
+const DT_VISIBILITY_ARC_SEGMENTS = 32
+
+/// Computes the visibility polygon of a position on the xz-plane: the part
+/// of the navigation mesh within a radius that can be seen from it, bounded
+/// by the walls of the mesh.
+///  @param[in]		startRef	The reference id of the polygon containing @p centerPos.
+///  @param[in]		centerPos	The position to look from. [(x, y, z)]
+///  @param[in]		radius		The view distance. [Limit: > 0]
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[out]	verts		The vertices of the visibility polygon, in the winding order of
+///  							the navigation mesh polygons. [(x, y, z) * @p vertCount]
+///  @param[out]	vertCount	The number of vertices of the visibility polygon.
+///  @param[in]		maxVerts	The maximum number of vertices the @p verts array can hold.
+/// @returns The status flags for the query.
+/// @par
+///
+/// The walls are the segments of #GetPolyWallSegments of the polygons found
+/// by #FindPolysAroundCircle, so polygons excluded by the filter block the
+/// view like walls do. A ray is cast against the walls towards both sides of
+/// every wall end, and along #DT_VISIBILITY_ARC_SEGMENTS directions around
+/// the circle, and the nearest hits form the polygon. The polygon is not
+/// convex in general, but it is star-shaped around @p centerPos.
+///
+/// The test is done on the xz-plane, like #Raycast. The height of a vertex is
+/// the one of the wall it lies on, or the one of @p centerPos on the circle.
+///
+/// #DT_OUT_OF_NODES or #DT_BUFFER_TOO_SMALL are set when the search for the
+/// polygons around the center was cut short, so some walls may be missing;
+/// #DT_BUFFER_TOO_SMALL is also set when @p verts is too small, and then the
+/// polygon is truncated.
+func (this *DtNavMeshQuery) FindVisibilityPolygon(startRef DtPolyRef, centerPos []float32, radius float32,
+	filter DtQueryFilterI, verts []float32, vertCount *int, maxVerts int) DtStatus {
+	DtAssert(this.m_nav != nil)
+	DtAssert(this.m_nodePool != nil)
+
+	if vertCount == nil {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	*vertCount = 0
+	if !this.m_nav.IsValidPolyRef(startRef) || len(centerPos) < 3 || !(radius > 0) ||
+		filter == nil || maxVerts < 0 || len(verts) < maxVerts*3 {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+
+	walls, status := this.collectWalls(startRef, centerPos, radius, filter)
+	if DtStatusFailed(status) {
+		return status
+	}
+
+	// Directions to cast rays along: both sides of every wall end and of
+	// every place a wall leaves the circle, and around the circle.
+	const ANGLE_EPS = 1e-4
+	angles := make([]float64, 0, DT_VISIBILITY_ARC_SEGMENTS+len(walls)*3)
+	for i := 0; i < DT_VISIBILITY_ARC_SEGMENTS; i++ {
+		angles = append(angles, 2*math.Pi*float64(i)/DT_VISIBILITY_ARC_SEGMENTS)
+	}
+	addAngle := func(p []float32) {
+		a := math.Atan2(float64(p[2]-centerPos[2]), float64(p[0]-centerPos[0]))
+		angles = append(angles, a-ANGLE_EPS, a, a+ANGLE_EPS)
+	}
+	rsqr := radius * radius
+	for i := 0; i < len(walls); i += 6 {
+		wa, wb := walls[i:i+3], walls[i+3:i+6]
+		if dtDistSqr2D(centerPos, wa) < rsqr {
+			addAngle(wa)
+		}
+		if dtDistSqr2D(centerPos, wb) < rsqr {
+			addAngle(wb)
+		}
+		var cross [6]float32
+		for j, n := 0, dtSegCircle2D(wa, wb, centerPos, radius, cross[:]); j < n; j++ {
+			addAngle(cross[j*3:])
+		}
+	}
+	for i := range angles {
+		angles[i] = math.Mod(angles[i]+4*math.Pi, 2*math.Pi)
+	}
+	sort.Float64s(angles)
+
+	// Nearest wall hit along each direction.
+	points := make([]float32, 0, len(angles)*3)
+	for i, a := range angles {
+		if i > 0 && a-angles[i-1] < ANGLE_EPS*0.1 {
+			continue
+		}
+		end := [3]float32{
+			centerPos[0] + radius*float32(math.Cos(a)),
+			centerPos[1],
+			centerPos[2] + radius*float32(math.Sin(a)),
+		}
+		hit := [3]float32{end[0], end[1], end[2]}
+		best := float32(1)
+		for j := 0; j < len(walls); j += 6 {
+			var s, t float32
+			if !DtIntersectSegSeg2D(centerPos, end[:], walls[j:j+3], walls[j+3:j+6], &s, &t) {
+				continue
+			}
+			if s >= 0 && s < best && t >= 0 && t <= 1 {
+				best = s
+				DtVlerp(hit[:], centerPos, end[:], s)
+				hit[1] = walls[j+1] + (walls[j+4]-walls[j+1])*t
+			}
+		}
+		points = append(points, hit[:]...)
+	}
+	points = dtRemoveCollinear2D(points)
+	// The angles go the other way around than the polygons of the mesh.
+	for i, j := 0, len(points)/3-1; i < j; i, j = i+1, j-1 {
+		for k := 0; k < 3; k++ {
+			points[i*3+k], points[j*3+k] = points[j*3+k], points[i*3+k]
+		}
+	}
+
+	n := len(points) / 3
+	if n > maxVerts {
+		n = maxVerts
+		status |= DT_BUFFER_TOO_SMALL
+	}
+	copy(verts, points[:n*3])
+	*vertCount = n
+	return status
+}
+
+/// Checks the line of sight from a position to many targets.
+///  @param[in]		startRef	The reference id of the polygon containing @p startPos.
+///  @param[in]		startPos	The position to look from. [(x, y, z)]
+///  @param[in]		targetPos	The positions of the targets. [(x, y, z) * @p targetCount]
+///  @param[in]		targetCount	The number of targets.
+///  @param[in]		maxDistance	Targets farther than this are not visible.
+///  							[Limit: > 0, FLT_MAX for no limit]
+///  @param[in]		filter		The polygon filter to apply to the query.
+///  @param[out]	visible		Whether each target is visible. [(visible) * @p targetCount]
+/// @returns The status flags for the query.
+/// @par
+///
+/// A target is visible when a #Raycast from the start position reaches it
+/// without hitting a wall. As with #Raycast the test is on the xz-plane along
+/// the surface of the mesh, so the heights of the targets are not checked.
+///
+/// The targets are checked one after the other, without allocating; targets
+/// out of @p maxDistance are rejected without casting a ray.
+func (this *DtNavMeshQuery) CheckLineOfSight(startRef DtPolyRef, startPos []float32,
+	targetPos []float32, targetCount int, maxDistance float32,
+	filter DtQueryFilterI, visible []bool) DtStatus {
+	DtAssert(this.m_nav != nil)
+
+	if !this.m_nav.IsValidPolyRef(startRef) || len(startPos) < 3 || targetCount < 0 ||
+		len(targetPos) < targetCount*3 || !(maxDistance > 0) || filter == nil || len(visible) < targetCount {
+		return DT_FAILURE | DT_INVALID_PARAM
+	}
+	maxDistSqr := maxDistance * maxDistance
+	if maxDistance == math.MaxFloat32 {
+		maxDistSqr = math.MaxFloat32
+	}
+	var hit DtRaycastHit
+	for i := 0; i < targetCount; i++ {
+		target := targetPos[i*3 : i*3+3]
+		visible[i] = false
+		if DtVdistSqr(startPos, target) > maxDistSqr {
+			continue
+		}
+		status := this.Raycast2(startRef, startPos, target, filter, 0, &hit, 0)
+		if DtStatusFailed(status) {
+			return status
+		}
+		visible[i] = hit.T == math.MaxFloat32
+	}
+	return DT_SUCCESS
+}
+
+// collectWalls returns the wall segments of the polygons around a circle,
+// as pairs of end points, keeping those which enter the circle.
+func (this *DtNavMeshQuery) collectWalls(startRef DtPolyRef, centerPos []float32, radius float32,
+	filter DtQueryFilterI) ([]float32, DtStatus) {
+	refs := make([]DtPolyRef, this.m_nodePool.GetMaxNodes())
+	var count int
+	status := this.FindPolysAroundCircle(startRef, centerPos, radius, filter, refs, nil, nil, &count, len(refs))
+	if DtStatusFailed(status) {
+		return nil, status
+	}
+
+	const MAX_SEGS = 64
+	var segs [MAX_SEGS * 6]float32
+	var walls []float32
+	rsqr := radius * radius
+	for _, ref := range refs[:count] {
+		var tile *DtMeshTile
+		var poly *DtPoly
+		this.m_nav.GetTileAndPolyByRefUnsafe(ref, &tile, &poly)
+		if poly.GetType() == DT_POLYTYPE_OFFMESH_CONNECTION {
+			continue
+		}
+		var nsegs int
+		segStatus := this.GetPolyWallSegments(ref, filter, segs[:], nil, &nsegs, MAX_SEGS)
+		if DtStatusFailed(segStatus) {
+			return nil, segStatus
+		}
+		status |= segStatus & DT_STATUS_DETAIL_MASK
+		for i := 0; i < nsegs; i++ {
+			seg := segs[i*6 : i*6+6]
+			var t float32
+			if DtDistancePtSegSqr2D(centerPos, seg[0:3], seg[3:6], &t) < rsqr {
+				walls = append(walls, seg...)
+			}
+		}
+	}
+	return walls, status
+}
+
+func dtDistSqr2D(a, b []float32) float32 {
+	dx := b[0] - a[0]
+	dz := b[2] - a[2]
+	return dx*dx + dz*dz
+}
+
+// dtSegCircle2D stores the points where segment a-b crosses the circle on the
+// xz-plane, and returns their number.
+func dtSegCircle2D(a, b, center []float32, radius float32, out []float32) int {
+	dx := b[0] - a[0]
+	dz := b[2] - a[2]
+	fx := a[0] - center[0]
+	fz := a[2] - center[2]
+	qa := dx*dx + dz*dz
+	qb := 2 * (fx*dx + fz*dz)
+	qc := fx*fx + fz*fz - radius*radius
+	disc := qb*qb - 4*qa*qc
+	if qa < 1e-12 || disc < 0 {
+		return 0
+	}
+	sq := float32(math.Sqrt(float64(disc)))
+	n := 0
+	for _, t := range [2]float32{(-qb - sq) / (2 * qa), (-qb + sq) / (2 * qa)} {
+		if t >= 0 && t <= 1 {
+			DtVlerp(out[n*3:], a, b, t)
+			n++
+		}
+	}
+	return n
+}
+
+// dtRemoveCollinear2D removes the vertices of a closed polygon which lie on
+// the line through their neighbours.
+func dtRemoveCollinear2D(points []float32) []float32 {
+	const AREA_EPS = 1e-6
+	n := len(points) / 3
+	if n <= 3 {
+		return points
+	}
+	keep := make([]bool, n)
+	kept := 0
+	for i := 0; i < n; i++ {
+		prev := points[((i+n-1)%n)*3:]
+		cur := points[i*3:]
+		next := points[((i+1)%n)*3:]
+		if math.Abs(float64(DtTriArea2D(prev, cur, next))) > AREA_EPS*float64(dtDistSqr2D(prev, next)+1) {
+			keep[i] = true
+			kept++
+		}
+	}
+	if kept < 3 {
+		return points
+	}
+	out := make([]float32, 0, kept*3)
+	for i := 0; i < n; i++ {
+		if keep[i] {
+			out = append(out, points[i*3:i*3+3]...)
+		}
+	}
+	return out
+}
@@ -0,0 +1,62 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+/// Adds a tile to the navigation mesh without taking ownership of, or writing to, its data.
+///  @param[in]		data		Data for the new tile mesh. (See: #dtCreateNavMeshData)
+///  @param[in]		dataSize	Data size of the new tile mesh.
+///  @param[in]		lastRef		The desired reference for the tile. (When reloading a tile.) [opt] [Default: 0]
+///  @param[out]	result		The tile reference. (If the tile was succesfully added.) [opt]
+/// @return The status flags for the operation.
+/// @par
+///
+/// The same tile data can be added to any number of navigation meshes at the
+/// same time, including data that lives in read-only memory such as a
+/// memory-mapped file.
+///
+/// The read-only portion of the data (header, detail meshes, detail vertices,
+/// detail triangles, bounding volume tree and off-mesh connections) is referenced
+/// in place. The dynamic portion (polygons and links) is copied into memory owned
+/// by this nav mesh, so polygon flags, areas and links stay per instance. The
+/// vertices are referenced in place too, unless the tile has off-mesh connections,
+/// whose end points are snapped to the mesh when the tile is connected.
+///
+/// The caller must keep @p data alive and unchanged until the tile has been
+/// removed from every nav mesh that uses it.
+///
+/// @see #addTile, #removeTile
+func (this *DtNavMesh) AddTileShared(data []byte, dataSize int, lastRef DtTileRef, result *DtTileRef) DtStatus {
+	return this.addTile(data, dataSize, 0, lastRef, result, true)
+}
+
+// privatizeTileData replaces the slices of a freshly patched tile that the nav
+// mesh writes to with private copies.
+func (this *DtNavMesh) privatizeTileData(tile *DtMeshTile) {
+	polys := make([]DtPoly, len(tile.Polys))
+	copy(polys, tile.Polys)
+	tile.Polys = polys
+
+	tile.Links = make([]DtLink, len(tile.Links))
+
+	if len(tile.OffMeshCons) > 0 {
+		verts := make([]float32, len(tile.Verts))
+		copy(verts, tile.Verts)
+		tile.Verts = verts
+	}
+}
@@ -59,6 +59,8 @@ type DtNodePool struct {
 	m_maxNodes  uint32
 	m_hashSize  uint32
 	m_nodeCount uint32
+	m_highWater uint32 ///< Largest node count seen since the last reset.
+	m_exhausted uint32 ///< Number of nodes refused because the pool was full.
 
 	base uintptr
 }
@@ -90,6 +92,18 @@ func (this *DtNodePool) GetHashSize() uint32             { return this.m_hashSiz
 func (this *DtNodePool) GetFirst(bucket int) DtNodeIndex { return this.m_first[bucket] }
 func (this *DtNodePool) GetNext(i int) DtNodeIndex       { return this.m_next[i] }
 func (this *DtNodePool) GetNodeCount() uint32            { return this.m_nodeCount }
+func (this *DtNodePool) GetExhaustedCount() uint32       { return this.m_exhausted }
+
+/// Gets the largest number of nodes in use at once since the last reset.
+func (this *DtNodePool) GetHighWater() uint32 {
+	return DtMaxUInt32(this.m_highWater, this.m_nodeCount)
+}
+
+/// Resets the high-water mark and the exhausted count.
+func (this *DtNodePool) ResetStats() {
+	this.m_highWater = this.m_nodeCount
+	this.m_exhausted = 0
+}
 
 func DtAllocNodePool(maxNodes, hashSize uint32) *DtNodePool {
 	pool := &DtNodePool{}
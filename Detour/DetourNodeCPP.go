@@ -20,17 +20,6 @@ package detour
 
 import "unsafe"
 
-func DtHashRef(polyRef DtPolyRef) uint32 {
-	a := uint32(polyRef)
-	a += ^(a << 15)
-	a ^= (a >> 10)
-	a += (a << 3)
-	a ^= (a >> 6)
-	a += ^(a << 11)
-	a ^= (a >> 16)
-	return a
-}
-
 func (this *DtNodePool) constructor(maxNodes, hashSize uint32) {
 	this.m_maxNodes = maxNodes
 	this.m_hashSize = hashSize
@@ -68,6 +57,9 @@ func (this *DtNodePool) Clear() {
 	for i := 0; i < len(this.m_first); i++ {
 		this.m_first[i] = DT_NULL_IDX
 	}
+	if this.m_nodeCount > this.m_highWater {
+		this.m_highWater = this.m_nodeCount
+	}
 	this.m_nodeCount = 0
 }
 
@@ -113,6 +105,7 @@ func (this *DtNodePool) GetNode(id DtPolyRef, state uint8) *DtNode {
 	}
 
 	if this.m_nodeCount >= this.m_maxNodes {
+		this.m_exhausted++
 		return nil
 	}
 
@@ -0,0 +1,46 @@
+// +build !dtpolyref64
+
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+/// A handle to a polygon within a navigation mesh tile.
+/// @ingroup detour
+type DtPolyRef uint32
+
+/// A handle to a tile within a navigation mesh.
+/// @ingroup detour
+type DtTileRef uint32
+
+/// The number of bits of #DtPolyRef and #DtTileRef, shared by the salt, tile
+/// and polygon fields. Build with the dtpolyref64 tag for 64-bit references.
+/// @ingroup detour
+const DT_REF_BITS = 32
+
+/// Hashes a polygon reference, for the node pool.
+func DtHashRef(polyRef DtPolyRef) uint32 {
+	a := uint32(polyRef)
+	a += ^(a << 15)
+	a ^= (a >> 10)
+	a += (a << 3)
+	a ^= (a >> 6)
+	a += ^(a << 11)
+	a ^= (a >> 16)
+	return a
+}
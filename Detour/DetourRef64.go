@@ -0,0 +1,52 @@
+// +build dtpolyref64
+
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+/// A handle to a polygon within a navigation mesh tile.
+/// @ingroup detour
+type DtPolyRef uint64
+
+/// A handle to a tile within a navigation mesh.
+/// @ingroup detour
+type DtTileRef uint64
+
+/// The number of bits of #DtPolyRef and #DtTileRef, shared by the salt, tile
+/// and polygon fields.
+/// @par
+///
+/// With the dtpolyref64 build tag the references are 64-bit, so large tiled
+/// worlds keep up to 31 salt bits and stale references do not wrap around.
+/// The tile data contains links, so it must be built with the same tag as
+/// the nav mesh loading it.
+/// @ingroup detour
+const DT_REF_BITS = 64
+
+/// Hashes a polygon reference, for the node pool.
+func DtHashRef(polyRef DtPolyRef) uint32 {
+	a := uint64(polyRef)
+	a = (^a) + (a << 18)
+	a = a ^ (a >> 31)
+	a = a * 21
+	a = a ^ (a >> 11)
+	a = a + (a << 6)
+	a = a ^ (a >> 22)
+	return uint32(a)
+}
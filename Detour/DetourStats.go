@@ -0,0 +1,213 @@
+//
+// Copyright (c) 2009-2010 Mikko Mononen memon@inside.org
+//
+// This software is provided 'as-is', without any express or implied
+// warranty.  In no event will the authors be held liable for any damages
+// arising from the use of this software.
+// Permission is granted to anyone to use this software for any purpose,
+// including commercial applications, and to alter it and redistribute it
+// freely, subject to the following restrictions:
+// 1. The origin of this software must not be misrepresented; you must not
+//    claim that you wrote the original software. If you use this software
+//    in a product, an acknowledgment in the product documentation would be
+//    appreciated but is not required.
+// 2. Altered source versions must be plainly marked as such, and must not be
+//    misrepresented as being the original software.
+// 3. This notice may not be removed or altered from any source distribution.
+//
+
+package detour
+
+import "unsafe"
+
+/// Size and memory statistics of a tile.
+/// @see DtNavMesh::Stats
+type DtTileStats struct {
+	Ref   DtTileRef ///< The tile reference.
+	X     int32     ///< The x-position of the tile within the tile grid.
+	Y     int32     ///< The y-position of the tile within the tile grid.
+	Layer int32     ///< The layer of the tile within the tile grid.
+
+	Polys        int ///< The number of polygons, including off-mesh connections.
+	Verts        int ///< The number of polygon vertices.
+	LinksUsed    int ///< The number of links in use.
+	LinksFree    int ///< The number of free links.
+	DetailMeshes int ///< The number of detail sub-meshes.
+	DetailVerts  int ///< The number of unique detail mesh vertices.
+	DetailTris   int ///< The number of detail mesh triangles.
+	BvNodes      int ///< The number of bounding volume nodes.
+	OffMeshCons  int ///< The number of off-mesh connections.
+
+	DataBytes int ///< The size of the tile data.
+
+	/// The size of the tile arrays held outside the tile data, such as the
+	/// private copies of a shared tile or links grown by runtime connections.
+	PrivateBytes int
+}
+
+/// Size and memory statistics of a navigation mesh.
+/// The totals add up the statistics of the tiles and of the tiles of the
+/// runtime off-mesh connections.
+/// @see DtNavMesh::Stats
+type DtNavMeshStats struct {
+	MaxTiles        int ///< The maximum number of tiles.
+	Tiles           int ///< The number of tiles in use, not counting runtime off-mesh connection tiles.
+	OffMeshConTiles int ///< The number of tiles used by runtime off-mesh connections.
+
+	Polys        int
+	Verts        int
+	LinksUsed    int
+	LinksFree    int
+	DetailMeshes int
+	DetailVerts  int
+	DetailTris   int
+	BvNodes      int
+	OffMeshCons  int
+	DataBytes    int
+	PrivateBytes int
+
+	TileStats []DtTileStats ///< The statistics of each tile in use, by tile index, without the runtime off-mesh connection tiles.
+}
+
+/// Gathers the size and memory statistics of the navigation mesh.
+/// @return The statistics.
+/// @par
+///
+/// The statistics are a snapshot. The tiles are walked, so the cost grows with
+/// the number of tiles and links; call it when exporting metrics, not per frame.
+func (this *DtNavMesh) Stats() DtNavMeshStats {
+	stats := DtNavMeshStats{MaxTiles: int(this.m_maxTiles)}
+	for i := 0; i < int(this.m_maxTiles); i++ {
+		tile := &this.m_tiles[i]
+		if tile.Header == nil {
+			continue
+		}
+		ts := this.tileStats(tile)
+		if (tile.Flags & DT_TILE_OFFMESH_CONNECTION) != 0 {
+			stats.OffMeshConTiles++
+		} else {
+			stats.Tiles++
+			stats.TileStats = append(stats.TileStats, ts)
+		}
+		stats.Polys += ts.Polys
+		stats.Verts += ts.Verts
+		stats.LinksUsed += ts.LinksUsed
+		stats.LinksFree += ts.LinksFree
+		stats.DetailMeshes += ts.DetailMeshes
+		stats.DetailVerts += ts.DetailVerts
+		stats.DetailTris += ts.DetailTris
+		stats.BvNodes += ts.BvNodes
+		stats.OffMeshCons += ts.OffMeshCons
+		stats.DataBytes += ts.DataBytes
+		stats.PrivateBytes += ts.PrivateBytes
+	}
+	return stats
+}
+
+func (this *DtNavMesh) tileStats(tile *DtMeshTile) DtTileStats {
+	header := tile.Header
+	ts := DtTileStats{
+		Ref:          this.GetTileRef(tile),
+		X:            header.X,
+		Y:            header.Y,
+		Layer:        header.Layer,
+		Polys:        int(header.PolyCount),
+		Verts:        int(header.VertCount),
+		DetailMeshes: int(header.DetailMeshCount),
+		DetailVerts:  int(header.DetailVertCount),
+		DetailTris:   int(header.DetailTriCount),
+		BvNodes:      int(header.BvNodeCount),
+		OffMeshCons:  int(header.OffMeshConCount),
+		DataBytes:    len(tile.Data),
+	}
+	for i := tile.LinksFreeList; i != DT_NULL_LINK; i = tile.Links[i].Next {
+		ts.LinksFree++
+	}
+	ts.LinksUsed = len(tile.Links) - ts.LinksFree
+
+	if len(tile.Polys) != 0 && !inTileData(tile, unsafe.Pointer(&tile.Polys[0])) {
+		ts.PrivateBytes += len(tile.Polys) * int(unsafe.Sizeof(tile.Polys[0]))
+	}
+	if len(tile.Verts) != 0 && !inTileData(tile, unsafe.Pointer(&tile.Verts[0])) {
+		ts.PrivateBytes += len(tile.Verts) * int(unsafe.Sizeof(tile.Verts[0]))
+	}
+	if len(tile.Links) != 0 && !inTileData(tile, unsafe.Pointer(&tile.Links[0])) {
+		ts.PrivateBytes += len(tile.Links) * int(unsafe.Sizeof(tile.Links[0]))
+	}
+	if len(tile.OffMeshCons) != 0 && !inTileData(tile, unsafe.Pointer(&tile.OffMeshCons[0])) {
+		ts.PrivateBytes += len(tile.OffMeshCons) * int(unsafe.Sizeof(tile.OffMeshCons[0]))
+	}
+	return ts
+}
+
+// inTileData reports whether p points into the data of tile.
+func inTileData(tile *DtMeshTile, p unsafe.Pointer) bool {
+	if len(tile.Data) == 0 {
+		return false
+	}
+	begin := uintptr(unsafe.Pointer(&tile.Data[0]))
+	return uintptr(p) >= begin && uintptr(p) < begin+uintptr(len(tile.Data))
+}
+
+/// Usage statistics of a node pool.
+/// @see DtNavMeshQuery::Stats
+type DtNodePoolStats struct {
+	MaxNodes  int ///< The capacity of the pool.
+	HashSize  int ///< The number of hash buckets.
+	Nodes     int ///< The number of nodes used by the last search.
+	HighWater int ///< The largest number of nodes used by a search since the last reset.
+	Exhausted int ///< The number of nodes refused because the pool was full, since the last reset.
+	MemUsed   int ///< The memory used by the pool, in bytes.
+}
+
+/// Usage statistics of a query object.
+/// @see DtNavMeshQuery::Stats
+type DtNavMeshQueryStats struct {
+	NodePool     DtNodePoolStats ///< The node pool of the path searches.
+	TinyNodePool DtNodePoolStats ///< The small node pool of the local searches.
+
+	/// The node pool of the backward search of bidirectional path finding.
+	/// (Zero until the first bidirectional search.)
+	BackNodePool DtNodePoolStats
+}
+
+/// Gathers the usage statistics of the node pools of the query.
+/// @return The statistics.
+/// @par
+///
+/// A high-water mark close to the capacity, or a non-zero exhausted count,
+/// means searches are cut short and the query should be initialised with
+/// more nodes.
+///
+/// Like the other query methods this must not run concurrently with a search
+/// on the same query object.
+func (this *DtNavMeshQuery) Stats() DtNavMeshQueryStats {
+	return DtNavMeshQueryStats{
+		NodePool:     this.m_nodePool.stats(),
+		TinyNodePool: this.m_tinyNodePool.stats(),
+		BackNodePool: this.m_backNodePool.stats(),
+	}
+}
+
+/// Resets the high-water marks and exhausted counts of the node pools.
+func (this *DtNavMeshQuery) ResetStats() {
+	for _, pool := range []*DtNodePool{this.m_nodePool, this.m_tinyNodePool, this.m_backNodePool} {
+		if pool != nil {
+			pool.ResetStats()
+		}
+	}
+}
+
+func (this *DtNodePool) stats() DtNodePoolStats {
+	if this == nil {
+		return DtNodePoolStats{}
+	}
+	return DtNodePoolStats{
+		MaxNodes:  int(this.m_maxNodes),
+		HashSize:  int(this.m_hashSize),
+		Nodes:     int(this.m_nodeCount),
+		HighWater: int(this.GetHighWater()),
+		Exhausted: int(this.m_exhausted),
+		MemUsed:   int(this.GetMemUsed()),
+	}
+}
@@ -6,7 +6,9 @@ import (
 
 type DtObstacleRef uint32
 
-type DtCompressedTileRef uint32
+/// A handle to a compressed tile, 64-bit like detour.DtTileRef with the
+/// dtpolyref64 build tag.
+type DtCompressedTileRef detour.DtTileRef
 
 /// Flags for AddTile
 const (
@@ -2,6 +2,7 @@ package dtcache
 
 import (
 	"math"
+	"sync"
 	"unsafe"
 
 	"github.com/fananchong/recastnavigation-go/Detour"
@@ -124,7 +125,7 @@ func (this *DtTileCache) Init(params *DtTileCacheParams,
 	// Init ID generator values.
 	this.m_tileBits = detour.DtIlog2(detour.DtNextPow2(uint32(this.m_params.MaxTiles)))
 	// Only allow 31 salt bits, since the salt mask is calculated using 32bit uint and it will overflow.
-	this.m_saltBits = detour.DtMinUInt32(uint32(31), 32-this.m_tileBits)
+	this.m_saltBits = detour.DtMinUInt32(uint32(31), detour.DT_REF_BITS-this.m_tileBits)
 	if this.m_saltBits < 10 {
 		return detour.DT_FAILURE | detour.DT_INVALID_PARAM
 	}
@@ -493,6 +494,15 @@ func (this *DtTileCache) QueryTiles(bmin, bmax []float32,
 
 func (this *DtTileCache) Update(dt float32, navmesh *detour.DtNavMesh,
 	upToDate *bool) detour.DtStatus {
+	return this.UpdateLocked(dt, navmesh, upToDate, nil)
+}
+
+/// Same as #Update, but the nav mesh is only modified while @p lock is held.
+/// The tiles are rebuilt before taking the lock, so queries holding the read
+/// side of the same lock keep running during the rebuild. The tile cache itself
+/// is not synchronized and must only be used by the caller's goroutine.
+func (this *DtTileCache) UpdateLocked(dt float32, navmesh *detour.DtNavMesh,
+	upToDate *bool, lock sync.Locker) detour.DtStatus {
 	if this.m_nupdate == 0 {
 		// Process requests.
 		for i := int32(0); i < this.m_nreqs; i++ {
@@ -554,7 +564,7 @@ func (this *DtTileCache) Update(dt float32, navmesh *detour.DtNavMesh,
 	if this.m_nupdate > 0 {
 		// Build mesh
 		ref := this.m_update[0]
-		status = this.BuildNavMeshTile(ref, navmesh)
+		status = this.BuildNavMeshTileLocked(ref, navmesh, lock)
 		this.m_nupdate--
 		if this.m_nupdate > 0 {
 			for i := int32(0); i < this.m_nupdate; i++ {
@@ -618,8 +628,52 @@ func (this *DtTileCache) BuildNavMeshTilesAt(tx, ty int32, navmesh *detour.DtNav
 }
 
 func (this *DtTileCache) BuildNavMeshTile(ref DtCompressedTileRef, navmesh *detour.DtNavMesh) detour.DtStatus {
+	return this.BuildNavMeshTileLocked(ref, navmesh, nil)
+}
+
+/// Same as #BuildNavMeshTile, but only the swap of the old nav mesh tile for
+/// the new one happens while @p lock is held. A nil @p lock is allowed.
+func (this *DtTileCache) BuildNavMeshTileLocked(ref DtCompressedTileRef, navmesh *detour.DtNavMesh, lock sync.Locker) detour.DtStatus {
+	var navData []byte
+	var navDataSize int
+	status := this.BuildNavMeshTileData(ref, &navData, &navDataSize)
+	if detour.DtStatusFailed(status) {
+		return status
+	}
+	tile := this.GetTileByRef(ref)
+
+	if lock != nil {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	// Remove existing tile.
+	navmesh.RemoveTile(navmesh.GetTileRefAt(tile.Header.Tx, tile.Header.Ty, tile.Header.Tlayer), nil, nil)
+
+	// Add new tile, or leave the location empty.
+	if navData != nil {
+		// Let the navmesh own the data.
+		status = navmesh.AddTile(navData, navDataSize, detour.DT_TILE_FREE_DATA, 0, nil)
+		if detour.DtStatusFailed(status) {
+			return status
+		}
+	}
+
+	return detour.DT_SUCCESS
+}
+
+/// Builds the nav mesh tile data of a compressed tile, with the current
+/// obstacles rasterized, without touching any nav mesh.
+///  @param[in]		ref			The compressed tile reference.
+///  @param[out]	navData		The tile data, or nil if the tile has no polygons.
+///  @param[out]	navDataSize	The size of the tile data.
+/// @return The status flags for the operation.
+func (this *DtTileCache) BuildNavMeshTileData(ref DtCompressedTileRef, navData *[]byte, navDataSize *int) detour.DtStatus {
 	detour.DtAssert(this.m_tcomp != nil)
 
+	*navData = nil
+	*navDataSize = 0
+
 	idx := this.DecodeTileIdTile(ref)
 	if idx > uint32(this.m_params.MaxTiles) {
 		return detour.DT_FAILURE | detour.DT_INVALID_PARAM
@@ -687,8 +741,6 @@ func (this *DtTileCache) BuildNavMeshTile(ref DtCompressedTileRef, navmesh *deto
 
 	// Early out if the mesh tile is empty.
 	if bc.lmesh.Npolys == 0 {
-		// Remove existing tile.
-		navmesh.RemoveTile(navmesh.GetTileRefAt(tile.Header.Tx, tile.Header.Ty, tile.Header.Tlayer), nil, nil)
 		return detour.DT_SUCCESS
 	}
 
@@ -717,24 +769,10 @@ func (this *DtTileCache) BuildNavMeshTile(ref DtCompressedTileRef, navmesh *deto
 		this.m_tmproc.Process(&params, bc.lmesh.Areas[:], bc.lmesh.Flags[:])
 	}
 
-	var navData []byte
-	var navDataSize int
-	if !detour.DtCreateNavMeshData(&params, &navData, &navDataSize) {
+	if !detour.DtCreateNavMeshData(&params, navData, navDataSize) {
 		return detour.DT_FAILURE
 	}
 
-	// Remove existing tile.
-	navmesh.RemoveTile(navmesh.GetTileRefAt(tile.Header.Tx, tile.Header.Ty, tile.Header.Tlayer), nil, nil)
-
-	// Add new tile, or leave the location empty.
-	if navData != nil {
-		// Let the navmesh own the data.
-		status = navmesh.AddTile(navData, navDataSize, detour.DT_TILE_FREE_DATA, 0, nil)
-		if detour.DtStatusFailed(status) {
-			return status
-		}
-	}
-
 	return detour.DT_SUCCESS
 }
 
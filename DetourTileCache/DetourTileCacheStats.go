@@ -0,0 +1,51 @@
+package dtcache
+
+import (
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+/// Size and usage statistics of a tile cache.
+/// @see DtTileCache::Stats
+type DtTileCacheStats struct {
+	MaxTiles int ///< The maximum number of compressed tiles.
+	Tiles    int ///< The number of compressed tiles in use.
+
+	/// The size of the stored tile data, layer headers included.
+	CompressedBytes int
+
+	/// The size the tiles decompress to: the layer header followed by the
+	/// height, area and connection grids.
+	UncompressedBytes int
+
+	MaxObstacles    int ///< The number of obstacle slots.
+	Obstacles       int ///< The number of obstacle slots in use, including obstacles being added or removed.
+	PendingRequests int ///< The number of obstacle requests waiting for the next update.
+	PendingUpdates  int ///< The number of tiles waiting to be rebuilt.
+}
+
+/// Gathers the size and usage statistics of the tile cache.
+/// @return The statistics.
+func (this *DtTileCache) Stats() DtTileCacheStats {
+	stats := DtTileCacheStats{
+		MaxTiles:        int(this.m_params.MaxTiles),
+		MaxObstacles:    int(this.m_params.MaxObstacles),
+		PendingRequests: int(this.m_nreqs),
+		PendingUpdates:  int(this.m_nupdate),
+	}
+	headerSize := detour.DtAlign4(int(DtTileCacheLayerHeaderSize))
+	for i := range this.m_tiles {
+		tile := &this.m_tiles[i]
+		if tile.Header == nil {
+			continue
+		}
+		stats.Tiles++
+		stats.CompressedBytes += int(tile.DataSize)
+		stats.UncompressedBytes += headerSize + int(tile.Header.Width)*int(tile.Header.Height)*3
+	}
+	for i := range this.m_obstacles {
+		if this.m_obstacles[i].State != DT_OBSTACLE_EMPTY {
+			stats.Obstacles++
+		}
+	}
+	return stats
+}
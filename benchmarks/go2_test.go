@@ -29,6 +29,8 @@ func Benchmark_TileCache_FindPath(t *testing.B) {
 	query := tests.CreateQuery(mesh2, 2048)
 	filter := detour.DtAllocDtQueryFilter()
 
+	nodes := 0
+	partial := 0
 	for i := 0; i < t.N; i++ {
 		var stat detour.DtStatus
 		startPos := [3]float32{0, 0, 0}
@@ -41,7 +43,13 @@ func Benchmark_TileCache_FindPath(t *testing.B) {
 		pathCount := 0
 		stat = query.FindPath(startRef, endRef, startPos[:], endPos[:], filter, path[:], &pathCount, PATH_MAX_NODE)
 		detour.DtAssert(detour.DtStatusSucceed(stat))
+		nodes += countNodes(query, startRef != endRef)
+		if detour.DtStatusDetail(stat, detour.DT_PARTIAL_RESULT) {
+			partial++
+		}
 	}
+	t.ReportMetric(float64(nodes)/float64(t.N), "nodes/op")
+	t.ReportMetric(float64(partial)/float64(t.N), "partial/op")
 }
 
 func Benchmark_TileCache_MoveAlongSurface(t *testing.B) {
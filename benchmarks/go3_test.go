@@ -0,0 +1,67 @@
+package benchmarks
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/tests"
+)
+
+// Compare with Benchmark_TileCache_FindPath. Both report the nodes used and
+// the fraction of paths that did not reach the end.
+func Benchmark_TileCache_FindPathBidirectional(t *testing.B) {
+	var randPosValue []float32
+	var randPosIndex int = 0
+
+	getPos := func(ref *detour.DtPolyRef, pos []float32) {
+		*ref = detour.DtPolyRef(randPosValue[randPosIndex*4+0])
+		pos[0] = randPosValue[randPosIndex*4+1]
+		pos[1] = randPosValue[randPosIndex*4+2]
+		pos[2] = randPosValue[randPosIndex*4+3]
+		randPosIndex++
+	}
+
+	sliceHeader := (*reflect.SliceHeader)((unsafe.Pointer(&randPosValue)))
+	sliceHeader.Cap = int(len(tempdata2) / int(unsafe.Sizeof(float32(1.0))))
+	sliceHeader.Len = int(len(tempdata2) / int(unsafe.Sizeof(float32(1.0))))
+	sliceHeader.Data = uintptr(unsafe.Pointer(&(tempdata2[0])))
+
+	query := tests.CreateQuery(mesh2, 2048)
+	filter := detour.DtAllocDtQueryFilter()
+
+	nodes := 0
+	partial := 0
+	for i := 0; i < t.N; i++ {
+		var stat detour.DtStatus
+		startPos := [3]float32{0, 0, 0}
+		endPos := [3]float32{0, 0, 0}
+		var startRef detour.DtPolyRef
+		var endRef detour.DtPolyRef
+		getPos(&startRef, startPos[:])
+		getPos(&endRef, endPos[:])
+		var path [PATH_MAX_NODE]detour.DtPolyRef
+		pathCount := 0
+		stat = query.FindPathBidirectional(startRef, endRef, startPos[:], endPos[:], filter, path[:], &pathCount, PATH_MAX_NODE)
+		detour.DtAssert(detour.DtStatusSucceed(stat))
+		nodes += countNodes(query, startRef != endRef)
+		if detour.DtStatusDetail(stat, detour.DT_PARTIAL_RESULT) {
+			partial++
+		}
+	}
+	t.ReportMetric(float64(nodes)/float64(t.N), "nodes/op")
+	t.ReportMetric(float64(partial)/float64(t.N), "partial/op")
+}
+
+// countNodes returns the number of nodes used by the last search of query.
+func countNodes(query *detour.DtNavMeshQuery, searched bool) int {
+	if !searched {
+		return 0
+	}
+	n := int(query.GetNodePool().GetNodeCount())
+	if pool := query.GetBackNodePool(); pool != nil {
+		n += int(pool.GetNodeCount())
+	}
+	return n
+}
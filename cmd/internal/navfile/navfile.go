@@ -0,0 +1,48 @@
+// Package navfile loads the navmesh files given to the commands.
+package navfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navimport"
+	"github.com/fananchong/recastnavigation-go/navmeshset"
+)
+
+// Load loads a navmesh file. It is a version 2 navmesh container, a legacy
+// navmesh set (MSET), or a JSON tile description when its extension is .json.
+func Load(path string) (*detour.DtNavMesh, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		desc, err := navimport.ParseJSON(f)
+		if err != nil {
+			return nil, err
+		}
+		return navimport.BuildNavMesh(nil, desc)
+	}
+
+	reader, err := navmeshset.Open(path, nil)
+	if err == nil {
+		defer reader.Close()
+		return reader.LoadNavMesh()
+	}
+	if !errors.Is(err, navmeshset.ErrWrongMagic) {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := navmeshset.NewShared(data)
+	if err != nil {
+		return nil, err
+	}
+	return shared.NewNavMesh()
+}
@@ -0,0 +1,93 @@
+// Command navdiff compares two builds of a navmesh tile by tile and writes
+// the differences as JSON, to review what changed in navigation before a
+// map is shipped.
+//
+// Usage:
+//
+//	navdiff [-landmarks landmarks.json] [-tolerance d] [-max-nodes n] [-o diff.json] old new
+//
+// The files are version 2 navmesh containers, legacy navmesh sets (MSET), or
+// JSON tile descriptions (see package navimport). The landmarks file is a
+// JSON array of named points, such as
+//
+//	[{"name": "spawn", "pos": [1, 0, 2]}, {"name": "flag", "pos": [40, 0, 12]}]
+//
+// whose reachability from each other is compared.
+//
+// Like diff, the exit status is 0 when the builds are the same, 1 when they
+// differ, and 2 when a file cannot be loaded.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fananchong/recastnavigation-go/cmd/internal/navfile"
+	"github.com/fananchong/recastnavigation-go/navdiff"
+	"github.com/fananchong/recastnavigation-go/navigation"
+)
+
+func main() {
+	landmarks := flag.String("landmarks", "", "JSON file of the landmarks whose reachability is compared")
+	tolerance := flag.Float64("tolerance", navdiff.DEFAULT_TOLERANCE, "distance below which vertices are equal, in world units")
+	maxNodes := flag.Int("max-nodes", navigation.DEFAULT_MAX_NODES, "node pool size of the path searches")
+	output := flag.String("o", "", "write the differences to this file instead of the standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: navdiff [flags] old new\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := &navdiff.Options{
+		Tolerance:  float32(*tolerance),
+		Navigation: &navigation.Options{MaxNodes: *maxNodes},
+	}
+	if *landmarks != "" {
+		data, err := os.ReadFile(*landmarks)
+		if err != nil {
+			fail(err)
+		}
+		if err := json.Unmarshal(data, &opts.Landmarks); err != nil {
+			fail(fmt.Errorf("%s: %w", *landmarks, err))
+		}
+	}
+	oldMesh, err := navfile.Load(flag.Arg(0))
+	if err != nil {
+		fail(err)
+	}
+	newMesh, err := navfile.Load(flag.Arg(1))
+	if err != nil {
+		fail(err)
+	}
+	report, err := navdiff.Compare(oldMesh, newMesh, opts)
+	if err != nil {
+		fail(err)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			fail(err)
+		}
+	}
+	if err := report.WriteJSON(out); err != nil {
+		fail(err)
+	}
+	if err := out.Close(); err != nil {
+		fail(err)
+	}
+	if report.Changed() {
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "navdiff:", err)
+	os.Exit(2)
+}
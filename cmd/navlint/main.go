@@ -0,0 +1,108 @@
+// Command navlint checks the tiles of navmesh files and writes a JSON report,
+// so that broken map assets can be rejected before they are published.
+//
+// Usage:
+//
+//	navlint [-tolerance d] [-checks list] [-strict] [-o report.json] file...
+//
+// The files are version 2 navmesh containers, legacy navmesh sets (MSET), or
+// JSON tile descriptions (see package navimport). The report is a JSON array
+// with one entry per file.
+//
+// The exit status is 1 when an error is found, or a warning with -strict, and
+// 2 when a file cannot be loaded.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fananchong/recastnavigation-go/cmd/internal/navfile"
+	"github.com/fananchong/recastnavigation-go/navlint"
+)
+
+type fileReport struct {
+	File  string `json:"file"`
+	Error string `json:"error,omitempty"`
+	*navlint.Report
+}
+
+func main() {
+	tolerance := flag.Float64("tolerance", navlint.DEFAULT_TOLERANCE, "distance below which positions are equal, in world units")
+	checks := flag.String("checks", "", "comma separated checks to run, all when empty")
+	strict := flag.Bool("strict", false, "fail on warnings too")
+	output := flag.String("o", "", "write the report to this file instead of the standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: navlint [flags] file...\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "checks: %v\n", navlint.Checks)
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := &navlint.Options{Tolerance: float32(*tolerance)}
+	if *checks != "" {
+		for _, name := range strings.Split(*checks, ",") {
+			check := navlint.Check(strings.TrimSpace(name))
+			if !knownCheck(check) {
+				fmt.Fprintf(os.Stderr, "navlint: unknown check %q\n", check)
+				os.Exit(2)
+			}
+			opts.Checks = append(opts.Checks, check)
+		}
+	}
+
+	status := 0
+	reports := make([]fileReport, 0, flag.NArg())
+	for _, path := range flag.Args() {
+		navMesh, err := navfile.Load(path)
+		if err != nil {
+			reports = append(reports, fileReport{File: path, Error: err.Error()})
+			status = 2
+			continue
+		}
+		report := navlint.Lint(navMesh, opts)
+		reports = append(reports, fileReport{File: path, Report: report})
+		if status == 0 && (!report.OK() || (*strict && report.Warnings != 0)) {
+			status = 1
+		}
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "navlint:", err)
+			os.Exit(2)
+		}
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(reports); err != nil {
+		fmt.Fprintln(os.Stderr, "navlint:", err)
+		status = 2
+	}
+	if out != os.Stdout {
+		if err := out.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "navlint:", err)
+			status = 2
+		}
+	}
+	os.Exit(status)
+}
+
+func knownCheck(check navlint.Check) bool {
+	for _, known := range navlint.Checks {
+		if check == known {
+			return true
+		}
+	}
+	return false
+}
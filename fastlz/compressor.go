@@ -0,0 +1,92 @@
+package fastlz
+
+import (
+	"github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// MaxCompressedSize returns the worst case size of the output of Compress for
+// length bytes of input.
+//
+// FastLZ never expands matches, and literals are emitted in runs of at most
+// MAX_COPY bytes, each preceded by one control byte. The bound also honours
+// the 66 byte minimum output size required by the reference implementation.
+func MaxCompressedSize(length int) int {
+	bound := length + (length+MAX_COPY-1)/MAX_COPY + 1
+	if bound < 66 {
+		bound = 66
+	}
+	return bound
+}
+
+// Compress compresses input into output and returns the compressed size.
+// Unlike Fastlz_compress it accepts any input length, and it returns 0
+// instead of overrunning output when output is shorter than
+// MaxCompressedSize(len(input)).
+func Compress(input []byte, output []byte) int {
+	if len(output) < MaxCompressedSize(len(input)) {
+		return 0
+	}
+	if len(input) < 16 {
+		// The compressors need at least 16 bytes of lookahead, store short
+		// input as a literal run of a level 1 block instead.
+		return compressLiterals(input, output)
+	}
+	return Fastlz_compress(input, len(input), output)
+}
+
+// Decompress decompresses input into output and returns the decompressed
+// size, or 0 when input is corrupted, truncated or does not fit into output.
+func Decompress(input []byte, output []byte) int {
+	if len(input) == 0 {
+		return 0
+	}
+	return Fastlz_decompress(input, len(input), output, len(output))
+}
+
+func compressLiterals(input []byte, output []byte) int {
+	op := 0
+	for ip := 0; ip < len(input); ip += MAX_COPY {
+		run := len(input) - ip
+		if run > MAX_COPY {
+			run = MAX_COPY
+		}
+		output[op] = byte(run - 1)
+		op++
+		op += copy(output[op:], input[ip:ip+run])
+	}
+	return op
+}
+
+// Compressor implements the tile cache compressor interface
+// (dtcache.DtTileCacheCompressor) with FastLZ.
+type Compressor struct{}
+
+func (this *Compressor) MaxCompressedSize(bufferSize int32) int32 {
+	return int32(MaxCompressedSize(int(bufferSize)))
+}
+
+func (this *Compressor) Compress(buffer []byte, bufferSize int32, compressed []byte, maxCompressedSize int32, compressedSize *int32) detour.DtStatus {
+	if int(maxCompressedSize) > len(compressed) {
+		maxCompressedSize = int32(len(compressed))
+	}
+	if maxCompressedSize < this.MaxCompressedSize(bufferSize) {
+		return detour.DT_FAILURE | detour.DT_BUFFER_TOO_SMALL
+	}
+	*compressedSize = int32(Compress(buffer[:bufferSize], compressed[:maxCompressedSize]))
+	return detour.DT_SUCCESS
+}
+
+func (this *Compressor) Decompress(compressed []byte, compressedSize int32, buffer []byte, maxBufferSize int32, bufferSize *int32) detour.DtStatus {
+	if int(maxBufferSize) > len(buffer) {
+		maxBufferSize = int32(len(buffer))
+	}
+	if compressedSize < 0 || int(compressedSize) > len(compressed) || maxBufferSize < 0 {
+		*bufferSize = 0
+		return detour.DT_FAILURE | detour.DT_INVALID_PARAM
+	}
+	*bufferSize = int32(Decompress(compressed[:compressedSize], buffer[:maxBufferSize]))
+	if *bufferSize == 0 && compressedSize != 0 {
+		return detour.DT_FAILURE
+	}
+	return detour.DT_SUCCESS
+}
@@ -113,6 +113,9 @@ func Fastlz_compress(input []byte, length int, output []byte) int {
   more than what is specified in maxout.
 */
 func Fastlz_decompress(input []byte, length int, output []byte, maxout int) int {
+	if length <= 0 || len(input) == 0 {
+		return 0
+	}
 	/* magic identifier for compression level */
 	level := ((*(*uint8)(unsafe.Pointer(&input[0]))) >> 5) + 1
 
@@ -701,6 +704,12 @@ func fastlz2_compress(input []byte, length int, output []byte) int {
 }
 
 func fastlz1_decompress(input []byte, length int, output []byte, maxout int) int {
+	if length > len(input) {
+		length = len(input)
+	}
+	if maxout > len(output) {
+		maxout = len(output)
+	}
 	var ip uint = 0
 	var ip_limit uint = uint(length)
 	var op uint = 0
@@ -718,9 +727,15 @@ func fastlz1_decompress(input []byte, length int, output []byte, maxout int) int
 			len--
 			ref -= ofs
 			if len == 7-1 {
+				if ip >= ip_limit {
+					return 0
+				}
 				len += uint(input[ip])
 				ip++
 			}
+			if ip >= ip_limit {
+				return 0
+			}
 			ref -= uint(input[ip])
 			ip++
 
@@ -797,6 +812,12 @@ func fastlz1_decompress(input []byte, length int, output []byte, maxout int) int
 }
 
 func fastlz2_decompress(input []byte, length int, output []byte, maxout int) int {
+	if length > len(input) {
+		length = len(input)
+	}
+	if maxout > len(output) {
+		maxout = len(output)
+	}
 	var ip uint = 0
 	var ip_limit uint = uint(length)
 	var op uint = 0
@@ -817,11 +838,17 @@ func fastlz2_decompress(input []byte, length int, output []byte, maxout int) int
 			if len == 7-1 {
 				code = 255
 				for code == 255 {
+					if ip >= ip_limit {
+						return 0
+					}
 					code = input[ip]
 					ip++
 					len += uint(code)
 				}
 			}
+			if ip >= ip_limit {
+				return 0
+			}
 			code = input[ip]
 			ip++
 			ref -= uint(code)
@@ -829,6 +856,9 @@ func fastlz2_decompress(input []byte, length int, output []byte, maxout int) int
 			/* match from 16-bit distance */
 			if code == 255 {
 				if ofs == (31 << 8) {
+					if ip+2 > ip_limit {
+						return 0
+					}
 					ofs = uint(input[ip]) << 8
 					ip++
 					ofs += uint(input[ip])
@@ -0,0 +1,243 @@
+package fastlz
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// Framed stream format.
+//
+//	stream  = magic block* end
+//	magic   = "FLZ1"
+//	block   = header payload
+//	header  = storedSize uint32, rawSize uint32, crc32 uint32   (little-endian)
+//	end     = header with storedSize = rawSize = crc32 = 0
+//
+// storedSize is the payload size. When its STREAM_BLOCK_RAW bit is set the
+// payload is the raw data, otherwise it is a FastLZ block that decompresses
+// to rawSize bytes. crc32 is the IEEE CRC32 of the raw data.
+const (
+	STREAM_MAGIC          = "FLZ1"
+	STREAM_BLOCK_RAW      = 0x80000000
+	DEFAULT_BLOCK_SIZE    = 64 * 1024
+	MAX_BLOCK_SIZE        = 4 * 1024 * 1024
+	streamBlockHeaderSize = 12
+)
+
+var (
+	ErrHeader    = errors.New("fastlz: invalid stream header")
+	ErrCorrupt   = errors.New("fastlz: corrupt stream")
+	ErrChecksum  = errors.New("fastlz: checksum mismatch")
+	ErrBlockSize = errors.New("fastlz: invalid block size")
+	ErrClosed    = errors.New("fastlz: writer closed")
+)
+
+// Writer compresses data written to it into the framed stream format.
+// Close must be called to write the end of the stream.
+type Writer struct {
+	w           io.Writer
+	buf         []byte
+	n           int
+	out         []byte
+	wroteHeader bool
+	closed      bool
+	err         error
+}
+
+// NewWriter returns a Writer using DEFAULT_BLOCK_SIZE blocks.
+func NewWriter(w io.Writer) *Writer {
+	writer, _ := NewWriterSize(w, DEFAULT_BLOCK_SIZE)
+	return writer
+}
+
+// NewWriterSize returns a Writer that buffers up to blockSize bytes per block.
+func NewWriterSize(w io.Writer, blockSize int) (*Writer, error) {
+	if blockSize <= 0 || blockSize > MAX_BLOCK_SIZE {
+		return nil, ErrBlockSize
+	}
+	return &Writer{
+		w:   w,
+		buf: make([]byte, blockSize),
+		out: make([]byte, streamBlockHeaderSize+MaxCompressedSize(blockSize)),
+	}, nil
+}
+
+// Write buffers p, writing a block every time the buffer fills up.
+func (this *Writer) Write(p []byte) (int, error) {
+	if this.closed {
+		return 0, ErrClosed
+	}
+	written := 0
+	for len(p) > 0 && this.err == nil {
+		c := copy(this.buf[this.n:], p)
+		this.n += c
+		p = p[c:]
+		written += c
+		if this.n == len(this.buf) {
+			this.writeBlock()
+		}
+	}
+	return written, this.err
+}
+
+// Flush writes the buffered data as a block.
+func (this *Writer) Flush() error {
+	if this.closed {
+		return ErrClosed
+	}
+	if this.n > 0 {
+		this.writeBlock()
+	}
+	return this.err
+}
+
+// Close flushes the buffered data and writes the end of the stream. It does
+// not close the underlying writer.
+func (this *Writer) Close() error {
+	if this.closed {
+		return this.err
+	}
+	this.Flush()
+	this.closed = true
+	this.writeHeader()
+	if this.err == nil {
+		var end [streamBlockHeaderSize]byte
+		_, this.err = this.w.Write(end[:])
+	}
+	return this.err
+}
+
+func (this *Writer) writeHeader() {
+	if this.wroteHeader || this.err != nil {
+		return
+	}
+	this.wroteHeader = true
+	_, this.err = io.WriteString(this.w, STREAM_MAGIC)
+}
+
+func (this *Writer) writeBlock() {
+	this.writeHeader()
+	if this.err != nil {
+		return
+	}
+	raw := this.buf[:this.n]
+	this.n = 0
+
+	payload := this.out[streamBlockHeaderSize:]
+	stored := uint32(Compress(raw, payload))
+	if stored == 0 || int(stored) >= len(raw) {
+		stored = uint32(copy(payload, raw)) | STREAM_BLOCK_RAW
+	}
+	binary.LittleEndian.PutUint32(this.out[0:], stored)
+	binary.LittleEndian.PutUint32(this.out[4:], uint32(len(raw)))
+	binary.LittleEndian.PutUint32(this.out[8:], crc32.ChecksumIEEE(raw))
+	_, this.err = this.w.Write(this.out[:streamBlockHeaderSize+int(stored&^STREAM_BLOCK_RAW)])
+}
+
+// Reader decompresses a framed stream written by Writer.
+type Reader struct {
+	r          io.Reader
+	readHeader bool
+	eof        bool
+	in         []byte
+	block      []byte
+	pos        int
+	err        error
+}
+
+// NewReader returns a Reader decompressing r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Read reads decompressed data. It returns io.EOF at the end of the stream,
+// io.ErrUnexpectedEOF when the stream is truncated and ErrChecksum or
+// ErrCorrupt when a block is damaged.
+func (this *Reader) Read(p []byte) (int, error) {
+	for this.pos == len(this.block) {
+		if this.err != nil {
+			return 0, this.err
+		}
+		if this.eof {
+			return 0, io.EOF
+		}
+		this.err = this.nextBlock()
+	}
+	n := copy(p, this.block[this.pos:])
+	this.pos += n
+	return n, nil
+}
+
+func (this *Reader) nextBlock() error {
+	this.block = this.block[:0]
+	this.pos = 0
+
+	if !this.readHeader {
+		var magic [len(STREAM_MAGIC)]byte
+		if _, err := io.ReadFull(this.r, magic[:]); err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if string(magic[:]) != STREAM_MAGIC {
+			return ErrHeader
+		}
+		this.readHeader = true
+	}
+
+	var header [streamBlockHeaderSize]byte
+	if _, err := io.ReadFull(this.r, header[:]); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	stored := binary.LittleEndian.Uint32(header[0:])
+	rawSize := binary.LittleEndian.Uint32(header[4:])
+	sum := binary.LittleEndian.Uint32(header[8:])
+	if stored == 0 && rawSize == 0 {
+		if sum != 0 {
+			return ErrCorrupt
+		}
+		this.eof = true
+		return nil
+	}
+
+	raw := stored&STREAM_BLOCK_RAW != 0
+	storedSize := stored &^ STREAM_BLOCK_RAW
+	if rawSize == 0 || rawSize > MAX_BLOCK_SIZE ||
+		(raw && storedSize != rawSize) ||
+		(!raw && storedSize > uint32(MaxCompressedSize(int(rawSize)))) {
+		return ErrCorrupt
+	}
+
+	if cap(this.in) < int(storedSize) {
+		this.in = make([]byte, storedSize)
+	}
+	in := this.in[:storedSize]
+	if _, err := io.ReadFull(this.r, in); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	if cap(this.block) < int(rawSize) {
+		this.block = make([]byte, rawSize)
+	}
+	this.block = this.block[:rawSize]
+	if raw {
+		copy(this.block, in)
+	} else if Decompress(in, this.block) != int(rawSize) {
+		this.block = this.block[:0]
+		return ErrCorrupt
+	}
+	if crc32.ChecksumIEEE(this.block) != sum {
+		this.block = this.block[:0]
+		return ErrChecksum
+	}
+	return nil
+}
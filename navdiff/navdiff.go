@@ -0,0 +1,413 @@
+// Package navdiff compares two builds of a navmesh tile by tile, to review
+// what changed in navigation when the geometry of a map changes: polygons
+// added or removed, area and flag changes, links between polygons, and
+// which landmarks can reach each other.
+//
+// Polygon references change whenever a tile is rebuilt, so polygons are
+// matched by their vertices instead. A polygon whose vertices moved is
+// reported as removed from the old build and added to the new one.
+package navdiff
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navigation"
+)
+
+// DEFAULT_TOLERANCE is the default of Options.Tolerance.
+const DEFAULT_TOLERANCE = 0.01
+
+// Options configures Compare. Zero fields get their defaults.
+type Options struct {
+	Tolerance  float32             // Distance below which vertices are considered equal, in world units.
+	Landmarks  []Landmark          // Points whose reachability from each other is compared.
+	Navigation *navigation.Options // Options of the path searches between landmarks.
+}
+
+// Landmark is a named point of interest, such as a spawn point or an objective.
+type Landmark struct {
+	Name string     `json:"name"`
+	Pos  [3]float32 `json:"pos"`
+}
+
+// TileLocation is the location of a tile in the tile grid.
+type TileLocation struct {
+	X     int32 `json:"x"`
+	Y     int32 `json:"y"`
+	Layer int32 `json:"layer"`
+}
+
+// TileChange tells how a tile changed.
+type TileChange string
+
+const (
+	TILE_ADDED   TileChange = "added"   // The tile is only in the new build.
+	TILE_REMOVED TileChange = "removed" // The tile is only in the old build.
+	TILE_CHANGED TileChange = "changed" // The tile is in both builds, with different polygons.
+)
+
+// Poly is a polygon added or removed.
+type Poly struct {
+	Ref    detour.DtPolyRef `json:"ref"`    // Reference in the build it is part of.
+	Type   string           `json:"type"`   // "ground" or "offmesh".
+	Center [3]float32       `json:"center"` // Center of the polygon, or middle of the off-mesh connection.
+	Area   uint8            `json:"area"`
+	Flags  uint16           `json:"flags"`
+}
+
+// AreaChange is a polygon in both builds whose area or flags changed.
+type AreaChange struct {
+	OldRef   detour.DtPolyRef `json:"oldRef"`
+	NewRef   detour.DtPolyRef `json:"newRef"`
+	Center   [3]float32       `json:"center"`
+	OldArea  uint8            `json:"oldArea"`
+	NewArea  uint8            `json:"newArea"`
+	OldFlags uint16           `json:"oldFlags"`
+	NewFlags uint16           `json:"newFlags"`
+}
+
+// LinkChange is a polygon in both builds whose neighbours changed. The
+// neighbours are given by their centers.
+type LinkChange struct {
+	OldRef  detour.DtPolyRef `json:"oldRef"`
+	NewRef  detour.DtPolyRef `json:"newRef"`
+	Center  [3]float32       `json:"center"`
+	Added   [][3]float32     `json:"added,omitempty"`   // Neighbours only linked in the new build.
+	Removed [][3]float32     `json:"removed,omitempty"` // Neighbours only linked in the old build.
+}
+
+// TileDiff is the difference between the two builds of a tile. The polygon
+// lists are only filled for changed tiles.
+type TileDiff struct {
+	Tile         TileLocation `json:"tile"`
+	Change       TileChange   `json:"change"`
+	OldPolys     int          `json:"oldPolys"`
+	NewPolys     int          `json:"newPolys"`
+	AddedPolys   []Poly       `json:"addedPolys,omitempty"`
+	RemovedPolys []Poly       `json:"removedPolys,omitempty"`
+	AreaChanges  []AreaChange `json:"areaChanges,omitempty"`
+	LinkChanges  []LinkChange `json:"linkChanges,omitempty"`
+}
+
+// Reachability tells whether a landmark can be reached from another.
+type Reachability string
+
+const (
+	REACHABLE   Reachability = "reachable"
+	UNREACHABLE Reachability = "unreachable"
+	NOT_ON_MESH Reachability = "not-on-mesh" // No polygon near one of the landmarks.
+	UNKNOWN     Reachability = "unknown"     // The search ran out of nodes.
+)
+
+// ReachabilityChange is a pair of landmarks whose reachability changed.
+type ReachabilityChange struct {
+	From string       `json:"from"`
+	To   string       `json:"to"`
+	Old  Reachability `json:"old"`
+	New  Reachability `json:"new"`
+}
+
+// Report is the result of Compare.
+type Report struct {
+	Tiles         []TileDiff           `json:"tiles"`         // Tiles which changed, by location.
+	LandmarkPairs int                  `json:"landmarkPairs"` // Number of landmark pairs compared.
+	Reachability  []ReachabilityChange `json:"reachability"`  // Landmark pairs whose reachability changed.
+}
+
+// Changed reports whether any difference was found.
+func (this *Report) Changed() bool {
+	return len(this.Tiles) != 0 || len(this.Reachability) != 0
+}
+
+// WriteJSON writes the report as indented JSON.
+func (this *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(this)
+}
+
+// Compare compares the old and the new build of a navmesh. The error only
+// reports invalid navigation options.
+func Compare(oldMesh, newMesh *detour.DtNavMesh, opts *Options) (*Report, error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Tolerance <= 0 {
+		o.Tolerance = DEFAULT_TOLERANCE
+	}
+	report := &Report{Tiles: []TileDiff{}, Reachability: []ReachabilityChange{}}
+
+	oldTiles, newTiles := tilesOf(oldMesh), tilesOf(newMesh)
+	locs := make([]TileLocation, 0, len(oldTiles)+len(newTiles))
+	for loc := range oldTiles {
+		locs = append(locs, loc)
+	}
+	for loc := range newTiles {
+		if _, ok := oldTiles[loc]; !ok {
+			locs = append(locs, loc)
+		}
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		a, b := locs[i], locs[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Layer < b.Layer
+	})
+
+	oldSide := &side{nav: oldMesh, tol: o.Tolerance}
+	newSide := &side{nav: newMesh, tol: o.Tolerance}
+	for _, loc := range locs {
+		oldTile, newTile := oldTiles[loc], newTiles[loc]
+		switch {
+		case newTile == nil:
+			report.Tiles = append(report.Tiles, TileDiff{Tile: loc, Change: TILE_REMOVED,
+				OldPolys: int(oldTile.Header.PolyCount)})
+		case oldTile == nil:
+			report.Tiles = append(report.Tiles, TileDiff{Tile: loc, Change: TILE_ADDED,
+				NewPolys: int(newTile.Header.PolyCount)})
+		default:
+			if diff := compareTile(oldSide, newSide, oldTile, newTile); diff != nil {
+				diff.Tile = loc
+				report.Tiles = append(report.Tiles, *diff)
+			}
+		}
+	}
+
+	if len(o.Landmarks) > 1 {
+		if err := compareReachability(oldMesh, newMesh, &o, report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// tilesOf returns the tiles of a navmesh by location. The tiles of the
+// off-mesh connections added at runtime are left out.
+func tilesOf(nav *detour.DtNavMesh) map[TileLocation]*detour.DtMeshTile {
+	tiles := make(map[TileLocation]*detour.DtMeshTile)
+	for i := 0; i < int(nav.GetMaxTiles()); i++ {
+		tile := nav.GetTile(i)
+		if tile.Header == nil || (tile.Flags&detour.DT_TILE_OFFMESH_CONNECTION) != 0 {
+			continue
+		}
+		tiles[TileLocation{X: tile.Header.X, Y: tile.Header.Y, Layer: tile.Header.Layer}] = tile
+	}
+	return tiles
+}
+
+// polyKey identifies a polygon by its vertices, rounded to the tolerance and
+// starting from the smallest one.
+type polyKey struct {
+	n     int
+	verts [detour.DT_VERTS_PER_POLYGON][3]int32
+}
+
+// side is one of the builds compared.
+type side struct {
+	nav *detour.DtNavMesh
+	tol float32
+}
+
+func (this *side) key(tile *detour.DtMeshTile, poly *detour.DtPoly) polyKey {
+	key := polyKey{n: int(poly.VertCount)}
+	first := 0
+	for k := 0; k < key.n; k++ {
+		v := tile.Verts[int(poly.Verts[k])*3:]
+		for c := 0; c < 3; c++ {
+			key.verts[k][c] = int32(math.Round(float64(v[c] / this.tol)))
+		}
+		if less(key.verts[k], key.verts[first]) {
+			first = k
+		}
+	}
+	var rotated [detour.DT_VERTS_PER_POLYGON][3]int32
+	for k := 0; k < key.n; k++ {
+		rotated[k] = key.verts[(first+k)%key.n]
+	}
+	key.verts = rotated
+	return key
+}
+
+func less(a, b [3]int32) bool {
+	for c := 0; c < 3; c++ {
+		if a[c] != b[c] {
+			return a[c] < b[c]
+		}
+	}
+	return false
+}
+
+func center(tile *detour.DtMeshTile, poly *detour.DtPoly) [3]float32 {
+	var c [3]float32
+	for k := 0; k < int(poly.VertCount); k++ {
+		v := tile.Verts[int(poly.Verts[k])*3:]
+		c[0] += v[0]
+		c[1] += v[1]
+		c[2] += v[2]
+	}
+	s := 1 / float32(poly.VertCount)
+	return [3]float32{c[0] * s, c[1] * s, c[2] * s}
+}
+
+func (this *side) poly(tile *detour.DtMeshTile, i int) Poly {
+	poly := &tile.Polys[i]
+	p := Poly{
+		Ref:    this.nav.GetPolyRefBase(tile) | detour.DtPolyRef(i),
+		Type:   "ground",
+		Center: center(tile, poly),
+		Area:   poly.GetArea(),
+		Flags:  poly.Flags,
+	}
+	if poly.GetType() == detour.DT_POLYTYPE_OFFMESH_CONNECTION {
+		p.Type = "offmesh"
+	}
+	return p
+}
+
+// neighbours returns the centers of the polygons polygon i links to.
+func (this *side) neighbours(tile *detour.DtMeshTile, i int) map[polyKey][3]float32 {
+	neis := make(map[polyKey][3]float32)
+	for l := tile.Polys[i].FirstLink; l != detour.DT_NULL_LINK; l = tile.Links[l].Next {
+		ref := tile.Links[l].Ref
+		if ref == 0 {
+			continue
+		}
+		var neiTile *detour.DtMeshTile
+		var neiPoly *detour.DtPoly
+		this.nav.GetTileAndPolyByRefUnsafe(ref, &neiTile, &neiPoly)
+		neis[this.key(neiTile, neiPoly)] = center(neiTile, neiPoly)
+	}
+	return neis
+}
+
+// compareTile compares the two builds of a tile, and returns nil when they
+// have the same polygons.
+func compareTile(oldSide, newSide *side, oldTile, newTile *detour.DtMeshTile) *TileDiff {
+	diff := &TileDiff{
+		Change:   TILE_CHANGED,
+		OldPolys: int(oldTile.Header.PolyCount),
+		NewPolys: int(newTile.Header.PolyCount),
+	}
+	newPolys := make(map[polyKey]int, diff.NewPolys)
+	for j := 0; j < diff.NewPolys; j++ {
+		newPolys[newSide.key(newTile, &newTile.Polys[j])] = j
+	}
+	matched := make([]bool, diff.NewPolys)
+	for i := 0; i < diff.OldPolys; i++ {
+		oldPoly := &oldTile.Polys[i]
+		j, ok := newPolys[oldSide.key(oldTile, oldPoly)]
+		if !ok || matched[j] {
+			diff.RemovedPolys = append(diff.RemovedPolys, oldSide.poly(oldTile, i))
+			continue
+		}
+		matched[j] = true
+		newPoly := &newTile.Polys[j]
+		oldRef := oldSide.nav.GetPolyRefBase(oldTile) | detour.DtPolyRef(i)
+		newRef := newSide.nav.GetPolyRefBase(newTile) | detour.DtPolyRef(j)
+		c := center(newTile, newPoly)
+		if oldPoly.GetArea() != newPoly.GetArea() || oldPoly.Flags != newPoly.Flags {
+			diff.AreaChanges = append(diff.AreaChanges, AreaChange{
+				OldRef:   oldRef,
+				NewRef:   newRef,
+				Center:   c,
+				OldArea:  oldPoly.GetArea(),
+				NewArea:  newPoly.GetArea(),
+				OldFlags: oldPoly.Flags,
+				NewFlags: newPoly.Flags,
+			})
+		}
+
+		oldNeis, newNeis := oldSide.neighbours(oldTile, i), newSide.neighbours(newTile, j)
+		change := LinkChange{OldRef: oldRef, NewRef: newRef, Center: c}
+		for key, pos := range newNeis {
+			if _, ok := oldNeis[key]; !ok {
+				change.Added = append(change.Added, pos)
+			}
+		}
+		for key, pos := range oldNeis {
+			if _, ok := newNeis[key]; !ok {
+				change.Removed = append(change.Removed, pos)
+			}
+		}
+		if len(change.Added) != 0 || len(change.Removed) != 0 {
+			sortPositions(change.Added)
+			sortPositions(change.Removed)
+			diff.LinkChanges = append(diff.LinkChanges, change)
+		}
+	}
+	for j := 0; j < diff.NewPolys; j++ {
+		if !matched[j] {
+			diff.AddedPolys = append(diff.AddedPolys, newSide.poly(newTile, j))
+		}
+	}
+
+	if len(diff.AddedPolys) == 0 && len(diff.RemovedPolys) == 0 &&
+		len(diff.AreaChanges) == 0 && len(diff.LinkChanges) == 0 {
+		return nil
+	}
+	return diff
+}
+
+func sortPositions(positions [][3]float32) {
+	sort.Slice(positions, func(i, j int) bool {
+		a, b := positions[i], positions[j]
+		for c := 0; c < 3; c++ {
+			if a[c] != b[c] {
+				return a[c] < b[c]
+			}
+		}
+		return false
+	})
+}
+
+// compareReachability searches a path between every ordered pair of
+// landmarks on both builds, since the paths may only lead one way.
+func compareReachability(oldMesh, newMesh *detour.DtNavMesh, opts *Options, report *Report) error {
+	oldNav, err := navigation.New(oldMesh, opts.Navigation)
+	if err != nil {
+		return err
+	}
+	newNav, err := navigation.New(newMesh, opts.Navigation)
+	if err != nil {
+		return err
+	}
+	for i, from := range opts.Landmarks {
+		for j, to := range opts.Landmarks {
+			if i == j {
+				continue
+			}
+			report.LandmarkPairs++
+			before := reachability(oldNav, from.Pos, to.Pos)
+			after := reachability(newNav, from.Pos, to.Pos)
+			if before != after {
+				report.Reachability = append(report.Reachability,
+					ReachabilityChange{From: from.Name, To: to.Name, Old: before, New: after})
+			}
+		}
+	}
+	return nil
+}
+
+func reachability(nav *navigation.Navigator, from, to navigation.Vec3) Reachability {
+	_, err := nav.PolyPath(from, to)
+	switch {
+	case err == nil:
+		return REACHABLE
+	case errors.Is(err, navigation.ErrNotFound):
+		return NOT_ON_MESH
+	case errors.Is(err, navigation.ErrOutOfNodes):
+		return UNKNOWN
+	default:
+		return UNREACHABLE
+	}
+}
@@ -0,0 +1,208 @@
+package navexport
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// glTF 2.0 constants.
+const (
+	gltfFloat        = 5126
+	gltfArrayBuffer  = 34962
+	gltfModeTriangle = 4
+)
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type gltfBuffer struct {
+	ByteLength int    `json:"byteLength"`
+	URI        string `json:"uri"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target,omitempty"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type gltfPBR struct {
+	BaseColorFactor [4]float32 `json:"baseColorFactor"`
+	MetallicFactor  float32    `json:"metallicFactor"`
+	RoughnessFactor float32    `json:"roughnessFactor"`
+}
+
+type gltfMaterial struct {
+	Name                 string  `json:"name"`
+	PbrMetallicRoughness gltfPBR `json:"pbrMetallicRoughness"`
+	DoubleSided          bool    `json:"doubleSided"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Material   int            `json:"material"`
+	Mode       int            `json:"mode"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfNode struct {
+	Name string `json:"name"`
+	Mesh int    `json:"mesh"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfDocument struct {
+	Asset       gltfAsset        `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes"`
+	Materials   []gltfMaterial   `json:"materials"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+}
+
+type gltfBuilder struct {
+	doc  gltfDocument
+	data bytes.Buffer
+}
+
+func (this *gltfBuilder) addAccessor(values []float32, typ string, count int, minmax bool) int {
+	view := gltfBufferView{
+		Buffer:     0,
+		ByteOffset: this.data.Len(),
+		ByteLength: len(values) * 4,
+		Target:     gltfArrayBuffer,
+	}
+	binary.Write(&this.data, binary.LittleEndian, values)
+	this.doc.BufferViews = append(this.doc.BufferViews, view)
+
+	accessor := gltfAccessor{
+		BufferView:    len(this.doc.BufferViews) - 1,
+		ComponentType: gltfFloat,
+		Count:         count,
+		Type:          typ,
+	}
+	if minmax && count > 0 {
+		n := len(values) / count
+		accessor.Min = make([]float32, n)
+		accessor.Max = make([]float32, n)
+		for c := 0; c < n; c++ {
+			accessor.Min[c] = math.MaxFloat32
+			accessor.Max[c] = -math.MaxFloat32
+		}
+		for i := 0; i < len(values); i++ {
+			c := i % n
+			if values[i] < accessor.Min[c] {
+				accessor.Min[c] = values[i]
+			}
+			if values[i] > accessor.Max[c] {
+				accessor.Max[c] = values[i]
+			}
+		}
+	}
+	this.doc.Accessors = append(this.doc.Accessors, accessor)
+	return len(this.doc.Accessors) - 1
+}
+
+// WriteGLTF writes the detail mesh of navMesh as a self-contained glTF 2.0
+// document (.gltf) with the binary buffer embedded as a data URI.
+//
+// There is one primitive per area id, each using an area coloured material.
+// Vertices are not shared between triangles, so per-polygon data can be
+// stored as custom vertex attributes:
+//
+//	_AREA     area id
+//	_FLAGS    polygon flags
+//	_POLYREF  poly ref, as a VEC4 of 16-bit parts, least significant first
+//
+// The poly ref is split in 16-bit parts so each is exact as a float, and
+// 64-bit references (the dtpolyref64 build tag) are exported whole. With
+// 32-bit references the last two parts are 0.
+func WriteGLTF(w io.Writer, navMesh *detour.DtNavMesh) error {
+	tris := CollectTriangles(navMesh)
+
+	b := &gltfBuilder{}
+	b.doc.Asset = gltfAsset{Version: "2.0", Generator: "recastnavigation-go navexport"}
+	mesh := gltfMesh{Name: "navmesh"}
+
+	for _, area := range usedAreas(tris) {
+		var pos, areas, flags, refs []float32
+		for i := range tris {
+			tri := &tris[i]
+			if tri.Area != area {
+				continue
+			}
+			pos = append(pos, tri.Verts[:]...)
+			for k := 0; k < 3; k++ {
+				areas = append(areas, float32(tri.Area))
+				flags = append(flags, float32(tri.Flags))
+				ref := uint64(tri.Ref)
+				for part := uint(0); part < 4; part++ {
+					refs = append(refs, float32((ref>>(16*part))&0xffff))
+				}
+			}
+		}
+		count := len(pos) / 3
+
+		col := AreaColor(area)
+		b.doc.Materials = append(b.doc.Materials, gltfMaterial{
+			Name: fmt.Sprintf("area_%d", area),
+			PbrMetallicRoughness: gltfPBR{
+				BaseColorFactor: [4]float32{col[0], col[1], col[2], 1},
+				MetallicFactor:  0,
+				RoughnessFactor: 1,
+			},
+			DoubleSided: true,
+		})
+
+		mesh.Primitives = append(mesh.Primitives, gltfPrimitive{
+			Attributes: map[string]int{
+				"POSITION": b.addAccessor(pos, "VEC3", count, true),
+				"_AREA":    b.addAccessor(areas, "SCALAR", count, false),
+				"_FLAGS":   b.addAccessor(flags, "SCALAR", count, false),
+				"_POLYREF": b.addAccessor(refs, "VEC4", count, false),
+			},
+			Material: len(b.doc.Materials) - 1,
+			Mode:     gltfModeTriangle,
+		})
+	}
+
+	b.doc.Meshes = []gltfMesh{mesh}
+	b.doc.Nodes = []gltfNode{{Name: "navmesh", Mesh: 0}}
+	b.doc.Scenes = []gltfScene{{Nodes: []int{0}}}
+	b.doc.Buffers = []gltfBuffer{{
+		ByteLength: b.data.Len(),
+		URI:        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(b.data.Bytes()),
+	}}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(&b.doc)
+}
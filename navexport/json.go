@@ -0,0 +1,125 @@
+package navexport
+
+import (
+	"encoding/json"
+	"io"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// GraphLink is a link from a polygon to a neighbour.
+type GraphLink struct {
+	Ref  detour.DtPolyRef `json:"ref"`
+	Edge uint8            `json:"edge"`
+	// Side is 0xff for links inside the tile, otherwise the side of the tile
+	// the neighbour is on.
+	Side uint8 `json:"side"`
+}
+
+// GraphPoly is a polygon of the navigation graph.
+type GraphPoly struct {
+	Ref        detour.DtPolyRef `json:"ref"`
+	Type       string           `json:"type"`
+	Area       uint8            `json:"area"`
+	Flags      uint16           `json:"flags"`
+	Verts      [][3]float32     `json:"verts"`
+	Neighbours []GraphLink      `json:"neighbours"`
+}
+
+// GraphOffMeshConnection is an off-mesh connection of the navigation graph.
+type GraphOffMeshConnection struct {
+	Ref    detour.DtPolyRef `json:"ref"`
+	Start  [3]float32       `json:"start"`
+	End    [3]float32       `json:"end"`
+	Radius float32          `json:"radius"`
+	Bidir  bool             `json:"bidir"`
+	UserId uint32           `json:"userId"`
+}
+
+// GraphTile is a tile of the navigation graph.
+type GraphTile struct {
+	Ref                detour.DtTileRef         `json:"ref"`
+	X                  int32                    `json:"x"`
+	Y                  int32                    `json:"y"`
+	Layer              int32                    `json:"layer"`
+	Bmin               [3]float32               `json:"bmin"`
+	Bmax               [3]float32               `json:"bmax"`
+	Polys              []GraphPoly              `json:"polys"`
+	OffMeshConnections []GraphOffMeshConnection `json:"offMeshConnections"`
+}
+
+// Graph is the polygon graph of a navigation mesh.
+type Graph struct {
+	Params detour.DtNavMeshParams `json:"params"`
+	Tiles  []GraphTile            `json:"tiles"`
+}
+
+// BuildGraph collects the polygon graph of navMesh.
+func BuildGraph(navMesh *detour.DtNavMesh) *Graph {
+	graph := &Graph{Params: *navMesh.GetParams()}
+	for i := 0; i < int(navMesh.GetMaxTiles()); i++ {
+		tile := navMesh.GetTile(i)
+		if tile == nil || tile.Header == nil {
+			continue
+		}
+		graph.Tiles = append(graph.Tiles, buildGraphTile(navMesh, tile))
+	}
+	return graph
+}
+
+func buildGraphTile(navMesh *detour.DtNavMesh, tile *detour.DtMeshTile) GraphTile {
+	header := tile.Header
+	gt := GraphTile{
+		Ref:                navMesh.GetTileRef(tile),
+		X:                  header.X,
+		Y:                  header.Y,
+		Layer:              header.Layer,
+		Bmin:               header.Bmin,
+		Bmax:               header.Bmax,
+		Polys:              make([]GraphPoly, 0, header.PolyCount),
+		OffMeshConnections: make([]GraphOffMeshConnection, 0, header.OffMeshConCount),
+	}
+	base := navMesh.GetPolyRefBase(tile)
+
+	for i := 0; i < int(header.PolyCount); i++ {
+		poly := &tile.Polys[i]
+		gp := GraphPoly{
+			Ref:        base | detour.DtPolyRef(i),
+			Type:       "ground",
+			Area:       poly.GetArea(),
+			Flags:      poly.Flags,
+			Verts:      make([][3]float32, poly.VertCount),
+			Neighbours: []GraphLink{},
+		}
+		if poly.GetType() == detour.DT_POLYTYPE_OFFMESH_CONNECTION {
+			gp.Type = "offmesh"
+		}
+		for j := 0; j < int(poly.VertCount); j++ {
+			copy(gp.Verts[j][:], tile.Verts[uint32(poly.Verts[j])*3:])
+		}
+		for k := poly.FirstLink; k != detour.DT_NULL_LINK; k = tile.Links[k].Next {
+			link := &tile.Links[k]
+			gp.Neighbours = append(gp.Neighbours, GraphLink{Ref: link.Ref, Edge: link.Edge, Side: link.Side})
+		}
+		gt.Polys = append(gt.Polys, gp)
+	}
+
+	for i := 0; i < int(header.OffMeshConCount); i++ {
+		con := &tile.OffMeshCons[i]
+		gc := GraphOffMeshConnection{
+			Ref:    base | detour.DtPolyRef(con.Poly),
+			Radius: con.Rad,
+			Bidir:  con.Flags&detour.DT_OFFMESH_CON_BIDIR != 0,
+			UserId: con.UserId,
+		}
+		copy(gc.Start[:], con.Pos[0:3])
+		copy(gc.End[:], con.Pos[3:6])
+		gt.OffMeshConnections = append(gt.OffMeshConnections, gc)
+	}
+	return gt
+}
+
+// WriteJSON writes the polygon graph of navMesh as JSON.
+func WriteJSON(w io.Writer, navMesh *detour.DtNavMesh) error {
+	return json.NewEncoder(w).Encode(BuildGraph(navMesh))
+}
@@ -0,0 +1,102 @@
+// Package navexport writes navigation meshes in formats understood by
+// external tools: glTF 2.0 and Wavefront OBJ for the triangulated surface,
+// and JSON for the polygon graph.
+package navexport
+
+import (
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// Triangle is one detail mesh triangle together with the attributes of the
+// polygon it belongs to.
+type Triangle struct {
+	Verts [9]float32 // (ax, ay, az, bx, by, bz, cx, cy, cz)
+	Ref   detour.DtPolyRef
+	Area  uint8
+	Flags uint16
+}
+
+// CollectTriangles returns the detail triangles of every ground polygon of
+// every tile, in tile and polygon order. Polygons without a detail mesh are
+// triangulated as a fan.
+func CollectTriangles(navMesh *detour.DtNavMesh) []Triangle {
+	var tris []Triangle
+	for i := 0; i < int(navMesh.GetMaxTiles()); i++ {
+		tile := navMesh.GetTile(i)
+		if tile == nil || tile.Header == nil {
+			continue
+		}
+		tris = collectTileTriangles(navMesh, tile, tris)
+	}
+	return tris
+}
+
+func collectTileTriangles(navMesh *detour.DtNavMesh, tile *detour.DtMeshTile, tris []Triangle) []Triangle {
+	base := navMesh.GetPolyRefBase(tile)
+	for i := 0; i < int(tile.Header.PolyCount); i++ {
+		poly := &tile.Polys[i]
+		if poly.GetType() == detour.DT_POLYTYPE_OFFMESH_CONNECTION {
+			continue
+		}
+		tri := Triangle{
+			Ref:   base | detour.DtPolyRef(i),
+			Area:  poly.GetArea(),
+			Flags: poly.Flags,
+		}
+
+		if i >= len(tile.DetailMeshes) {
+			for j := 2; j < int(poly.VertCount); j++ {
+				copy(tri.Verts[0:3], tile.Verts[poly.Verts[0]*3:poly.Verts[0]*3+3])
+				copy(tri.Verts[3:6], tile.Verts[poly.Verts[j-1]*3:poly.Verts[j-1]*3+3])
+				copy(tri.Verts[6:9], tile.Verts[poly.Verts[j]*3:poly.Verts[j]*3+3])
+				tris = append(tris, tri)
+			}
+			continue
+		}
+
+		pd := &tile.DetailMeshes[i]
+		for j := 0; j < int(pd.TriCount); j++ {
+			t := tile.DetailTris[(pd.TriBase+uint32(j))*4:]
+			for k := 0; k < 3; k++ {
+				var v []float32
+				if t[k] < poly.VertCount {
+					v = tile.Verts[uint32(poly.Verts[t[k]])*3:]
+				} else {
+					v = tile.DetailVerts[(pd.VertBase+uint32(t[k]-poly.VertCount))*3:]
+				}
+				copy(tri.Verts[k*3:k*3+3], v[:3])
+			}
+			tris = append(tris, tri)
+		}
+	}
+	return tris
+}
+
+// AreaColor returns the colour used for an area id, matching the palette of
+// the Recast debug draw (duDebugDraw::areaToCol). Components are in [0, 1].
+func AreaColor(area uint8) [3]float32 {
+	if area == 0 || int(area) == detour.DT_MAX_AREAS-1 {
+		// Ground and the default walkable area: light blue.
+		return [3]float32{0, 192.0 / 255.0, 1}
+	}
+	bit := func(a, b uint8) uint8 { return (a & (1 << b)) >> b }
+	r := bit(area, 1) + bit(area, 3)*2 + 1
+	g := bit(area, 2) + bit(area, 4)*2 + 1
+	b := bit(area, 0) + bit(area, 5)*2 + 1
+	return [3]float32{float32(r) * 63 / 255, float32(g) * 63 / 255, float32(b) * 63 / 255}
+}
+
+// usedAreas returns the distinct area ids of tris in ascending order.
+func usedAreas(tris []Triangle) []uint8 {
+	var seen [256]bool
+	for i := range tris {
+		seen[tris[i].Area] = true
+	}
+	var areas []uint8
+	for a := 0; a < len(seen); a++ {
+		if seen[a] {
+			areas = append(areas, uint8(a))
+		}
+	}
+	return areas
+}
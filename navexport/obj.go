@@ -0,0 +1,56 @@
+package navexport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// WriteOBJ writes the detail mesh of navMesh as a Wavefront OBJ file.
+// Faces use one material per area id (named area_<id>, see WriteMTL); mtlName
+// is the file name written to the mtllib statement, or empty to omit it.
+// Each polygon's faces are preceded by a comment holding its poly ref, area
+// and flags:
+//
+//	# poly <ref> area <area> flags <flags>
+func WriteOBJ(w io.Writer, navMesh *detour.DtNavMesh, mtlName string) error {
+	tris := CollectTriangles(navMesh)
+	bw := bufio.NewWriter(w)
+
+	if mtlName != "" {
+		fmt.Fprintf(bw, "mtllib %s\n", mtlName)
+	}
+	for i := range tris {
+		v := &tris[i].Verts
+		fmt.Fprintf(bw, "v %f %f %f\nv %f %f %f\nv %f %f %f\n", v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7], v[8])
+	}
+
+	fmt.Fprintf(bw, "o navmesh\n")
+	var lastRef detour.DtPolyRef
+	area := -1
+	for i := range tris {
+		tri := &tris[i]
+		if i == 0 || tri.Ref != lastRef {
+			if int(tri.Area) != area {
+				area = int(tri.Area)
+				fmt.Fprintf(bw, "usemtl area_%d\n", area)
+			}
+			fmt.Fprintf(bw, "# poly %d area %d flags %d\n", tri.Ref, tri.Area, tri.Flags)
+			lastRef = tri.Ref
+		}
+		fmt.Fprintf(bw, "f %d %d %d\n", i*3+1, i*3+2, i*3+3)
+	}
+	return bw.Flush()
+}
+
+// WriteMTL writes the area coloured materials referenced by WriteOBJ.
+func WriteMTL(w io.Writer, navMesh *detour.DtNavMesh) error {
+	bw := bufio.NewWriter(w)
+	for _, area := range usedAreas(CollectTriangles(navMesh)) {
+		col := AreaColor(area)
+		fmt.Fprintf(bw, "newmtl area_%d\nKd %f %f %f\nKa 0 0 0\nd 1\nillum 1\n\n", area, col[0], col[1], col[2])
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,351 @@
+// Package navhpa implements hierarchical pathfinding (HPA*) over the tiles
+// of a navmesh.
+//
+// Every tile is a cluster. The abstract graph has a vertex for each polygon
+// linked to, or from, another tile, and two kinds of edges: links between
+// tiles, and the cheapest way across a tile from one of its entries to one of
+// its exits. Long queries search this small graph first, then refine the
+// result with FindPath restricted to a few tiles at a time, so the node pool
+// of the query never has to cover the whole distance.
+//
+// The graph follows tile changes through a DtTileListener: adding or removing
+// a tile, for example when a DtTileCache rebuilds it, only recomputes that
+// tile and its neighbours. An off-mesh connection added at runtime is a tile
+// of its own, whose neighbours are the tiles under its end points. Like the navmesh itself, a Graph can serve
+// concurrent FindPath calls as long as no tile changes at the same time.
+package navhpa
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+var (
+	ErrNotInGraph = errors.New("navhpa: polygon is not part of the graph")
+	ErrNoPath     = errors.New("navhpa: end is not reachable from start")
+)
+
+const infinity = float32(math.MaxFloat32)
+
+// cluster is the abstract view of one tile.
+type cluster struct {
+	tile    *detour.DtMeshTile
+	base    detour.DtPolyRef
+	centers [][3]float32 // Center of every polygon of the tile.
+	entries []int32      // Polygons linked from another tile.
+	exits   []int32      // Polygons linked to another tile.
+	entry   map[int32]int
+	exit    map[int32]int
+	costs   []float32 // Cost from entry i to exit j at i*len(exits)+j.
+}
+
+func (this *cluster) cost(entry, exit int) float32 {
+	return this.costs[entry*len(this.exits)+exit]
+}
+
+// Graph is the abstract graph of a navmesh, for one filter.
+type Graph struct {
+	mesh     *detour.DtNavMesh
+	filter   detour.DtQueryFilterI
+	clusters map[*detour.DtMeshTile]*cluster
+	offMesh  map[*detour.DtMeshTile]bool // Tiles of the runtime off-mesh connections.
+
+	// MaxRefineTiles is the maximum number of tiles of a single FindPath
+	// call when refining an abstract path.
+	MaxRefineTiles int
+}
+
+// DEFAULT_MAX_REFINE_TILES is the default of Graph.MaxRefineTiles.
+const DEFAULT_MAX_REFINE_TILES = 6
+
+// New builds the abstract graph of mesh for filter, and keeps it up to date
+// with tile changes until Close. The costs of the graph come from filter, so
+// the filter must not change while the graph is in use.
+func New(mesh *detour.DtNavMesh, filter detour.DtQueryFilterI) *Graph {
+	this := &Graph{
+		mesh:           mesh,
+		filter:         filter,
+		clusters:       make(map[*detour.DtMeshTile]*cluster),
+		offMesh:        make(map[*detour.DtMeshTile]bool),
+		MaxRefineTiles: DEFAULT_MAX_REFINE_TILES,
+	}
+	for i := 0; i < int(mesh.GetMaxTiles()); i++ {
+		if tile := mesh.GetTile(i); tile.Header != nil && (tile.Flags&detour.DT_TILE_OFFMESH_CONNECTION) != 0 {
+			this.offMesh[tile] = true
+		}
+	}
+	for i := 0; i < int(mesh.GetMaxTiles()); i++ {
+		if tile := mesh.GetTile(i); tile.Header != nil {
+			this.clusters[tile] = this.buildCluster(tile)
+		}
+	}
+	mesh.AddTileListener(this)
+	return this
+}
+
+// Close stops following the tile changes of the navmesh.
+func (this *Graph) Close() {
+	this.mesh.RemoveTileListener(this)
+}
+
+// NavMesh returns the navmesh of the graph.
+func (this *Graph) NavMesh() *detour.DtNavMesh {
+	return this.mesh
+}
+
+// Filter returns the filter of the graph.
+func (this *Graph) Filter() detour.DtQueryFilterI {
+	return this.filter
+}
+
+// Stats returns the number of clusters and of abstract vertices.
+func (this *Graph) Stats() (clusters, vertices int) {
+	for _, c := range this.clusters {
+		vertices += len(c.entries) + len(c.exits)
+	}
+	return len(this.clusters), vertices
+}
+
+// TileAdded implements detour.DtTileListener.
+func (this *Graph) TileAdded(nav *detour.DtNavMesh, ref detour.DtTileRef, tile *detour.DtMeshTile) {
+	if (tile.Flags & detour.DT_TILE_OFFMESH_CONNECTION) != 0 {
+		this.offMesh[tile] = true
+	}
+	this.clusters[tile] = this.buildCluster(tile)
+	this.rebuildNeighbours(tile)
+}
+
+// TileRemoved implements detour.DtTileListener.
+func (this *Graph) TileRemoved(nav *detour.DtNavMesh, ref detour.DtTileRef, tile *detour.DtMeshTile) {
+	delete(this.clusters, tile)
+	delete(this.offMesh, tile)
+	this.rebuildNeighbours(tile)
+}
+
+// rebuildNeighbours rebuilds the clusters of the tiles which may link to tile.
+func (this *Graph) rebuildNeighbours(tile *detour.DtMeshTile) {
+	for _, nei := range this.neighbours(tile) {
+		this.clusters[nei] = this.buildCluster(nei)
+	}
+}
+
+// neighbours returns the tiles which may link to tile: those around it,
+// including the other layers at its location, and the runtime off-mesh
+// connections with an end point on it. The neighbours of a connection are
+// the tiles under its end points.
+func (this *Graph) neighbours(tile *detour.DtMeshTile) []*detour.DtMeshTile {
+	const MAX_NEIS = 32
+	var neis [MAX_NEIS]*detour.DtMeshTile
+	n := 0
+	if this.offMesh[tile] {
+		var x, y [2]int32
+		con := &tile.OffMeshCons[0]
+		for k := 0; k < 2; k++ {
+			this.mesh.CalcTileLoc(con.Pos[k*3:], &x[k], &y[k])
+			if k == 0 || x[1] != x[0] || y[1] != y[0] {
+				n += this.mesh.GetTilesAt(x[k], y[k], neis[n:], MAX_NEIS-n)
+			}
+		}
+	} else {
+		x, y := tile.Header.X, tile.Header.Y
+		n = this.mesh.GetTilesAt(x, y, neis[:], MAX_NEIS)
+		for side := 0; side < 8; side++ {
+			n += this.mesh.GetNeighbourTilesAt(x, y, side, neis[n:], MAX_NEIS-n)
+		}
+	}
+	result := make([]*detour.DtMeshTile, 0, n)
+	for _, nei := range neis[:n] {
+		if nei != tile {
+			result = append(result, nei)
+		}
+	}
+	if this.offMesh[tile] {
+		return result
+	}
+	for conTile := range this.offMesh {
+		con := &conTile.OffMeshCons[0]
+		for k := 0; k < 2; k++ {
+			var x, y int32
+			this.mesh.CalcTileLoc(con.Pos[k*3:], &x, &y)
+			if x == tile.Header.X && y == tile.Header.Y {
+				result = append(result, conTile)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// inTile reports whether ref is a polygon of the tile whose polygons start at base.
+func (this *Graph) inTile(ref, base detour.DtPolyRef) bool {
+	return this.mesh.DecodePolyIdTile(ref) == this.mesh.DecodePolyIdTile(base) &&
+		this.mesh.DecodePolyIdSalt(ref) == this.mesh.DecodePolyIdSalt(base)
+}
+
+func (this *Graph) buildCluster(tile *detour.DtMeshTile) *cluster {
+	n := int32(tile.Header.PolyCount)
+	c := &cluster{
+		tile:    tile,
+		base:    this.mesh.GetPolyRefBase(tile),
+		centers: make([][3]float32, n),
+		entry:   make(map[int32]int),
+		exit:    make(map[int32]int),
+	}
+	for i := int32(0); i < n; i++ {
+		c.centers[i] = polyCenter(tile, &tile.Polys[i])
+	}
+
+	passes := func(i int32) bool {
+		return this.filter.PassFilter(c.base|detour.DtPolyRef(i), tile, &tile.Polys[i])
+	}
+
+	// Exits link to other tiles.
+	for i := int32(0); i < n; i++ {
+		poly := &tile.Polys[i]
+		for l := poly.FirstLink; l != detour.DT_NULL_LINK; l = tile.Links[l].Next {
+			if ref := tile.Links[l].Ref; ref != 0 && !this.inTile(ref, c.base) && passes(i) {
+				c.exit[i] = len(c.exits)
+				c.exits = append(c.exits, i)
+				break
+			}
+		}
+	}
+
+	// Entries are linked from other tiles.
+	for _, nei := range this.neighbours(tile) {
+		for i := int32(0); i < nei.Header.PolyCount; i++ {
+			poly := &nei.Polys[i]
+			for l := poly.FirstLink; l != detour.DT_NULL_LINK; l = nei.Links[l].Next {
+				ref := nei.Links[l].Ref
+				if ref == 0 || !this.inTile(ref, c.base) {
+					continue
+				}
+				ip := int32(this.mesh.DecodePolyIdPoly(ref))
+				if _, ok := c.entry[ip]; !ok && passes(ip) {
+					c.entry[ip] = len(c.entries)
+					c.entries = append(c.entries, ip)
+				}
+			}
+		}
+	}
+
+	c.costs = make([]float32, len(c.entries)*len(c.exits))
+	dist := make([]float32, n)
+	for i, e := range c.entries {
+		this.tileDijkstra(c, e, false, dist)
+		for j, x := range c.exits {
+			c.costs[i*len(c.exits)+j] = dist[x]
+		}
+	}
+	return c
+}
+
+// tileDijkstra computes the cost from source to every polygon of the tile of
+// c, without leaving the tile, or to source from every polygon when reverse
+// is set. Unreachable polygons get infinity.
+func (this *Graph) tileDijkstra(c *cluster, source int32, reverse bool, dist []float32) {
+	tile := c.tile
+	for i := range dist {
+		dist[i] = infinity
+	}
+
+	// Links within the tile, reversed if needed.
+	var rev [][]int32
+	if reverse {
+		rev = make([][]int32, len(dist))
+		for i := range dist {
+			poly := &tile.Polys[i]
+			for l := poly.FirstLink; l != detour.DT_NULL_LINK; l = tile.Links[l].Next {
+				if ref := tile.Links[l].Ref; ref != 0 && this.inTile(ref, c.base) {
+					j := this.mesh.DecodePolyIdPoly(ref)
+					rev[j] = append(rev[j], int32(i))
+				}
+			}
+		}
+	}
+
+	edgeCost := func(from, to int32) float32 {
+		fromRef, toRef := c.base|detour.DtPolyRef(from), c.base|detour.DtPolyRef(to)
+		return this.filter.GetCost(c.centers[from][:], c.centers[to][:],
+			0, nil, nil,
+			fromRef, tile, &tile.Polys[from],
+			toRef, tile, &tile.Polys[to])
+	}
+
+	open := &polyHeap{}
+	dist[source] = 0
+	heap.Push(open, polyItem{source, 0})
+	for open.Len() > 0 {
+		item := heap.Pop(open).(polyItem)
+		cur := item.poly
+		if item.dist > dist[cur] {
+			// Stale entry, the polygon was reached more cheaply since.
+			continue
+		}
+		relax := func(next int32, cost float32) {
+			ref := c.base | detour.DtPolyRef(next)
+			if !this.filter.PassFilter(ref, tile, &tile.Polys[next]) {
+				return
+			}
+			if d := dist[cur] + cost; d < dist[next] {
+				dist[next] = d
+				heap.Push(open, polyItem{next, d})
+			}
+		}
+		if reverse {
+			for _, prev := range rev[cur] {
+				relax(prev, edgeCost(prev, cur))
+			}
+			continue
+		}
+		poly := &tile.Polys[cur]
+		for l := poly.FirstLink; l != detour.DT_NULL_LINK; l = tile.Links[l].Next {
+			if ref := tile.Links[l].Ref; ref != 0 && this.inTile(ref, c.base) {
+				next := int32(this.mesh.DecodePolyIdPoly(ref))
+				relax(next, edgeCost(cur, next))
+			}
+		}
+	}
+}
+
+// polyCenter returns the center of a polygon, or the middle of an off-mesh
+// connection.
+func polyCenter(tile *detour.DtMeshTile, poly *detour.DtPoly) [3]float32 {
+	var center [3]float32
+	for i := 0; i < int(poly.VertCount); i++ {
+		v := tile.Verts[poly.Verts[i]*3:]
+		center[0] += v[0]
+		center[1] += v[1]
+		center[2] += v[2]
+	}
+	s := 1 / float32(poly.VertCount)
+	return [3]float32{center[0] * s, center[1] * s, center[2] * s}
+}
+
+type polyItem struct {
+	poly int32
+	dist float32
+}
+
+// polyHeap is a priority queue of polygons. A polygon is pushed again when
+// its distance decreases, and the stale entries are skipped when popped.
+type polyHeap []polyItem
+
+func (this polyHeap) Len() int            { return len(this) }
+func (this polyHeap) Less(i, j int) bool  { return this[i].dist < this[j].dist }
+func (this polyHeap) Swap(i, j int)       { this[i], this[j] = this[j], this[i] }
+func (this *polyHeap) Push(x interface{}) { *this = append(*this, x.(polyItem)) }
+func (this *polyHeap) Pop() interface{} {
+	old := *this
+	x := old[len(old)-1]
+	*this = old[:len(old)-1]
+	return x
+}
+
+func statusError(what string, status detour.DtStatus) error {
+	return fmt.Errorf("navhpa: %s failed (status 0x%08x)", what, uint32(status))
+}
@@ -0,0 +1,277 @@
+package navhpa
+
+import (
+	"container/heap"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// Waypoint is a polygon of an abstract path.
+type Waypoint struct {
+	Ref detour.DtPolyRef
+	Pos [3]float32 // Center of the polygon, or the start or end position.
+}
+
+// abstractNode is a vertex of the abstract search.
+type abstractNode struct {
+	ref    detour.DtPolyRef
+	goal   bool // The end position, reached from a polygon of the end tile.
+	cost   float32
+	total  float32
+	parent *abstractNode
+	index  int
+}
+
+type nodeKey struct {
+	ref  detour.DtPolyRef
+	goal bool
+}
+
+type nodeHeap []*abstractNode
+
+func (this nodeHeap) Len() int           { return len(this) }
+func (this nodeHeap) Less(i, j int) bool { return this[i].total < this[j].total }
+func (this nodeHeap) Swap(i, j int) {
+	this[i], this[j] = this[j], this[i]
+	this[i].index = i
+	this[j].index = j
+}
+func (this *nodeHeap) Push(x interface{}) {
+	node := x.(*abstractNode)
+	node.index = len(*this)
+	*this = append(*this, node)
+}
+func (this *nodeHeap) Pop() interface{} {
+	old := *this
+	node := old[len(old)-1]
+	*this = old[:len(old)-1]
+	return node
+}
+
+// clusterOf returns the cluster of the tile of ref.
+func (this *Graph) clusterOf(ref detour.DtPolyRef) (*cluster, int32, error) {
+	var tile *detour.DtMeshTile
+	var poly *detour.DtPoly
+	if detour.DtStatusFailed(this.mesh.GetTileAndPolyByRef(ref, &tile, &poly)) {
+		return nil, 0, ErrNotInGraph
+	}
+	c := this.clusters[tile]
+	if c == nil {
+		return nil, 0, ErrNotInGraph
+	}
+	return c, int32(this.mesh.DecodePolyIdPoly(ref)), nil
+}
+
+// AbstractPath searches the abstract graph from the start polygon to the end
+// polygon. The result starts at the start polygon, ends at the end polygon,
+// and contains the tile entries and exits in between. Each step stays within
+// one tile, or crosses from one tile to a neighbour.
+func (this *Graph) AbstractPath(startRef, endRef detour.DtPolyRef, startPos, endPos [3]float32) ([]Waypoint, float32, error) {
+	startCluster, startPoly, err := this.clusterOf(startRef)
+	if err != nil {
+		return nil, 0, err
+	}
+	endCluster, endPoly, err := this.clusterOf(endRef)
+	if err != nil {
+		return nil, 0, err
+	}
+	if startRef == endRef {
+		return []Waypoint{{startRef, startPos}, {endRef, endPos}}, 0, nil
+	}
+
+	// Costs from the start to the polygons of its tile, and from the polygons
+	// of the end tile to the end.
+	fromStart := make([]float32, startCluster.tile.Header.PolyCount)
+	this.tileDijkstra(startCluster, startPoly, false, fromStart)
+	toEnd := make([]float32, endCluster.tile.Header.PolyCount)
+	this.tileDijkstra(endCluster, endPoly, true, toEnd)
+
+	nodes := make(map[nodeKey]*abstractNode)
+	open := &nodeHeap{}
+	center := func(ref detour.DtPolyRef) [3]float32 {
+		c, ip, _ := this.clusterOf(ref)
+		return c.centers[ip]
+	}
+	push := func(key nodeKey, parent *abstractNode, cost float32) {
+		node := nodes[key]
+		if node != nil && cost >= node.cost {
+			return
+		}
+		var heuristic float32
+		if !key.goal {
+			pos := center(key.ref)
+			heuristic = detour.DtVdist(pos[:], endPos[:]) * detour.H_SCALE
+		}
+		if node == nil {
+			node = &abstractNode{ref: key.ref, goal: key.goal, index: -1}
+			nodes[key] = node
+		}
+		node.cost = cost
+		node.total = cost + heuristic
+		node.parent = parent
+		if node.index >= 0 {
+			heap.Fix(open, node.index)
+		} else {
+			heap.Push(open, node)
+		}
+	}
+
+	start := &abstractNode{ref: startRef, index: -1}
+	nodes[nodeKey{startRef, false}] = start
+	heap.Push(open, start)
+
+	for open.Len() > 0 {
+		node := heap.Pop(open).(*abstractNode)
+		node.index = -1
+		if node.goal {
+			return this.waypoints(node, startPos, endPos), node.cost, nil
+		}
+
+		c, ip, err := this.clusterOf(node.ref)
+		if err != nil {
+			continue
+		}
+		if node == start {
+			for _, x := range startCluster.exits {
+				if fromStart[x] != infinity {
+					push(nodeKey{startCluster.base | detour.DtPolyRef(x), false}, node, fromStart[x])
+				}
+			}
+			if startCluster == endCluster && fromStart[endPoly] != infinity {
+				push(nodeKey{endRef, true}, node, fromStart[endPoly]+this.endCost(endCluster, endPoly, endPos))
+			}
+		}
+		if c == endCluster && toEnd[ip] != infinity {
+			push(nodeKey{endRef, true}, node, node.cost+toEnd[ip]+this.endCost(endCluster, endPoly, endPos))
+		}
+		// Across the tile.
+		if i, ok := c.entry[ip]; ok {
+			for j, x := range c.exits {
+				if cost := c.cost(i, j); cost != infinity {
+					push(nodeKey{c.base | detour.DtPolyRef(x), false}, node, node.cost+cost)
+				}
+			}
+		}
+		// To the neighbour tiles.
+		if _, ok := c.exit[ip]; ok {
+			poly := &c.tile.Polys[ip]
+			for l := poly.FirstLink; l != detour.DT_NULL_LINK; l = c.tile.Links[l].Next {
+				ref := c.tile.Links[l].Ref
+				if ref == 0 || this.inTile(ref, c.base) {
+					continue
+				}
+				nc, nip, err := this.clusterOf(ref)
+				if err != nil || !this.filter.PassFilter(ref, nc.tile, &nc.tile.Polys[nip]) {
+					continue
+				}
+				cost := this.filter.GetCost(c.centers[ip][:], nc.centers[nip][:],
+					0, nil, nil,
+					node.ref, c.tile, poly,
+					ref, nc.tile, &nc.tile.Polys[nip])
+				push(nodeKey{ref, false}, node, node.cost+cost)
+			}
+		}
+	}
+	return nil, 0, ErrNoPath
+}
+
+// endCost is the cost from the center of the end polygon to the end position.
+func (this *Graph) endCost(c *cluster, ip int32, endPos [3]float32) float32 {
+	ref := c.base | detour.DtPolyRef(ip)
+	return this.filter.GetCost(c.centers[ip][:], endPos[:],
+		0, nil, nil,
+		ref, c.tile, &c.tile.Polys[ip],
+		0, nil, nil)
+}
+
+func (this *Graph) waypoints(goal *abstractNode, startPos, endPos [3]float32) []Waypoint {
+	var path []Waypoint
+	for node := goal; node != nil; node = node.parent {
+		if node.goal {
+			path = append(path, Waypoint{node.ref, endPos})
+			continue
+		}
+		c, ip, _ := this.clusterOf(node.ref)
+		path = append(path, Waypoint{node.ref, c.centers[ip]})
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	path[0].Pos = startPos
+	// The end polygon may be on the path already, as an entry or exit.
+	if n := len(path); n > 2 && path[n-2].Ref == path[n-1].Ref {
+		path = append(path[:n-2], path[n-1])
+	}
+	return path
+}
+
+// corridorFilter restricts a filter to a set of tiles.
+type corridorFilter struct {
+	detour.DtQueryFilterI
+	tiles map[*detour.DtMeshTile]bool
+}
+
+func (this *corridorFilter) PassFilter(ref detour.DtPolyRef, tile *detour.DtMeshTile, poly *detour.DtPoly) bool {
+	return this.tiles[tile] && this.DtQueryFilterI.PassFilter(ref, tile, poly)
+}
+
+// FindPath finds the polygon corridor from the start polygon to the end
+// polygon, at most maxPath polygons long. It searches the abstract graph,
+// then refines the result with query.FindPath, each call restricted to the
+// tiles of at most MaxRefineTiles consecutive waypoints.
+//
+// When a refinement step cannot complete, for example because query runs out
+// of nodes, the corridor found so far is returned with an error.
+func (this *Graph) FindPath(query *detour.DtNavMeshQuery, startRef, endRef detour.DtPolyRef,
+	startPos, endPos [3]float32, maxPath int) ([]detour.DtPolyRef, error) {
+	waypoints, _, err := this.AbstractPath(startRef, endRef, startPos, endPos)
+	if err != nil {
+		return nil, err
+	}
+	maxTiles := this.MaxRefineTiles
+	if maxTiles < 2 {
+		maxTiles = 2
+	}
+
+	path := make([]detour.DtPolyRef, 0, maxPath)
+	buf := make([]detour.DtPolyRef, maxPath)
+	filter := &corridorFilter{DtQueryFilterI: this.filter}
+	for a := 0; a < len(waypoints)-1; {
+		// Extend the segment while it spans few enough tiles.
+		filter.tiles = make(map[*detour.DtMeshTile]bool)
+		b := a
+		for b < len(waypoints) {
+			c, _, err := this.clusterOf(waypoints[b].Ref)
+			if err != nil {
+				return path, err
+			}
+			if !filter.tiles[c.tile] && len(filter.tiles) == maxTiles {
+				break
+			}
+			filter.tiles[c.tile] = true
+			b++
+		}
+		b--
+
+		from, to := waypoints[a], waypoints[b]
+		var count int
+		status := query.FindPath(from.Ref, to.Ref, from.Pos[:], to.Pos[:], filter, buf, &count, maxPath)
+		if detour.DtStatusFailed(status) {
+			return path, statusError("refining path", status)
+		}
+		// The segments share their end polygons.
+		segment := buf[:count]
+		if len(path) > 0 {
+			segment = segment[1:]
+		}
+		if len(path)+len(segment) > maxPath {
+			return append(path, segment[:maxPath-len(path)]...), statusError("refining path", detour.DT_SUCCESS|detour.DT_BUFFER_TOO_SMALL)
+		}
+		path = append(path, segment...)
+		if detour.DtStatusDetail(status, detour.DT_PARTIAL_RESULT) {
+			return path, statusError("refining path", status)
+		}
+		a = b
+	}
+	return path, nil
+}
@@ -0,0 +1,171 @@
+package navigation
+
+import (
+	"math"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// Unreachable is the distance and cost of targets which cannot be reached.
+var Unreachable = float32(math.Inf(1))
+
+// PathDetails describes a path found by Navigator.PathDetails.
+type PathDetails struct {
+	Points   []Vec3    // Way points, with a point wherever the area changes.
+	Areas    []uint8   // Area id of each segment, from Points[i] to Points[i+1].
+	Corridor []PolyRef // Polygons of the path.
+	Length   float32   // Length of the path through the way points.
+	Cost     float32   // Cost of the path by the filter, as found by the search.
+}
+
+// PathDetails is Path also returning the corridor, the cost of the path by
+// the filter, its length, and the area of each segment. Errors are those of
+// Path. For a partial path the cost is the one to the last polygon.
+func (this *Navigator) PathDetails(start, end Vec3) (PathDetails, error) {
+	var details PathDetails
+	corridor, startPos, endPos, status, err := this.polyPath(nil, start, end)
+	if err != nil {
+		return details, err
+	}
+	if corridor == nil {
+		return details, statusError("find path", status)
+	}
+	last := corridor[len(corridor)-1]
+	if detour.DtStatusDetail(status, detour.DT_PARTIAL_RESULT) {
+		this.query.ClosestPointOnPoly(last, end[:], endPos[:], nil)
+	}
+	if len(corridor) > 1 {
+		if costStatus := this.query.GetPathCost(last, &details.Cost); detour.DtStatusFailed(costStatus) {
+			return details, statusError("get path cost", costStatus)
+		}
+	} else {
+		// FindPath does not search within a single polygon.
+		details.Cost = this.segmentCost(last, startPos, endPos)
+	}
+	details.Corridor = append([]PolyRef(nil), corridor...)
+
+	// Split the segments where the area changes, each segment is then in
+	// the area of the polygon entered at its start.
+	refs := make([]PolyRef, len(this.straightFlags))
+	var count int
+	straightStatus := this.query.FindStraightPath(startPos[:], endPos[:], corridor, len(corridor),
+		this.straightPath, nil, refs, &count, len(refs), detour.DT_STRAIGHTPATH_AREA_CROSSINGS)
+	if detour.DtStatusFailed(straightStatus) {
+		return details, statusError("find straight path", straightStatus)
+	}
+	status |= straightStatus & detour.DT_STATUS_DETAIL_MASK
+
+	details.Points = make([]Vec3, count)
+	for i := range details.Points {
+		copy(details.Points[i][:], this.straightPath[i*3:i*3+3])
+	}
+	if count > 1 {
+		details.Areas = make([]uint8, count-1)
+		for i := range details.Areas {
+			this.mesh.GetPolyArea(refs[i], &details.Areas[i])
+		}
+	}
+	details.Length = detour.DtStraightPathLength(this.straightPath, count)
+	return details, statusError("find path", status)
+}
+
+// segmentCost is the cost of going from a to b within a polygon.
+func (this *Navigator) segmentCost(ref PolyRef, a, b Vec3) float32 {
+	var tile *detour.DtMeshTile
+	var poly *detour.DtPoly
+	this.mesh.GetTileAndPolyByRefUnsafe(ref, &tile, &poly)
+	return this.opts.Filter.GetCost(a[:], b[:], 0, nil, nil, ref, tile, poly, 0, nil, nil)
+}
+
+// PathCosts returns the cost by the filter of the path from start to each
+// target, using a single search. Targets which cannot be reached, or cost
+// more than maxCost, get Unreachable. A maxCost of zero or less means no
+// limit.
+//
+// The costs are those of FindPath: with the default area costs they are
+// lengths through the polygon edge midpoints, at least the real path
+// lengths. Use PathDistances for the lengths of the string pulled paths.
+//
+// The error matches ErrNotFound when no polygon is found near start, and
+// ErrOutOfNodes when the search could not reach every target.
+func (this *Navigator) PathCosts(start Vec3, targets []Vec3, maxCost float32) ([]float32, error) {
+	costs, _, _, err := this.pathCosts(start, targets, maxCost)
+	return costs, err
+}
+
+// PathDistances returns the length of the path from start to each target,
+// using a single search, for example to score targets by walking distance
+// instead of straight-line distance. Targets which cannot be reached, or
+// cost more than maxCost, get Unreachable. Errors are those of PathCosts.
+func (this *Navigator) PathDistances(start Vec3, targets []Vec3, maxCost float32) ([]float32, error) {
+	costs, from, ends, err := this.pathCosts(start, targets, maxCost)
+	if costs == nil {
+		return nil, err
+	}
+	for i, cost := range costs {
+		if cost == Unreachable {
+			continue
+		}
+		var count int
+		status := this.query.GetPathFromDijkstraSearch(ends[i].ref, this.path, &count, len(this.path))
+		if detour.DtStatusFailed(status) || count == 0 || this.path[0] != from.ref {
+			costs[i] = Unreachable
+			continue
+		}
+		if detour.DtStatusDetail(status, detour.DT_BUFFER_TOO_SMALL) && err == nil {
+			err = statusError("path distances", status)
+		}
+		var points int
+		status = this.query.FindStraightPath(from.pos[:], ends[i].pos[:], this.path, count,
+			this.straightPath, nil, nil, &points, len(this.straightPath)/3, 0)
+		if detour.DtStatusFailed(status) {
+			costs[i] = Unreachable
+			continue
+		}
+		costs[i] = detour.DtStraightPathLength(this.straightPath, points)
+	}
+	return costs, err
+}
+
+type target struct {
+	ref PolyRef
+	pos Vec3
+}
+
+// pathCosts implements PathCosts, also returning the polygon and position
+// of the start and of each target. The error only reports incomplete results
+// when costs is not nil.
+func (this *Navigator) pathCosts(start Vec3, targets []Vec3, maxCost float32) ([]float32, target, []target, error) {
+	startRef, startPos, err := this.Nearest(start)
+	if err != nil {
+		return nil, target{}, nil, err
+	}
+	if maxCost <= 0 {
+		maxCost = math.MaxFloat32
+	}
+
+	ends := make([]target, len(targets))
+	refs := make([]PolyRef, len(targets))
+	positions := make([]float32, len(targets)*3)
+	for i, t := range targets {
+		// Targets off the navmesh keep a zero ref and are not searched for.
+		if ref, pos, err := this.Nearest(t); err == nil {
+			ends[i] = target{ref, pos}
+			refs[i] = ref
+			copy(positions[i*3:], pos[:])
+		}
+	}
+
+	costs := make([]float32, len(targets))
+	status := this.query.FindPathCostsToMany(startRef, startPos[:], refs, positions, len(targets), maxCost,
+		this.opts.Filter, costs)
+	if detour.DtStatusFailed(status) {
+		return nil, target{}, nil, statusError("path costs", status)
+	}
+	for i := range costs {
+		if costs[i] == math.MaxFloat32 || costs[i] > maxCost {
+			costs[i] = Unreachable
+		}
+	}
+	return costs, target{startRef, startPos}, ends, statusError("path costs", status)
+}
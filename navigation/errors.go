@@ -0,0 +1,94 @@
+package navigation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// Sentinel errors. A *StatusError matches every sentinel whose status bit it
+// carries, so use errors.Is to test for them.
+var (
+	ErrFailure        = errors.New("navigation: operation failed")
+	ErrInvalidParam   = errors.New("navigation: invalid parameter")
+	ErrOutOfMemory    = errors.New("navigation: out of memory")
+	ErrOutOfNodes     = errors.New("navigation: search ran out of nodes")
+	ErrPartialResult  = errors.New("navigation: end not reached, returning best guess")
+	ErrBufferTooSmall = errors.New("navigation: result truncated")
+	ErrNotFound       = errors.New("navigation: no polygon found near position")
+	ErrStaleRef       = errors.New("navigation: polygon reference is stale")
+)
+
+var statusDetails = []struct {
+	status detour.DtStatus
+	err    error
+	name   string
+}{
+	{detour.DT_WRONG_MAGIC, nil, "wrong magic"},
+	{detour.DT_WRONG_VERSION, nil, "wrong version"},
+	{detour.DT_OUT_OF_MEMORY, ErrOutOfMemory, "out of memory"},
+	{detour.DT_INVALID_PARAM, ErrInvalidParam, "invalid param"},
+	{detour.DT_BUFFER_TOO_SMALL, ErrBufferTooSmall, "buffer too small"},
+	{detour.DT_OUT_OF_NODES, ErrOutOfNodes, "out of nodes"},
+	{detour.DT_PARTIAL_RESULT, ErrPartialResult, "partial result"},
+	{detour.DT_ALREADY_OCCUPIED, nil, "already occupied"},
+}
+
+// StatusError reports a failed Detour call, or a successful one whose result
+// is incomplete. In the latter case the call still returns its result.
+type StatusError struct {
+	Op     string
+	Status detour.DtStatus
+}
+
+func (this *StatusError) Error() string {
+	var details []string
+	for _, d := range statusDetails {
+		if detour.DtStatusDetail(this.Status, d.status) {
+			details = append(details, d.name)
+		}
+	}
+	what := "succeeded with"
+	if detour.DtStatusFailed(this.Status) {
+		what = "failed"
+	}
+	if len(details) == 0 {
+		return fmt.Sprintf("navigation: %s %s", this.Op, what)
+	}
+	if what == "failed" {
+		what += ":"
+	}
+	return fmt.Sprintf("navigation: %s %s %s", this.Op, what, strings.Join(details, ", "))
+}
+
+// Is reports whether target is ErrFailure for a failed status, or the
+// sentinel error of one of the detail bits of the status.
+func (this *StatusError) Is(target error) bool {
+	if target == ErrFailure {
+		return detour.DtStatusFailed(this.Status)
+	}
+	for _, d := range statusDetails {
+		if d.err != nil && target == d.err {
+			return detour.DtStatusDetail(this.Status, d.status)
+		}
+	}
+	return false
+}
+
+// statusError converts a Detour status to an error. Successful statuses
+// without detail bits return nil.
+func statusError(op string, status detour.DtStatus) error {
+	if !detour.DtStatusFailed(status) && (status&detour.DT_STATUS_DETAIL_MASK) == 0 {
+		return nil
+	}
+	return &StatusError{Op: op, Status: status}
+}
+
+// IsIncomplete reports whether err only says the result is partial or
+// truncated, so the returned value is still usable.
+func IsIncomplete(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && !detour.DtStatusFailed(se.Status)
+}
@@ -0,0 +1,139 @@
+package navigation
+
+import (
+	"errors"
+	"math"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// ErrUnreachable is returned by the flow field queries for positions the
+// field does not lead from.
+var ErrUnreachable = errors.New("navigation: target cannot be reached")
+
+// FLOW_LOOK_AHEAD is the number of polygons toward the target FlowDirection
+// string pulls through.
+const FLOW_LOOK_AHEAD = 8
+
+// FlowField holds the costs of the paths from every polygon to one target,
+// so that any number of agents can move toward it without a search each,
+// for example a horde chasing a player. Build it with Navigator.FlowField,
+// and again whenever the target moves to another polygon or the navmesh
+// changes.
+//
+// A FlowField is not changed by the queries, and may be used by several
+// Navigators at the same time.
+type FlowField struct {
+	field  *detour.DtDistanceField
+	filter detour.DtQueryFilterI
+}
+
+// FlowField builds the flow field toward target, covering the positions
+// whose path to target costs at most maxCost. A maxCost of zero or less
+// means no limit. The costs follow the off-mesh connections in their
+// direction of travel.
+//
+// The error matches ErrNotFound when no polygon is found near target, and
+// ErrOutOfNodes when the node pool was too small to cover every polygon; the
+// field is still usable in the latter case.
+func (this *Navigator) FlowField(target Vec3, maxCost float32) (*FlowField, error) {
+	ref, pos, err := this.Nearest(target)
+	if err != nil {
+		return nil, err
+	}
+	if maxCost <= 0 {
+		maxCost = math.MaxFloat32
+	}
+	field := detour.DtAllocDistanceField()
+	status := this.query.BuildDistanceField(ref, pos[:], maxCost, this.opts.Filter,
+		detour.DT_DISTANCEFIELD_TO_SOURCE, field)
+	if detour.DtStatusFailed(status) {
+		return nil, statusError("build flow field", status)
+	}
+	return &FlowField{field: field, filter: this.opts.Filter}, statusError("build flow field", status)
+}
+
+// Target returns the position on the navmesh the field leads to.
+func (this *FlowField) Target() Vec3 {
+	var target Vec3
+	copy(target[:], this.field.GetSourcePos())
+	return target
+}
+
+// Polys returns the number of polygons the field leads from.
+func (this *FlowField) Polys() int {
+	return this.field.GetPolyCount()
+}
+
+// FlowCost returns the cost by the field's filter of the path from pos to
+// the target of field, or Unreachable. The error matches ErrNotFound when
+// no polygon is found near pos. Like the costs of the distance field, it is
+// approximate and can differ by a few percent from that of the path Path
+// finds.
+func (this *Navigator) FlowCost(field *FlowField, pos Vec3) (float32, error) {
+	ref, pos, err := this.Nearest(pos)
+	if err != nil {
+		return Unreachable, err
+	}
+	var cost float32
+	if detour.DtStatusFailed(field.field.GetCostAt(ref, pos[:], field.filter, &cost)) {
+		return Unreachable, nil
+	}
+	return cost, nil
+}
+
+// FlowDirection returns the normalized direction to move in from pos toward
+// the target of field, or zero at the target. The direction heads for the
+// first corner of the path, so agents following it go around the walls.
+//
+// The error matches ErrNotFound when no polygon is found near pos, and
+// ErrUnreachable when the field does not lead from it.
+func (this *Navigator) FlowDirection(field *FlowField, pos Vec3) (Vec3, error) {
+	var dir Vec3
+	ref, pos, err := this.Nearest(pos)
+	if err != nil {
+		return dir, err
+	}
+	if field.field.GetParent(ref) == 0 && ref != field.field.GetSource() {
+		return dir, ErrUnreachable
+	}
+	status := this.query.GetFlowDirection(field.field, ref, pos[:], FLOW_LOOK_AHEAD, dir[:], nil)
+	if detour.DtStatusFailed(status) {
+		return dir, statusError("flow direction", status)
+	}
+	return dir, nil
+}
+
+// FlowPath returns the way points of the path from pos to the target of
+// field. Errors are those of FlowDirection, and ErrBufferTooSmall when the
+// path is longer than the navigator holds.
+func (this *Navigator) FlowPath(field *FlowField, pos Vec3) ([]Vec3, error) {
+	ref, pos, err := this.Nearest(pos)
+	if err != nil {
+		return nil, err
+	}
+	var count int
+	status := field.field.GetPath(ref, this.path, &count, len(this.path))
+	if detour.DtStatusFailed(status) {
+		return nil, ErrUnreachable
+	}
+	// A truncated corridor heads for the last polygon it holds.
+	target := field.Target()
+	if last := this.path[count-1]; last != field.field.GetSource() {
+		this.query.ClosestPointOnPoly(last, target[:], target[:], nil)
+	}
+
+	var points int
+	straightStatus := this.query.FindStraightPath(pos[:], target[:], this.path, count,
+		this.straightPath, nil, nil, &points, len(this.straightPath)/3, 0)
+	if detour.DtStatusFailed(straightStatus) {
+		return nil, statusError("find straight path", straightStatus)
+	}
+	status |= straightStatus & detour.DT_STATUS_DETAIL_MASK
+
+	result := make([]Vec3, points)
+	for i := range result {
+		copy(result[i][:], this.straightPath[i*3:i*3+3])
+	}
+	return result, statusError("flow path", status)
+}
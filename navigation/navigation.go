@@ -0,0 +1,280 @@
+// Package navigation is a high level facade over the Detour navmesh query.
+// It hides the preallocated buffers, out-counts and status bits of the raw
+// port behind methods returning slices and typed errors.
+//
+// Methods that can return an incomplete result (a partial path, a truncated
+// corridor) return the result together with a *StatusError; use errors.Is
+// with ErrPartialResult, ErrOutOfNodes or ErrBufferTooSmall, or IsIncomplete,
+// to tell them apart from failures.
+//
+// The Context variants give up when their context is done and return the
+// best partial result, with an error matching both the context error and
+// ErrPartialResult.
+package navigation
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// Vec3 is a position or direction in world units. (x, y, z) with y up.
+type Vec3 [3]float32
+
+// PolyRef is a polygon reference.
+type PolyRef = detour.DtPolyRef
+
+// Default option values.
+const (
+	DEFAULT_MAX_NODES         = 2048
+	DEFAULT_MAX_PATH          = 256
+	DEFAULT_MAX_STRAIGHT_PATH = 256
+)
+
+// DefaultHalfExtents is the default search box used to find the polygon
+// under a position.
+var DefaultHalfExtents = Vec3{2, 4, 2}
+
+// Options configures a Navigator. Zero fields get their defaults.
+type Options struct {
+	MaxNodes        int                   // Node pool size of the search. [Limit: >= 4]
+	MaxPath         int                   // Maximum number of polygons in a path corridor.
+	MaxStraightPath int                   // Maximum number of points in a path.
+	HalfExtents     Vec3                  // Search box used to find the polygon under a position.
+	Filter          detour.DtQueryFilterI // Polygon filter, a default DtQueryFilter when nil.
+}
+
+func (this *Options) withDefaults() Options {
+	var opts Options
+	if this != nil {
+		opts = *this
+	}
+	if opts.MaxNodes <= 0 {
+		opts.MaxNodes = DEFAULT_MAX_NODES
+	}
+	if opts.MaxPath <= 0 {
+		opts.MaxPath = DEFAULT_MAX_PATH
+	}
+	if opts.MaxStraightPath <= 0 {
+		opts.MaxStraightPath = DEFAULT_MAX_STRAIGHT_PATH
+	}
+	if opts.HalfExtents == (Vec3{}) {
+		opts.HalfExtents = DefaultHalfExtents
+	}
+	if opts.Filter == nil {
+		opts.Filter = detour.DtAllocDtQueryFilter()
+	}
+	return opts
+}
+
+// Hit is the result of a raycast.
+type Hit struct {
+	Hit    bool      // True if a wall was hit before reaching the end.
+	T      float32   // Hit parameter along the ray, in [0, 1]. 1 when nothing was hit.
+	Point  Vec3      // Hit point, or the end of the ray.
+	Normal Vec3      // Normal of the wall that was hit.
+	Path   []PolyRef // Polygons visited by the ray.
+}
+
+// Navigator answers path queries on a navmesh. A Navigator is not safe for
+// concurrent use; create one per goroutine or take them from a QueryPool.
+type Navigator struct {
+	mesh  *detour.DtNavMesh
+	query *detour.DtNavMeshQuery
+	opts  Options
+
+	path          []PolyRef
+	straightPath  []float32
+	straightFlags []detour.DtStraightPathFlags
+}
+
+// New creates a Navigator for mesh.
+func New(mesh *detour.DtNavMesh, opts *Options) (*Navigator, error) {
+	o := opts.withDefaults()
+	if o.MaxNodes < 4 {
+		// The node pool hash would be empty.
+		return nil, &StatusError{Op: "init query", Status: detour.DT_FAILURE | detour.DT_INVALID_PARAM}
+	}
+	query := detour.DtAllocNavMeshQuery()
+	if err := statusError("init query", query.Init(mesh, o.MaxNodes)); err != nil {
+		return nil, err
+	}
+	return &Navigator{
+		mesh:          mesh,
+		query:         query,
+		opts:          o,
+		path:          make([]PolyRef, o.MaxPath),
+		straightPath:  make([]float32, o.MaxStraightPath*3),
+		straightFlags: make([]detour.DtStraightPathFlags, o.MaxStraightPath),
+	}, nil
+}
+
+// NavMesh returns the navmesh queried.
+func (this *Navigator) NavMesh() *detour.DtNavMesh {
+	return this.mesh
+}
+
+// Query returns the underlying query object, for calls the facade does not cover.
+func (this *Navigator) Query() *detour.DtNavMeshQuery {
+	return this.query
+}
+
+// Filter returns the filter used by the queries.
+func (this *Navigator) Filter() detour.DtQueryFilterI {
+	return this.opts.Filter
+}
+
+// SetFilter changes the filter used by the queries. nil restores the default filter.
+func (this *Navigator) SetFilter(filter detour.DtQueryFilterI) {
+	if filter == nil {
+		filter = detour.DtAllocDtQueryFilter()
+	}
+	this.opts.Filter = filter
+}
+
+// Nearest returns the polygon nearest to pos within the search box, and the
+// closest point on it. It returns ErrNotFound when there is no polygon.
+func (this *Navigator) Nearest(pos Vec3) (PolyRef, Vec3, error) {
+	var ref PolyRef
+	var nearest Vec3
+	status := this.query.FindNearestPoly(pos[:], this.opts.HalfExtents[:], this.opts.Filter, &ref, nearest[:])
+	if err := statusError("find nearest poly", status); err != nil {
+		return 0, nearest, err
+	}
+	if ref == 0 {
+		return 0, nearest, ErrNotFound
+	}
+	return ref, nearest, nil
+}
+
+// PolyPath returns the polygon corridor from start to end.
+func (this *Navigator) PolyPath(start, end Vec3) ([]PolyRef, error) {
+	return this.findPolyPath(nil, start, end)
+}
+
+// PolyPathContext is PolyPath giving up when ctx is done. The corridor
+// found so far is then returned with an error matching both ctx.Err() and
+// ErrPartialResult.
+func (this *Navigator) PolyPathContext(ctx context.Context, start, end Vec3) ([]PolyRef, error) {
+	return this.findPolyPath(ctx, start, end)
+}
+
+// findPolyPath implements PolyPath, and PolyPathContext for a non-nil ctx.
+func (this *Navigator) findPolyPath(ctx context.Context, start, end Vec3) ([]PolyRef, error) {
+	corridor, _, _, status, err := this.polyPath(ctx, start, end)
+	if err == nil {
+		err = pathError(ctx, "find path", status)
+	}
+	if corridor == nil {
+		return nil, err
+	}
+	return append([]PolyRef(nil), corridor...), err
+}
+
+// polyPath finds the corridor from start to end. The error only reports
+// that no polygon was found near start or end.
+func (this *Navigator) polyPath(ctx context.Context, start, end Vec3) ([]PolyRef, Vec3, Vec3, detour.DtStatus, error) {
+	startRef, startPos, err := this.Nearest(start)
+	if err != nil {
+		return nil, start, end, detour.DT_FAILURE, err
+	}
+	endRef, endPos, err := this.Nearest(end)
+	if err != nil {
+		return nil, start, end, detour.DT_FAILURE, err
+	}
+
+	var count int
+	var status detour.DtStatus
+	if ctx == nil {
+		status = this.query.FindPath(startRef, endRef, startPos[:], endPos[:], this.opts.Filter, this.path, &count, len(this.path))
+	} else {
+		status = this.query.FindPathContext(ctx, startRef, endRef, startPos[:], endPos[:], this.opts.Filter, this.path, &count, len(this.path))
+	}
+	if detour.DtStatusFailed(status) {
+		return nil, startPos, endPos, status, nil
+	}
+	return this.path[:count], startPos, endPos, status, nil
+}
+
+// pathError is statusError, also wrapping the error of ctx when the search
+// was cut short by it.
+func pathError(ctx context.Context, op string, status detour.DtStatus) error {
+	err := statusError(op, status)
+	if ctx != nil && ctx.Err() != nil && detour.DtStatusDetail(status, detour.DT_PARTIAL_RESULT) {
+		return fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
+	return err
+}
+
+// Path returns the way points of the shortest path from start to end. When
+// the end cannot be reached the path leads to the closest reachable point
+// and the error matches ErrPartialResult.
+func (this *Navigator) Path(start, end Vec3) ([]Vec3, error) {
+	return this.findPath(nil, start, end)
+}
+
+// PathContext is Path giving up when ctx is done. The path then leads to
+// the point closest to end found so far, and the error matches both
+// ctx.Err() and ErrPartialResult.
+func (this *Navigator) PathContext(ctx context.Context, start, end Vec3) ([]Vec3, error) {
+	return this.findPath(ctx, start, end)
+}
+
+// findPath implements Path, and PathContext for a non-nil ctx.
+func (this *Navigator) findPath(ctx context.Context, start, end Vec3) ([]Vec3, error) {
+	corridor, startPos, endPos, status, err := this.polyPath(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if corridor == nil {
+		return nil, pathError(ctx, "find path", status)
+	}
+
+	// For partial paths, head for the closest point on the last polygon.
+	if detour.DtStatusDetail(status, detour.DT_PARTIAL_RESULT) {
+		this.query.ClosestPointOnPoly(corridor[len(corridor)-1], end[:], endPos[:], nil)
+	}
+
+	var count int
+	straightStatus := this.query.FindStraightPath(startPos[:], endPos[:], corridor, len(corridor),
+		this.straightPath, nil, nil, &count, len(this.straightPath)/3, 0)
+	if detour.DtStatusFailed(straightStatus) {
+		return nil, statusError("find straight path", straightStatus)
+	}
+	status |= straightStatus & detour.DT_STATUS_DETAIL_MASK
+
+	points := make([]Vec3, count)
+	for i := range points {
+		copy(points[i][:], this.straightPath[i*3:i*3+3])
+	}
+	return points, pathError(ctx, "find path", status)
+}
+
+// Raycast casts a ray along the surface of the navmesh from the polygon
+// under from towards to.
+func (this *Navigator) Raycast(from, to Vec3) (Hit, error) {
+	startRef, startPos, err := this.Nearest(from)
+	if err != nil {
+		return Hit{}, err
+	}
+
+	var t float32
+	var hit Hit
+	var count int
+	status := this.query.Raycast(startRef, startPos[:], to[:], this.opts.Filter, &t, hit.Normal[:], this.path, &count, len(this.path))
+	if err := statusError("raycast", status); detour.DtStatusFailed(status) {
+		return Hit{}, err
+	}
+	hit.Path = append([]PolyRef(nil), this.path[:count]...)
+	if t == math.MaxFloat32 {
+		hit.T = 1
+		hit.Point = to
+	} else {
+		hit.Hit = true
+		hit.T = t
+		detour.DtVlerp(hit.Point[:], startPos[:], to[:], t)
+	}
+	return hit, statusError("raycast", status)
+}
@@ -0,0 +1,209 @@
+package navigation
+
+import (
+	"context"
+	"sync"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// QueryPool hands out Navigators bound to one navmesh, so that many
+// goroutines can query it at the same time.
+//
+// A DtNavMeshQuery keeps its search state (node pools, open list, sliced
+// path state) in the object itself, so each goroutine needs its own; the
+// pool recycles them because allocating the node pool is not free.
+//
+// Concurrency guarantees:
+//
+// Any number of goroutines may query the navmesh concurrently through their
+// own Navigator or DtNavMeshQuery. The following DtNavMesh methods only read
+// the navmesh and are also safe to call concurrently with queries:
+// GetParams, GetMaxTiles, GetTile, GetTileAt, GetTilesAt, GetNeighbourTilesAt,
+// GetTileRefAt, GetTileByRef, GetTileRef, GetPolyRefBase, CalcTileLoc,
+// GetTileAndPolyByRef, GetTileAndPolyByRefUnsafe, IsValidPolyRef,
+// GetPolyFlags, GetPolyArea, GetOffMeshConnectionByRef,
+// GetOffMeshConnectionPolyEndPoints, GetTileStateSize and StoreTileState.
+// A shared DtQueryFilter is only read by queries and may be shared too.
+//
+// Every other DtNavMesh method mutates it, including Init, AddTile,
+// AddTileShared, RemoveTile, AddOffMeshConnection, RemoveOffMeshConnection,
+// RestoreTileState, SetPolyFlags and SetPolyArea, as does DtTileCache.Update
+// or BuildNavMeshTile with the navmesh. None of them may run while queries
+// run, unless all access goes through a SyncNavMesh and its query pool.
+type QueryPool struct {
+	mesh *detour.DtNavMesh
+	opts Options
+	pool sync.Pool
+	lock sync.Locker // Read lock of a SyncNavMesh, or nil.
+}
+
+// NewQueryPool creates a pool of Navigators for mesh. All Navigators share
+// opts, including the filter.
+func NewQueryPool(mesh *detour.DtNavMesh, opts *Options) (*QueryPool, error) {
+	this := &QueryPool{mesh: mesh, opts: opts.withDefaults()}
+	// Create the first navigator now to report invalid options early.
+	nav, err := New(mesh, &this.opts)
+	if err != nil {
+		return nil, err
+	}
+	this.pool.Put(nav)
+	return this, nil
+}
+
+// NavMesh returns the navmesh queried.
+func (this *QueryPool) NavMesh() *detour.DtNavMesh {
+	return this.mesh
+}
+
+// Get returns a Navigator owned by the caller until it is passed to Put.
+// For a pool created by SyncNavMesh.NewQueryPool, the Navigator is only
+// usable while holding the read lock; prefer Do.
+func (this *QueryPool) Get() *Navigator {
+	if nav, ok := this.pool.Get().(*Navigator); ok {
+		return nav
+	}
+	nav, err := New(this.mesh, &this.opts)
+	if err != nil {
+		// The options were validated by NewQueryPool.
+		panic(err)
+	}
+	return nav
+}
+
+// Put returns a Navigator to the pool. The filter is reset to the one of
+// the pool.
+func (this *QueryPool) Put(nav *Navigator) {
+	if nav == nil || nav.mesh != this.mesh {
+		return
+	}
+	nav.opts.Filter = this.opts.Filter
+	this.pool.Put(nav)
+}
+
+// Do calls fn with a Navigator from the pool, holding the read lock of the
+// SyncNavMesh if any.
+func (this *QueryPool) Do(fn func(nav *Navigator) error) error {
+	nav := this.acquire()
+	defer this.release(nav)
+	return fn(nav)
+}
+
+func (this *QueryPool) acquire() *Navigator {
+	if this.lock != nil {
+		this.lock.Lock()
+	}
+	return this.Get()
+}
+
+func (this *QueryPool) release(nav *Navigator) {
+	this.Put(nav)
+	if this.lock != nil {
+		this.lock.Unlock()
+	}
+}
+
+// Nearest is Navigator.Nearest using a pooled Navigator.
+func (this *QueryPool) Nearest(pos Vec3) (PolyRef, Vec3, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.Nearest(pos)
+}
+
+// PolyPath is Navigator.PolyPath using a pooled Navigator.
+func (this *QueryPool) PolyPath(start, end Vec3) ([]PolyRef, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.PolyPath(start, end)
+}
+
+// Path is Navigator.Path using a pooled Navigator.
+func (this *QueryPool) Path(start, end Vec3) ([]Vec3, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.Path(start, end)
+}
+
+// PolyPathContext is Navigator.PolyPathContext using a pooled Navigator.
+func (this *QueryPool) PolyPathContext(ctx context.Context, start, end Vec3) ([]PolyRef, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.PolyPathContext(ctx, start, end)
+}
+
+// PathContext is Navigator.PathContext using a pooled Navigator.
+func (this *QueryPool) PathContext(ctx context.Context, start, end Vec3) ([]Vec3, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.PathContext(ctx, start, end)
+}
+
+// Raycast is Navigator.Raycast using a pooled Navigator.
+func (this *QueryPool) Raycast(from, to Vec3) (Hit, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.Raycast(from, to)
+}
+
+// SmoothPath is Navigator.SmoothPath using a pooled Navigator.
+func (this *QueryPool) SmoothPath(start, end Vec3, opts *SmoothOptions) ([]Vec3, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.SmoothPath(start, end, opts)
+}
+
+// ValidatePath is Navigator.ValidatePath using a pooled Navigator.
+func (this *QueryPool) ValidatePath(points []Vec3) (int, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.ValidatePath(points)
+}
+
+// PathDetails is Navigator.PathDetails using a pooled Navigator.
+func (this *QueryPool) PathDetails(start, end Vec3) (PathDetails, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.PathDetails(start, end)
+}
+
+// PathCosts is Navigator.PathCosts using a pooled Navigator.
+func (this *QueryPool) PathCosts(start Vec3, targets []Vec3, maxCost float32) ([]float32, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.PathCosts(start, targets, maxCost)
+}
+
+// PathDistances is Navigator.PathDistances using a pooled Navigator.
+func (this *QueryPool) PathDistances(start Vec3, targets []Vec3, maxCost float32) ([]float32, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.PathDistances(start, targets, maxCost)
+}
+
+// FlowField is Navigator.FlowField using a pooled Navigator.
+func (this *QueryPool) FlowField(target Vec3, maxCost float32) (*FlowField, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.FlowField(target, maxCost)
+}
+
+// FlowDirection is Navigator.FlowDirection using a pooled Navigator.
+func (this *QueryPool) FlowDirection(field *FlowField, pos Vec3) (Vec3, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.FlowDirection(field, pos)
+}
+
+// Visibility is Navigator.Visibility using a pooled Navigator.
+func (this *QueryPool) Visibility(pos Vec3, radius float32) ([]Vec3, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.Visibility(pos, radius)
+}
+
+// LineOfSight is Navigator.LineOfSight using a pooled Navigator.
+func (this *QueryPool) LineOfSight(pos Vec3, targets []Vec3, maxDistance float32) ([]bool, error) {
+	nav := this.acquire()
+	defer this.release(nav)
+	return nav.LineOfSight(pos, targets, maxDistance)
+}
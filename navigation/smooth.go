@@ -0,0 +1,514 @@
+package navigation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// Corners selects how SmoothPath rounds the corners of a path.
+type Corners int
+
+const (
+	CORNERS_SHARP       Corners = iota // Keep the corners of the string pulled path.
+	CORNERS_ARC                        // Replace each corner with a circular arc.
+	CORNERS_CATMULL_ROM                // Pass a centripetal Catmull-Rom spline through the corners.
+)
+
+// DEFAULT_CURVE_SEGMENTS is the default of SmoothOptions.Segments.
+const DEFAULT_CURVE_SEGMENTS = 4
+
+// SmoothOptions configures SmoothPath. Zero fields get their defaults.
+type SmoothOptions struct {
+	Radius    float32 // Agent radius, kept between the path and the wall corners it turns around.
+	Corners   Corners // How the corners are rounded.
+	Segments  int     // Number of segments of each arc, or of the spline between two corners.
+	ArcRadius float32 // Turning radius of CORNERS_ARC, Radius when zero. Larger arcs pass closer to the wall corners.
+}
+
+// ErrBlocked is matched by the errors of ValidatePath.
+var ErrBlocked = errors.New("navigation: path is blocked")
+
+// BlockedError reports the first segment of a path crossing a wall.
+type BlockedError struct {
+	Segment int // The segment from point Segment to point Segment+1.
+	Hit     Hit
+}
+
+func (this *BlockedError) Error() string {
+	p := this.Hit.Point
+	return fmt.Sprintf("navigation: path segment %d is blocked at (%g, %g, %g)", this.Segment, p[0], p[1], p[2])
+}
+
+// Is reports whether target is ErrBlocked.
+func (this *BlockedError) Is(target error) bool {
+	return target == ErrBlocked
+}
+
+// SmoothPath returns the way points of the shortest path from start to end,
+// turning around the wall corners at opts.Radius from them, with the corners
+// of the path optionally rounded. Errors are those of Path.
+//
+// The corridor portals are shrunk by the radius before string pulling, see
+// detour.DtNavMeshQuery.FindStraightPathRadius, and the corners turning
+// around the same wall vertex are then merged and moved out so the segments
+// keep the radius from it. Arcs of at most the radius keep that
+// clearance, while larger arcs and the splines cut the corners closer.
+// Every rounded corner is checked with Raycast, and kept sharp when its
+// curve would cross a wall. Corners at the ends of off-mesh connections are
+// never rounded.
+func (this *Navigator) SmoothPath(start, end Vec3, opts *SmoothOptions) ([]Vec3, error) {
+	var o SmoothOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Segments <= 0 {
+		o.Segments = DEFAULT_CURVE_SEGMENTS
+	}
+	if o.ArcRadius <= 0 {
+		o.ArcRadius = o.Radius
+	}
+
+	corridor, startPos, endPos, status, err := this.polyPath(nil, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if corridor == nil {
+		return nil, statusError("find path", status)
+	}
+	if detour.DtStatusDetail(status, detour.DT_PARTIAL_RESULT) {
+		this.query.ClosestPointOnPoly(corridor[len(corridor)-1], end[:], endPos[:], nil)
+	}
+
+	var count int
+	refs := make([]PolyRef, len(this.straightFlags))
+	straightStatus := this.query.FindStraightPathRadius(startPos[:], endPos[:], corridor, len(corridor), o.Radius,
+		this.straightPath, this.straightFlags, refs, &count, len(this.straightFlags), 0)
+	if detour.DtStatusFailed(straightStatus) {
+		return nil, statusError("find straight path", straightStatus)
+	}
+	status |= straightStatus & detour.DT_STATUS_DETAIL_MASK
+
+	points := make([]Vec3, count)
+	for i := range points {
+		copy(points[i][:], this.straightPath[i*3:i*3+3])
+	}
+	// Segment i, from point i to point i+1, follows an off-mesh connection.
+	offMesh := make([]bool, count)
+	for i := range offMesh {
+		offMesh[i] = this.straightFlags[i]&detour.DT_STRAIGHTPATH_OFFMESH_CONNECTION != 0
+	}
+	if o.Radius > 0 {
+		points, offMesh = this.clearCorners(points, offMesh, refs[:count], o.Radius)
+	}
+
+	switch o.Corners {
+	case CORNERS_ARC:
+		points = this.roundArcs(points, offMesh, o.ArcRadius, o.Segments)
+	case CORNERS_CATMULL_ROM:
+		points = this.roundSplines(points, offMesh, o.Segments)
+	}
+	return points, statusError("find path", status)
+}
+
+// WALL_VERTEX_TOLERANCE is how far from the radius a polygon vertex can be
+// from a corner of the path and still be the wall vertex it turns around.
+const WALL_VERTEX_TOLERANCE = 1e-3
+
+// turnCircle is a corner of the path, or a run of corners turning around
+// the same wall vertex, in clearCorners.
+type turnCircle struct {
+	first, last int     // The corners of the run.
+	center      Vec3    // The wall vertex, or the corner when side is zero.
+	side        float64 // 1 for a left turn, -1 for a right turn, 0 for a point passed through.
+}
+
+// clearCorners moves the corners of a string pulled path out, so its
+// segments keep radius from the wall vertices they turn around.
+//
+// FindStraightPathRadius puts a corner on every portal at the radius from
+// its end, so a path turning around a wall vertex shared by several portals
+// has a corner on each of them, and the segments between them pass closer
+// to the vertex, 0.71 times the radius around a right angle. Each run of
+// corners turning around the same vertex is replaced with the intersection
+// of the tangents to the circle of the radius around it, coming from the
+// previous corner and going to the next. Corners whose wall vertex is not
+// found, at portals narrower than twice the radius for example, are kept
+// and passed through. The path is kept as it is when a moved corner would
+// make it cross a wall.
+func (this *Navigator) clearCorners(points []Vec3, offMesh []bool, refs []PolyRef, radius float32) ([]Vec3, []bool) {
+	circles := []turnCircle{{first: 0, last: 0, center: points[0]}}
+	moved := false
+	for i := 1; i < len(points); i++ {
+		c := turnCircle{first: i, last: i, center: points[i]}
+		if i < len(points)-1 && !offMesh[i-1] && !offMesh[i] {
+			if wall, ok := this.wallVertex(refs[i], points[i], radius); ok {
+				c.side = turnSide(points[i-1], points[i], points[i+1], wall)
+				if c.side != 0 {
+					c.center = wall
+				}
+			}
+		}
+		prev := &circles[len(circles)-1]
+		if c.side != 0 && prev.side == c.side && detour.DtVdist2DSqr(prev.center[:], c.center[:]) < 1e-8 {
+			prev.last = i
+			continue
+		}
+		moved = moved || c.side != 0
+		circles = append(circles, c)
+	}
+	if !moved {
+		return points, offMesh
+	}
+
+	result := make([]Vec3, 0, len(circles))
+	resultOffMesh := make([]bool, 0, len(circles))
+	var changed []int
+	for k, c := range circles {
+		corner, ok := Vec3{}, false
+		if c.side != 0 {
+			corner, ok = circleCorner(&circles[k-1], &c, &circles[k+1], radius)
+		}
+		if !ok {
+			result = append(result, points[c.first:c.last+1]...)
+			resultOffMesh = append(resultOffMesh, offMesh[c.first:c.last+1]...)
+			continue
+		}
+		changed = append(changed, len(result))
+		result = append(result, corner)
+		resultOffMesh = append(resultOffMesh, offMesh[c.last])
+	}
+	for _, i := range changed {
+		if this.blocked(result[i-1 : i+2]) {
+			return points, offMesh
+		}
+	}
+	return result, resultOffMesh
+}
+
+// wallVertex returns the vertex of the polygon ref at radius from pos.
+func (this *Navigator) wallVertex(ref PolyRef, pos Vec3, radius float32) (Vec3, bool) {
+	var tile *detour.DtMeshTile
+	var poly *detour.DtPoly
+	if ref == 0 || detour.DtStatusFailed(this.mesh.GetTileAndPolyByRef(ref, &tile, &poly)) {
+		return Vec3{}, false
+	}
+	var wall Vec3
+	best := float32(WALL_VERTEX_TOLERANCE)
+	found := false
+	for i := 0; i < int(poly.VertCount); i++ {
+		v := tile.Verts[int(poly.Verts[i])*3 : int(poly.Verts[i])*3+3]
+		if d := detour.DtAbsFloat32(detour.DtVdist2D(v, pos[:]) - radius); d <= best {
+			best = d
+			copy(wall[:], v)
+			found = true
+		}
+	}
+	return wall, found
+}
+
+// turnSide returns 1 when the path a-p-b turns left around wall, -1 when it
+// turns right, and 0 when it does not turn or wall is on the outside.
+func turnSide(a, p, b, wall Vec3) float64 {
+	turn := float64(p[0]-a[0])*float64(b[2]-p[2]) - float64(p[2]-a[2])*float64(b[0]-p[0])
+	// The left of the segment a-p is toward (-dz, dx).
+	left := -float64(p[2]-a[2])*float64(wall[0]-p[0]) + float64(p[0]-a[0])*float64(wall[2]-p[2])
+	switch {
+	case turn > 1e-9 && left > 0:
+		return 1
+	case turn < -1e-9 && left < 0:
+		return -1
+	}
+	return 0
+}
+
+// circleCorner returns the intersection of the tangents of the circle of c
+// with those of its neighbours, the circles of points passed through having
+// no radius. It returns false when the tangents do not meet.
+func circleCorner(prev, c, next *turnCircle, radius float32) (Vec3, bool) {
+	mx1, mz1, h1, ok1 := circleTangent(prev, c, radius)
+	mx2, mz2, h2, ok2 := circleTangent(c, next, radius)
+	det := mx1*mz2 - mz1*mx2
+	if !ok1 || !ok2 || math.Abs(det) < 1e-9 {
+		return Vec3{}, false
+	}
+	return Vec3{
+		float32((h1*mz2 - mz1*h2) / det),
+		c.center[1],
+		float32((mx1*h2 - h1*mx2) / det),
+	}, true
+}
+
+// circleTangent returns the line mx*x + mz*z = h going from the circle of a
+// to the circle of b, each circle on the side of the line its turn is to.
+// It returns false when the circles overlap so there is no such line.
+func circleTangent(a, b *turnCircle, radius float32) (mx, mz, h float64, ok bool) {
+	ra, rb := a.side*float64(radius), b.side*float64(radius)
+	dx, dz := float64(b.center[0]-a.center[0]), float64(b.center[2]-a.center[2])
+	l := math.Hypot(dx, dz)
+	if l < 1e-9 || math.Abs(rb-ra) >= l {
+		return 0, 0, 0, false
+	}
+	ux, uz := dx/l, dz/l
+	// The normal (mx, mz) points to the left of the line, at the angle
+	// from the left of a-b giving the two distances.
+	s := (rb - ra) / l
+	c := math.Sqrt(1 - s*s)
+	mx, mz = -c*uz+s*ux, c*ux+s*uz
+	h = mx*float64(a.center[0]) + mz*float64(a.center[2]) - ra
+	return mx, mz, h, true
+}
+
+// roundArcs replaces each corner with an arc tangent to both its segments.
+func (this *Navigator) roundArcs(points []Vec3, offMesh []bool, radius float32, segments int) []Vec3 {
+	if len(points) < 3 || radius <= 0 {
+		return points
+	}
+	result := []Vec3{points[0]}
+	for i := 1; i < len(points)-1; i++ {
+		if offMesh[i-1] || offMesh[i] {
+			result = append(result, points[i])
+			continue
+		}
+		arc := cornerArc(points[i-1], points[i], points[i+1], radius, segments)
+		if arc == nil || this.blocked(arc) {
+			result = append(result, points[i])
+			continue
+		}
+		result = append(result, arc...)
+	}
+	return append(result, points[len(points)-1])
+}
+
+// cornerArc returns the points of the arc of the given radius tangent to the
+// segments a-p and p-b, from the first tangent point to the second, as
+// segments tangent to the arc. The
+// radius is reduced so the tangent points stay on the first half of the
+// segments. It returns nil when the segments are aligned.
+//
+// At a corner moved out by clearCorners, an arc of the clearance radius
+// follows the circle around the wall vertex, and smaller arcs stay outside
+// it. Larger arcs cut into it, passing closer to the vertex than the radius.
+func cornerArc(a, p, b Vec3, radius float32, segments int) []Vec3 {
+	ax, az := float64(a[0]-p[0]), float64(a[2]-p[2])
+	bx, bz := float64(b[0]-p[0]), float64(b[2]-p[2])
+	la, lb := math.Hypot(ax, az), math.Hypot(bx, bz)
+	if la < 1e-6 || lb < 1e-6 {
+		return nil
+	}
+	ax, az, bx, bz = ax/la, az/la, bx/lb, bz/lb
+
+	// Angle between the two segments at the corner.
+	angle := math.Acos(math.Max(-1, math.Min(1, ax*bx+az*bz)))
+	if angle > math.Pi-1e-3 {
+		return nil
+	}
+	half := math.Tan(angle / 2)
+	d := float64(radius) / half // Distance from the corner to the tangent points.
+	d = math.Min(d, math.Min(la, lb)/2)
+	r := d * half
+
+	// The center is on the bisector, at the radius from both segments.
+	mx, mz := ax+bx, az+bz
+	ml := math.Hypot(mx, mz)
+	cd := math.Hypot(d, r)
+	cx, cz := float64(p[0])+mx/ml*cd, float64(p[2])+mz/ml*cd
+
+	t1 := Vec3{p[0] + float32(ax*d), 0, p[2] + float32(az*d)}
+	t2 := Vec3{p[0] + float32(bx*d), 0, p[2] + float32(bz*d)}
+	t1[1] = p[1] + (a[1]-p[1])*float32(d/la)
+	t2[1] = p[1] + (b[1]-p[1])*float32(d/lb)
+
+	a1 := math.Atan2(float64(t1[2])-cz, float64(t1[0])-cx)
+	a2 := math.Atan2(float64(t2[2])-cz, float64(t2[0])-cx)
+	sweep := a2 - a1
+	if sweep > math.Pi {
+		sweep -= 2 * math.Pi
+	} else if sweep < -math.Pi {
+		sweep += 2 * math.Pi
+	}
+
+	// The inner points are the corners of the segments tangent to the arc
+	// at even steps, so the segments never pass inside the circle.
+	arc := make([]Vec3, segments+1)
+	arc[0], arc[segments] = t1, t2
+	for k := 1; k < segments; k++ {
+		s := (float64(k) - 0.5) / float64(segments-1)
+		an := a1 + sweep*s
+		rk := r / math.Cos(sweep/float64(segments-1)/2)
+		arc[k] = Vec3{
+			float32(cx + rk*math.Cos(an)),
+			t1[1] + (t2[1]-t1[1])*float32(s),
+			float32(cz + rk*math.Sin(an)),
+		}
+	}
+	return arc
+}
+
+// roundSplines inserts the points of a centripetal Catmull-Rom spline
+// between every two corners.
+func (this *Navigator) roundSplines(points []Vec3, offMesh []bool, segments int) []Vec3 {
+	if len(points) < 3 {
+		return points
+	}
+	result := []Vec3{points[0]}
+	for i := 0; i < len(points)-1; i++ {
+		if !offMesh[i] {
+			p0, p3 := points[i], points[i+1]
+			// Off-mesh connections end the spline, like the ends of the path.
+			if i > 0 && !offMesh[i-1] {
+				p0 = points[i-1]
+			}
+			if i+2 < len(points) && !offMesh[i+1] {
+				p3 = points[i+2]
+			}
+			curve := catmullRom(p0, points[i], points[i+1], p3, segments)
+			check := append(append([]Vec3{points[i]}, curve...), points[i+1])
+			if !this.blocked(check) {
+				result = append(result, curve...)
+			}
+		}
+		result = append(result, points[i+1])
+	}
+	return result
+}
+
+// catmullRom returns the inner points of the centripetal Catmull-Rom spline
+// from p1 to p2.
+func catmullRom(p0, p1, p2, p3 Vec3, segments int) []Vec3 {
+	knot := func(t float64, a, b Vec3) float64 {
+		d := math.Sqrt(float64(detour.DtVdist(a[:], b[:])))
+		if d < 1e-4 {
+			d = 1e-4 // Duplicated end points.
+		}
+		return t + d
+	}
+	t0 := 0.0
+	t1 := knot(t0, p0, p1)
+	t2 := knot(t1, p1, p2)
+	t3 := knot(t2, p2, p3)
+
+	lerp := func(a, b Vec3, ta, tb, t float64) Vec3 {
+		s := float32((t - ta) / (tb - ta))
+		var v Vec3
+		detour.DtVlerp(v[:], a[:], b[:], s)
+		return v
+	}
+	curve := make([]Vec3, 0, segments-1)
+	for k := 1; k < segments; k++ {
+		t := t1 + (t2-t1)*float64(k)/float64(segments)
+		a1 := lerp(p0, p1, t0, t1, t)
+		a2 := lerp(p1, p2, t1, t2, t)
+		a3 := lerp(p2, p3, t2, t3, t)
+		b1 := lerp(a1, a2, t0, t2, t)
+		b2 := lerp(a2, a3, t1, t3, t)
+		curve = append(curve, lerp(b1, b2, t1, t2, t))
+	}
+	return curve
+}
+
+// blocked reports whether a wall is hit along the points.
+func (this *Navigator) blocked(points []Vec3) bool {
+	_, err := this.ValidatePath(points)
+	return err != nil
+}
+
+// SEGMENT_END_TOLERANCE is how short of the end of a segment ValidatePath
+// can hit a wall and still count the end as reached.
+const SEGMENT_END_TOLERANCE = 1e-3
+
+// ValidatePath checks that every segment of points can be walked along the
+// surface of the navmesh, for example a path received from a client. It
+// returns the number of valid segments, and a *BlockedError matching
+// ErrBlocked for the first segment hitting a wall. Other errors are those of
+// Nearest and Raycast.
+//
+// String pulled paths run through wall vertices, at their corners and where
+// a corner is passed straight on. A ray hitting a wall within
+// SEGMENT_END_TOLERANCE of the end of its segment reaches it, and one
+// hitting a wall vertex on the way goes on from there.
+//
+// Segments following off-mesh connections are not walkable, so a path
+// using them must be validated one section at a time.
+func (this *Navigator) ValidatePath(points []Vec3) (int, error) {
+	if len(points) < 2 {
+		return 0, nil
+	}
+	ref, pos, err := this.Nearest(points[0])
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i+1 < len(points); i++ {
+		// The ray starts again from the wall vertices it passes, at from
+		// along the segment, after the polygons in passed. Each restart
+		// moves it on by more than the tolerance.
+		to := points[i+1]
+		from := float32(0)
+		var passed []PolyRef
+		for {
+			var t float32
+			var hit Hit
+			var count int
+			status := this.raycastFrom(ref, pos, to, &t, &hit.Normal, &count)
+			if detour.DtStatusFailed(status) {
+				return i, statusError("raycast", status)
+			}
+			if t == math.MaxFloat32 || (1-t)*detour.DtVdist2D(pos[:], to[:]) <= SEGMENT_END_TOLERANCE {
+				if count == len(this.path) {
+					// The polygon at the end is unknown.
+					if ref, _, err = this.Nearest(to); err != nil {
+						return i + 1, err
+					}
+				} else {
+					ref = this.path[count-1]
+				}
+				break
+			}
+			detour.DtVlerp(hit.Point[:], pos[:], to[:], t)
+			if _, ok := this.wallVertex(this.path[count-1], hit.Point, 0); ok && count < len(this.path) &&
+				detour.DtVdist2D(pos[:], hit.Point[:]) > SEGMENT_END_TOLERANCE {
+				passed = append(passed, this.path[:count]...)
+				ref, pos = this.path[count-1], hit.Point
+				from += t * (1 - from)
+				continue
+			}
+			hit.Hit = true
+			hit.T = from + t*(1-from)
+			hit.Path = append(passed, this.path[:count]...)
+			return i, &BlockedError{Segment: i, Hit: hit}
+		}
+		pos = to
+	}
+	return len(points) - 1, nil
+}
+
+// raycastFrom casts a ray from pos in the polygon ref to the path buffer.
+// Points often lie on the edges or corners of polygons, so when the ray
+// cannot leave ref, the others touching pos are also tried.
+func (this *Navigator) raycastFrom(ref PolyRef, pos, to Vec3, t *float32, normal *Vec3, count *int) detour.DtStatus {
+	status := this.query.Raycast(ref, pos[:], to[:], this.opts.Filter, t, normal[:], this.path, count, len(this.path))
+	if *t == 0 && !detour.DtStatusFailed(status) {
+		for _, other := range this.polysAt(pos) {
+			if other == ref {
+				continue
+			}
+			status = this.query.Raycast(other, pos[:], to[:], this.opts.Filter, t, normal[:], this.path, count, len(this.path))
+			if detour.DtStatusFailed(status) || *t != 0 {
+				break
+			}
+		}
+	}
+	return status
+}
+
+// polysAt returns the polygons within a small distance of pos.
+func (this *Navigator) polysAt(pos Vec3) []PolyRef {
+	const MAX_POLYS = 16
+	var polys [MAX_POLYS]PolyRef
+	var count int
+	halfExtents := Vec3{0.01, this.opts.HalfExtents[1], 0.01}
+	this.query.QueryPolygons(pos[:], halfExtents[:], this.opts.Filter, polys[:], &count, MAX_POLYS)
+	return polys[:count]
+}
@@ -0,0 +1,167 @@
+package navigation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+	dtcache "github.com/fananchong/recastnavigation-go/DetourTileCache"
+)
+
+// SyncNavMesh guards a navmesh with a read/write lock, so tiles can be added,
+// removed or rebuilt while other goroutines query it.
+//
+// Queries take the read lock for the duration of one call; tile changes take
+// the write lock only to swap tiles, after the tile data has been built.
+// Polygon references kept between calls may go stale when their tile is
+// replaced: removing a tile bumps its salt, so use CheckRefs or
+// DtNavMesh.IsValidPolyRef under the read lock before reusing them.
+type SyncNavMesh struct {
+	mu   sync.RWMutex
+	mesh *detour.DtNavMesh
+}
+
+// NewSyncNavMesh wraps mesh. After this call mesh must only be accessed
+// through the wrapper.
+func NewSyncNavMesh(mesh *detour.DtNavMesh) *SyncNavMesh {
+	return &SyncNavMesh{mesh: mesh}
+}
+
+// Locker returns the write side of the lock, for DtTileCache.UpdateLocked
+// and BuildNavMeshTileLocked.
+func (this *SyncNavMesh) Locker() sync.Locker {
+	return &this.mu
+}
+
+// RLocker returns the read side of the lock.
+func (this *SyncNavMesh) RLocker() sync.Locker {
+	return this.mu.RLocker()
+}
+
+// Read calls fn with the navmesh while holding the read lock. fn must not
+// modify the navmesh.
+func (this *SyncNavMesh) Read(fn func(mesh *detour.DtNavMesh) error) error {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	return fn(this.mesh)
+}
+
+// Write calls fn with the navmesh while holding the write lock.
+func (this *SyncNavMesh) Write(fn func(mesh *detour.DtNavMesh) error) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return fn(this.mesh)
+}
+
+// AddTile adds a tile, reusing lastRef when non-zero so the refs of a
+// reloaded tile stay valid.
+func (this *SyncNavMesh) AddTile(data []byte, lastRef detour.DtTileRef) (detour.DtTileRef, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	var ref detour.DtTileRef
+	status := this.mesh.AddTile(data, len(data), detour.DT_TILE_FREE_DATA, lastRef, &ref)
+	if err := statusError("AddTile", status); err != nil {
+		return 0, err
+	}
+	return ref, nil
+}
+
+// RemoveTile removes a tile. It returns the tile data for tiles added
+// without DT_TILE_FREE_DATA, and nil for tiles the navmesh owns, such as
+// those added by AddTile or navimport.BuildNavMesh.
+func (this *SyncNavMesh) RemoveTile(ref detour.DtTileRef) ([]byte, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	var data []byte
+	status := this.mesh.RemoveTile(ref, &data, nil)
+	if err := statusError("RemoveTile", status); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ReplaceTile removes the tile at the location of data, if any, and adds data
+// in its place in a single step, so queries never see the location empty.
+// The polygon refs of the old tile become stale.
+func (this *SyncNavMesh) ReplaceTile(data []byte) (detour.DtTileRef, error) {
+	var header detour.DtMeshHeader
+	if len(data) < binary.Size(header) {
+		return 0, &StatusError{Op: "ReplaceTile", Status: detour.DT_FAILURE | detour.DT_INVALID_PARAM}
+	}
+	binary.Read(bytes.NewReader(data), binary.LittleEndian, &header)
+	if header.Magic != detour.DT_NAVMESH_MAGIC {
+		return 0, &StatusError{Op: "ReplaceTile", Status: detour.DT_FAILURE | detour.DT_WRONG_MAGIC}
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if old := this.mesh.GetTileRefAt(header.X, header.Y, header.Layer); old != 0 {
+		if err := statusError("RemoveTile", this.mesh.RemoveTile(old, nil, nil)); err != nil {
+			return 0, err
+		}
+	}
+	var ref detour.DtTileRef
+	status := this.mesh.AddTile(data, len(data), detour.DT_TILE_FREE_DATA, 0, &ref)
+	if err := statusError("AddTile", status); err != nil {
+		return 0, err
+	}
+	return ref, nil
+}
+
+// AddOffMeshConnection adds an off-mesh connection from start to end, such
+// as a teleporter or a ladder created by the game, without rebuilding tiles.
+// See DtNavMesh.AddOffMeshConnection; each connection takes a tile of the
+// navmesh.
+func (this *SyncNavMesh) AddOffMeshConnection(start, end Vec3, radius float32, bidir bool,
+	area uint8, flags uint16, userId uint32) (PolyRef, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	ref, status := this.mesh.AddOffMeshConnection(start[:], end[:], radius, bidir, area, flags, userId)
+	if err := statusError("AddOffMeshConnection", status); err != nil {
+		return 0, err
+	}
+	return ref, nil
+}
+
+// RemoveOffMeshConnection removes a connection added by AddOffMeshConnection.
+// Its ref becomes stale.
+func (this *SyncNavMesh) RemoveOffMeshConnection(ref PolyRef) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return statusError("RemoveOffMeshConnection", this.mesh.RemoveOffMeshConnection(ref))
+}
+
+// UpdateTileCache runs one DtTileCache.Update step against the navmesh.
+// Tiles are rebuilt without the lock, so this can run on a background
+// goroutine while queries continue. It reports whether the tile cache is up
+// to date.
+func (this *SyncNavMesh) UpdateTileCache(tc *dtcache.DtTileCache, dt float32) (bool, error) {
+	var upToDate bool
+	status := tc.UpdateLocked(dt, this.mesh, &upToDate, &this.mu)
+	return upToDate, statusError("Update", status)
+}
+
+// CheckRefs reports ErrStaleRef for the first ref that no longer refers to a
+// polygon, for example because its tile was rebuilt.
+func (this *SyncNavMesh) CheckRefs(refs ...PolyRef) error {
+	this.mu.RLock()
+	defer this.mu.RUnlock()
+	for _, ref := range refs {
+		if !this.mesh.IsValidPolyRef(ref) {
+			return fmt.Errorf("%w: %d", ErrStaleRef, ref)
+		}
+	}
+	return nil
+}
+
+// NewQueryPool creates a pool of Navigators whose calls hold the read lock.
+// Navigators taken with Get must only be used inside Read, or through Do.
+func (this *SyncNavMesh) NewQueryPool(opts *Options) (*QueryPool, error) {
+	pool, err := NewQueryPool(this.mesh, opts)
+	if err != nil {
+		return nil, err
+	}
+	pool.lock = this.mu.RLocker()
+	return pool, nil
+}
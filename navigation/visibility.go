@@ -0,0 +1,59 @@
+package navigation
+
+import (
+	"math"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// Visibility returns the part of the navmesh within radius that can be seen
+// from pos, as a polygon on the xz-plane. See
+// DtNavMeshQuery.FindVisibilityPolygon.
+func (this *Navigator) Visibility(pos Vec3, radius float32) ([]Vec3, error) {
+	ref, center, err := this.Nearest(pos)
+	if err != nil {
+		return nil, err
+	}
+	var verts []float32
+	var count int
+	var status detour.DtStatus
+	for maxVerts := 256; ; maxVerts *= 2 {
+		verts = make([]float32, maxVerts*3)
+		status = this.query.FindVisibilityPolygon(ref, center[:], radius, this.opts.Filter, verts, &count, maxVerts)
+		if detour.DtStatusFailed(status) {
+			return nil, statusError("find visibility polygon", status)
+		}
+		// DT_BUFFER_TOO_SMALL may come from the search as well, so check the count.
+		if count < maxVerts {
+			break
+		}
+	}
+	points := make([]Vec3, count)
+	for i := range points {
+		copy(points[i][:], verts[i*3:i*3+3])
+	}
+	return points, statusError("find visibility polygon", status)
+}
+
+// LineOfSight reports whether each target can be seen from pos: whether a
+// Raycast reaches it without hitting a wall. Targets farther than
+// maxDistance are not visible; 0 means no limit.
+func (this *Navigator) LineOfSight(pos Vec3, targets []Vec3, maxDistance float32) ([]bool, error) {
+	ref, start, err := this.Nearest(pos)
+	if err != nil {
+		return nil, err
+	}
+	if maxDistance == 0 {
+		maxDistance = math.MaxFloat32
+	}
+	targetPos := make([]float32, len(targets)*3)
+	for i := range targets {
+		copy(targetPos[i*3:], targets[i][:])
+	}
+	visible := make([]bool, len(targets))
+	status := this.query.CheckLineOfSight(ref, start[:], targetPos, len(targets), maxDistance, this.opts.Filter, visible)
+	if err := statusError("check line of sight", status); err != nil {
+		return nil, err
+	}
+	return visible, nil
+}
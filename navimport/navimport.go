@@ -0,0 +1,337 @@
+// Package navimport builds navigation mesh tiles from simple polygon
+// descriptions, written by hand as JSON or exported from a modelling tool as
+// OBJ. It is meant for tests and tiny hand-authored maps; real levels should
+// go through Recast.
+package navimport
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// Defaults applied to zero fields of a Description.
+const (
+	DEFAULT_CELL_SIZE       float32 = 0.1
+	DEFAULT_CELL_HEIGHT     float32 = 0.05
+	DEFAULT_WALKABLE_HEIGHT float32 = 2.0
+	DEFAULT_WALKABLE_RADIUS float32 = 0.5
+	DEFAULT_WALKABLE_CLIMB  float32 = 0.5
+	DEFAULT_FLAGS           uint16  = 0x01
+)
+
+const meshNullIdx uint16 = 0xffff
+
+var (
+	ErrNoPolys         = errors.New("navimport: description has no polygons")
+	ErrTooManyVerts    = errors.New("navimport: too many vertices")
+	ErrCreateFailed    = errors.New("navimport: DtCreateNavMeshData failed")
+	ErrTileCount       = errors.New("navimport: a single tile navmesh needs exactly one description")
+	ErrDegenerateEdge  = errors.New("navimport: polygon has coincident vertices after quantization")
+	ErrVertexOutOfTile = errors.New("navimport: vertex outside the tile bounds")
+)
+
+// Poly is one convex polygon of a Description.
+type Poly struct {
+	Verts []int   `json:"verts"`           // Indices into Description.Verts, at least 3 and at most DT_VERTS_PER_POLYGON.
+	Area  uint8   `json:"area"`            // Area id.
+	Flags *uint16 `json:"flags,omitempty"` // Polygon flags, DEFAULT_FLAGS when omitted.
+}
+
+// OffMeshConnection is one off-mesh connection of a Description.
+type OffMeshConnection struct {
+	Start  [3]float32 `json:"start"`
+	End    [3]float32 `json:"end"`
+	Radius float32    `json:"radius"`
+	Bidir  bool       `json:"bidir"`
+	Area   uint8      `json:"area"`
+	Flags  *uint16    `json:"flags,omitempty"` // DEFAULT_FLAGS when omitted.
+	UserId uint32     `json:"userId"`
+}
+
+// Description is a tile described as a set of convex polygons in world units.
+//
+// Polygon neighbours are found from shared edges, so polygons only need to
+// list their own vertices. Vertices are snapped to the CellSize/CellHeight
+// grid, the same way Recast output is, and vertices that snap to the same
+// grid point are welded. Polygons may use either winding.
+type Description struct {
+	CellSize       float32 `json:"cellSize"`
+	CellHeight     float32 `json:"cellHeight"`
+	WalkableHeight float32 `json:"walkableHeight"`
+	WalkableRadius float32 `json:"walkableRadius"`
+	WalkableClimb  float32 `json:"walkableClimb"`
+
+	TileX     int32  `json:"tileX"`
+	TileY     int32  `json:"tileY"`
+	TileLayer int32  `json:"tileLayer"`
+	UserId    uint32 `json:"userId"`
+
+	// Tile bounds. When omitted they are computed from the vertices.
+	Bmin *[3]float32 `json:"bmin,omitempty"`
+	Bmax *[3]float32 `json:"bmax,omitempty"`
+	// Mark open edges lying on the tile bounds as portals to the neighbour
+	// tiles, so adjacent tiles connect.
+	BorderPortals bool `json:"borderPortals"`
+
+	Verts              [][3]float32        `json:"verts"`
+	Polys              []Poly              `json:"polys"`
+	OffMeshConnections []OffMeshConnection `json:"offMeshConnections"`
+}
+
+func orDefault(v, def float32) float32 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+func flagsOrDefault(flags *uint16) uint16 {
+	if flags == nil {
+		return DEFAULT_FLAGS
+	}
+	return *flags
+}
+
+// Bounds returns the tile bounds, computing the missing ones from the vertices.
+func (this *Description) Bounds() (bmin, bmax [3]float32) {
+	for i := 0; i < 3; i++ {
+		bmin[i] = math.MaxFloat32
+		bmax[i] = -math.MaxFloat32
+	}
+	for _, v := range this.Verts {
+		detour.DtVmin(bmin[:], v[:])
+		detour.DtVmax(bmax[:], v[:])
+	}
+	if this.Bmin != nil {
+		bmin = *this.Bmin
+	}
+	if this.Bmax != nil {
+		bmax = *this.Bmax
+	}
+	return bmin, bmax
+}
+
+type edgeKey struct {
+	a, b uint16
+}
+
+type edgeRef struct {
+	poly, edge int
+}
+
+// CreateParams converts the description to navmesh build parameters.
+func (this *Description) CreateParams() (*detour.DtNavMeshCreateParams, error) {
+	if len(this.Polys) == 0 {
+		return nil, ErrNoPolys
+	}
+	cs := orDefault(this.CellSize, DEFAULT_CELL_SIZE)
+	ch := orDefault(this.CellHeight, DEFAULT_CELL_HEIGHT)
+	bmin, bmax := this.Bounds()
+
+	params := &detour.DtNavMeshCreateParams{
+		Nvp:            detour.DT_VERTS_PER_POLYGON,
+		UserId:         this.UserId,
+		TileX:          this.TileX,
+		TileY:          this.TileY,
+		TileLayer:      this.TileLayer,
+		Bmin:           bmin,
+		Bmax:           bmax,
+		WalkableHeight: orDefault(this.WalkableHeight, DEFAULT_WALKABLE_HEIGHT),
+		WalkableRadius: orDefault(this.WalkableRadius, DEFAULT_WALKABLE_RADIUS),
+		WalkableClimb:  orDefault(this.WalkableClimb, DEFAULT_WALKABLE_CLIMB),
+		Cs:             cs,
+		Ch:             ch,
+		BuildBvTree:    true,
+	}
+
+	// Quantize and weld vertices.
+	quantize := func(v, min, cell float32) (uint16, bool) {
+		q := math.Floor(float64((v-min)/cell) + 0.5)
+		return uint16(q), q >= 0 && q < float64(meshNullIdx)
+	}
+	remap := make([]uint16, len(this.Verts))
+	welded := make(map[[3]uint16]uint16)
+	for i, v := range this.Verts {
+		var q [3]uint16
+		var ok [3]bool
+		q[0], ok[0] = quantize(v[0], bmin[0], cs)
+		q[1], ok[1] = quantize(v[1], bmin[1], ch)
+		q[2], ok[2] = quantize(v[2], bmin[2], cs)
+		if !ok[0] || !ok[1] || !ok[2] {
+			return nil, ErrVertexOutOfTile
+		}
+		idx, found := welded[q]
+		if !found {
+			if len(params.Verts)/3 >= int(meshNullIdx)-1 {
+				return nil, ErrTooManyVerts
+			}
+			idx = uint16(len(params.Verts) / 3)
+			welded[q] = idx
+			params.Verts = append(params.Verts, q[0], q[1], q[2])
+		}
+		remap[i] = idx
+	}
+	params.VertCount = int32(len(params.Verts) / 3)
+
+	// Polygons, in Detour winding.
+	nvp := int(params.Nvp)
+	params.PolyCount = int32(len(this.Polys))
+	params.Polys = make([]uint16, len(this.Polys)*nvp*2)
+	params.PolyAreas = make([]uint8, len(this.Polys))
+	params.PolyFlags = make([]uint16, len(this.Polys))
+	for i := range params.Polys {
+		params.Polys[i] = meshNullIdx
+	}
+	for i, poly := range this.Polys {
+		nv := len(poly.Verts)
+		if nv < 3 || nv > nvp {
+			return nil, fmt.Errorf("navimport: polygon %d has %d vertices, want 3 to %d", i, nv, nvp)
+		}
+		p := params.Polys[i*nvp*2:]
+		for j, vi := range poly.Verts {
+			if vi < 0 || vi >= len(remap) {
+				return nil, fmt.Errorf("navimport: polygon %d references missing vertex %d", i, vi)
+			}
+			p[j] = remap[vi]
+		}
+		if polyArea2D(params.Verts, p[:nv]) < 0 {
+			for a, b := 0, nv-1; a < b; a, b = a+1, b-1 {
+				p[a], p[b] = p[b], p[a]
+			}
+		}
+		for j := 0; j < nv; j++ {
+			if p[j] == p[(j+1)%nv] {
+				return nil, ErrDegenerateEdge
+			}
+		}
+		params.PolyAreas[i] = poly.Area
+		params.PolyFlags[i] = flagsOrDefault(poly.Flags)
+	}
+
+	// Neighbours from shared edges.
+	edges := make(map[edgeKey]edgeRef)
+	for i := range this.Polys {
+		p := params.Polys[i*nvp*2:]
+		nv := len(this.Polys[i].Verts)
+		for j := 0; j < nv; j++ {
+			a, b := p[j], p[(j+1)%nv]
+			if a > b {
+				a, b = b, a
+			}
+			key := edgeKey{a, b}
+			if other, ok := edges[key]; ok {
+				p[nvp+j] = uint16(other.poly)
+				params.Polys[other.poly*nvp*2+nvp+other.edge] = uint16(i)
+				delete(edges, key)
+			} else {
+				edges[key] = edgeRef{i, j}
+			}
+		}
+	}
+	if this.BorderPortals {
+		maxX, _ := quantize(bmax[0], bmin[0], cs)
+		maxZ, _ := quantize(bmax[2], bmin[2], cs)
+		for _, e := range edges {
+			p := params.Polys[e.poly*nvp*2:]
+			nv := len(this.Polys[e.poly].Verts)
+			va := params.Verts[int(p[e.edge])*3:]
+			vb := params.Verts[int(p[(e.edge+1)%nv])*3:]
+			switch {
+			case va[0] == 0 && vb[0] == 0:
+				p[nvp+e.edge] = 0x8000 | 0
+			case va[2] == maxZ && vb[2] == maxZ:
+				p[nvp+e.edge] = 0x8000 | 1
+			case va[0] == maxX && vb[0] == maxX:
+				p[nvp+e.edge] = 0x8000 | 2
+			case va[2] == 0 && vb[2] == 0:
+				p[nvp+e.edge] = 0x8000 | 3
+			}
+		}
+	}
+
+	// Off-mesh connections.
+	for _, con := range this.OffMeshConnections {
+		params.OffMeshConVerts = append(params.OffMeshConVerts, con.Start[:]...)
+		params.OffMeshConVerts = append(params.OffMeshConVerts, con.End[:]...)
+		params.OffMeshConRad = append(params.OffMeshConRad, con.Radius)
+		params.OffMeshConFlags = append(params.OffMeshConFlags, flagsOrDefault(con.Flags))
+		params.OffMeshConAreas = append(params.OffMeshConAreas, con.Area)
+		var dir uint8
+		if con.Bidir {
+			dir = detour.DT_OFFMESH_CON_BIDIR
+		}
+		params.OffMeshConDir = append(params.OffMeshConDir, dir)
+		params.OffMeshConUserID = append(params.OffMeshConUserID, con.UserId)
+	}
+	params.OffMeshConCount = int32(len(this.OffMeshConnections))
+
+	return params, nil
+}
+
+// polyArea2D returns twice the signed xz-area of a polygon, with the sign
+// convention of DtTriArea2D. Detour polygons have a positive area.
+func polyArea2D(verts []uint16, poly []uint16) float32 {
+	var area float32
+	a := verts[int(poly[0])*3:]
+	for j := 2; j < len(poly); j++ {
+		b := verts[int(poly[j-1])*3:]
+		c := verts[int(poly[j])*3:]
+		abx := float32(b[0]) - float32(a[0])
+		abz := float32(b[2]) - float32(a[2])
+		acx := float32(c[0]) - float32(a[0])
+		acz := float32(c[2]) - float32(a[2])
+		area += acx*abz - abx*acz
+	}
+	return area
+}
+
+// CreateNavMeshData builds the tile data of the description.
+func (this *Description) CreateNavMeshData() ([]byte, error) {
+	params, err := this.CreateParams()
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	var dataSize int
+	if !detour.DtCreateNavMeshData(params, &data, &dataSize) {
+		return nil, ErrCreateFailed
+	}
+	return data[:dataSize], nil
+}
+
+// BuildNavMesh creates a navmesh from tile descriptions. With nil params the
+// navmesh is a single tile mesh and exactly one description is expected;
+// otherwise the navmesh is initialized with params and every description is
+// added as a tile.
+func BuildNavMesh(params *detour.DtNavMeshParams, descs ...*Description) (*detour.DtNavMesh, error) {
+	if params == nil && len(descs) != 1 {
+		return nil, ErrTileCount
+	}
+	navMesh := detour.DtAllocNavMesh()
+	if params != nil {
+		status := navMesh.Init(params)
+		if detour.DtStatusFailed(status) {
+			return nil, fmt.Errorf("navimport: init navmesh failed (status 0x%08x)", uint32(status))
+		}
+	}
+	for _, desc := range descs {
+		data, err := desc.CreateNavMeshData()
+		if err != nil {
+			return nil, err
+		}
+		var status detour.DtStatus
+		if params == nil {
+			status = navMesh.Init2(data, len(data), detour.DT_TILE_FREE_DATA)
+		} else {
+			status = navMesh.AddTile(data, len(data), detour.DT_TILE_FREE_DATA, 0, nil)
+		}
+		if detour.DtStatusFailed(status) {
+			return nil, fmt.Errorf("navimport: add tile (%d, %d, %d) failed (status 0x%08x)",
+				desc.TileX, desc.TileY, desc.TileLayer, uint32(status))
+		}
+	}
+	return navMesh, nil
+}
@@ -0,0 +1,109 @@
+package navimport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseJSON reads a Description written as JSON. Field names follow the json
+// tags of Description, for example:
+//
+//	{
+//		"verts": [[0,0,0], [0,0,10], [10,0,10], [10,0,0]],
+//		"polys": [{"verts": [0,1,2,3], "area": 0, "flags": 1}]
+//	}
+func ParseJSON(r io.Reader) (*Description, error) {
+	desc := &Description{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(desc); err != nil {
+		return nil, fmt.Errorf("navimport: %v", err)
+	}
+	return desc, nil
+}
+
+// ParseOBJ reads the vertices and faces of a Wavefront OBJ file as a
+// Description. Every face becomes a polygon.
+//
+// Area ids and flags are taken from the statements written by the navexport
+// package, so its output can be read back:
+//
+//	usemtl area_<area>                         sets the area of the following faces
+//	# poly <ref> area <area> flags <flags>     sets area and flags of the following faces
+//
+// Faces get area 0 and DEFAULT_FLAGS otherwise. Everything else is ignored.
+func ParseOBJ(r io.Reader) (*Description, error) {
+	desc := &Description{}
+	var area uint8
+	var flags *uint16
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "v":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("navimport: line %d: vertex needs 3 coordinates", line)
+			}
+			var v [3]float32
+			for i := 0; i < 3; i++ {
+				f, err := strconv.ParseFloat(fields[1+i], 32)
+				if err != nil {
+					return nil, fmt.Errorf("navimport: line %d: %v", line, err)
+				}
+				v[i] = float32(f)
+			}
+			desc.Verts = append(desc.Verts, v)
+
+		case "f":
+			poly := Poly{Area: area, Flags: flags}
+			for _, field := range fields[1:] {
+				// Only the position index of v/vt/vn is used.
+				idx, err := strconv.Atoi(strings.SplitN(field, "/", 2)[0])
+				if err != nil {
+					return nil, fmt.Errorf("navimport: line %d: %v", line, err)
+				}
+				if idx < 0 {
+					idx += len(desc.Verts)
+				} else {
+					idx--
+				}
+				poly.Verts = append(poly.Verts, idx)
+			}
+			desc.Polys = append(desc.Polys, poly)
+
+		case "usemtl":
+			if len(fields) > 1 && strings.HasPrefix(fields[1], "area_") {
+				a, err := strconv.ParseUint(strings.TrimPrefix(fields[1], "area_"), 10, 8)
+				if err == nil {
+					area = uint8(a)
+				}
+			}
+
+		case "#":
+			// # poly <ref> area <area> flags <flags>
+			if len(fields) == 7 && fields[1] == "poly" && fields[3] == "area" && fields[5] == "flags" {
+				a, err1 := strconv.ParseUint(fields[4], 10, 8)
+				f, err2 := strconv.ParseUint(fields[6], 10, 16)
+				if err1 == nil && err2 == nil {
+					area = uint8(a)
+					fl := uint16(f)
+					flags = &fl
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
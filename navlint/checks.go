@@ -0,0 +1,415 @@
+package navlint
+
+import (
+	"math"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// vert returns vertex k of polygon i of the tile.
+func (this *linter) vert(i, k int) []float32 {
+	poly := &this.tile.Polys[i]
+	v := int(poly.Verts[k%int(poly.VertCount)]) * 3
+	return this.tile.Verts[v : v+3]
+}
+
+// polyVerts returns the vertices of polygon i of the tile.
+func (this *linter) polyVerts(i int) []float32 {
+	poly := &this.tile.Polys[i]
+	verts := make([]float32, 0, int(poly.VertCount)*3)
+	for k := 0; k < int(poly.VertCount); k++ {
+		verts = append(verts, this.vert(i, k)...)
+	}
+	return verts
+}
+
+// links returns the links of polygon i of the tile.
+func (this *linter) links(i int) []detour.DtLink {
+	var links []detour.DtLink
+	tile := this.tile
+	for l := tile.Polys[i].FirstLink; l != detour.DT_NULL_LINK; l = tile.Links[l].Next {
+		links = append(links, tile.Links[l])
+	}
+	return links
+}
+
+// inTile reports whether ref is a polygon of the tile.
+func (this *linter) inTile(ref detour.DtPolyRef) bool {
+	return this.nav.DecodePolyIdTile(ref) == this.nav.DecodePolyIdTile(this.base) &&
+		this.nav.DecodePolyIdSalt(ref) == this.nav.DecodePolyIdSalt(this.base)
+}
+
+// checkPolyVerts reports whether the vertex indices of polygon i are valid,
+// and as many as its type needs. The other checks skip invalid polygons.
+func (this *linter) checkPolyVerts(i int) bool {
+	poly := &this.tile.Polys[i]
+	nv := int(poly.VertCount)
+	want := 3
+	if poly.GetType() == detour.DT_POLYTYPE_OFFMESH_CONNECTION {
+		want = 2
+	}
+	if nv < want || nv > int(detour.DT_VERTS_PER_POLYGON) {
+		this.fail(CHECK_DEGENERATE_POLY, i, nil, "%d vertices", nv)
+		return false
+	}
+	for k := 0; k < nv; k++ {
+		if v := int(poly.Verts[k]); v >= int(this.tile.Header.VertCount) || (v+1)*3 > len(this.tile.Verts) {
+			this.fail(CHECK_DEGENERATE_POLY, i, nil, "vertex %d index %d out of range", k, v)
+			return false
+		}
+	}
+	return true
+}
+
+// fail adds an error found by check, if the check is enabled.
+func (this *linter) fail(check Check, poly int, pos []float32, format string, args ...interface{}) {
+	if this.checks[check] {
+		this.addIssue(check, SEVERITY_ERROR, poly, pos, format, args...)
+	}
+}
+
+// polyArea2D returns twice the signed area of polygon i on the xz-plane.
+func (this *linter) polyArea2D(i int) float32 {
+	var area float32
+	for k := 2; k < int(this.tile.Polys[i].VertCount); k++ {
+		area += detour.DtTriArea2D(this.vert(i, 0), this.vert(i, k-1), this.vert(i, k))
+	}
+	return area
+}
+
+func (this *linter) checkDegenerate(i int) bool {
+	tol := this.tol
+	for k := 0; k < int(this.tile.Polys[i].VertCount); k++ {
+		if a := this.vert(i, k); detour.DtVdist2DSqr(a, this.vert(i, k+1)) <= tol*tol {
+			this.fail(CHECK_DEGENERATE_POLY, i, a, "vertices %d and %d are at the same position", k, (k+1)%int(this.tile.Polys[i].VertCount))
+			return false
+		}
+	}
+	if area := this.polyArea2D(i); area*area <= tol*tol*tol*tol {
+		this.fail(CHECK_DEGENERATE_POLY, i, this.vert(i, 0), "no area")
+		return false
+	}
+	return true
+}
+
+func (this *linter) checkConvex(i int) {
+	// Every corner turns the way of the polygon.
+	sign := float32(1)
+	if this.polyArea2D(i) < 0 {
+		sign = -1
+	}
+	nv := int(this.tile.Polys[i].VertCount)
+	for k := 0; k < nv; k++ {
+		a, b, c := this.vert(i, k+nv-1), this.vert(i, k), this.vert(i, k+1)
+		if detour.DtTriArea2D(a, b, c)*sign < -this.tol*this.tol {
+			this.fail(CHECK_NONCONVEX_POLY, i, b, "reflex vertex %d", k)
+			return
+		}
+	}
+}
+
+func (this *linter) checkNeighbours(i int, valid []bool) {
+	tile := this.tile
+	poly := &tile.Polys[i]
+	links := this.links(i)
+	for j := 0; j < int(poly.VertCount); j++ {
+		nei := poly.Neis[j]
+		mid := edgeMid(this.vert(i, j), this.vert(i, j+1))
+		var edgeLinks []detour.DtLink
+		for _, link := range links {
+			if int(link.Edge) == j {
+				edgeLinks = append(edgeLinks, link)
+			}
+		}
+
+		switch {
+		case nei == 0:
+			for _, link := range edgeLinks {
+				this.fail(CHECK_NEIGHBOURS, i, mid, "border edge %d links to 0x%x", j, link.Ref)
+			}
+
+		case (nei & detour.DT_EXT_LINK) != 0:
+			side := uint8(nei & 0xff)
+			for _, link := range edgeLinks {
+				if this.inTile(link.Ref) || link.Side != side {
+					this.fail(CHECK_NEIGHBOURS, i, mid, "portal edge %d to side %d links to 0x%x on side %d",
+						j, side, link.Ref, link.Side)
+				}
+			}
+
+		default:
+			n := int(nei) - 1
+			if n >= int(tile.Header.PolyCount) || tile.Polys[n].GetType() != detour.DT_POLYTYPE_GROUND {
+				this.fail(CHECK_NEIGHBOURS, i, mid, "edge %d neighbour %d is not a polygon of the tile", j, n)
+				continue
+			}
+			ref := this.base | detour.DtPolyRef(n)
+			if len(edgeLinks) != 1 || edgeLinks[0].Ref != ref {
+				this.fail(CHECK_NEIGHBOURS, i, mid, "edge %d is not linked to its neighbour %d alone", j, n)
+			}
+			if valid[n] {
+				this.checkSharedEdge(i, j, n, mid)
+			}
+		}
+	}
+}
+
+// checkSharedEdge checks that neighbour n of polygon i has edge j of i as
+// one of its edges, leading back to i.
+func (this *linter) checkSharedEdge(i, j, n int, mid []float32) {
+	other := &this.tile.Polys[n]
+	for k := 0; k < int(other.VertCount); k++ {
+		if int(other.Neis[k]) != i+1 {
+			continue
+		}
+		tol2 := this.tol * this.tol
+		if detour.DtVdistSqr(this.vert(i, j), this.vert(n, k+1)) > tol2 ||
+			detour.DtVdistSqr(this.vert(i, j+1), this.vert(n, k)) > tol2 {
+			this.fail(CHECK_NEIGHBOURS, i, mid, "edge %d does not match edge %d of its neighbour %d", j, k, n)
+		}
+		return
+	}
+	this.fail(CHECK_NEIGHBOURS, i, mid, "neighbour %d of edge %d has no edge back", n, j)
+}
+
+func (this *linter) checkDetailMesh(i int) {
+	tile := this.tile
+	if i >= len(tile.DetailMeshes) {
+		return
+	}
+	poly := &tile.Polys[i]
+	pd := &tile.DetailMeshes[i]
+	verts := this.polyVerts(i)
+	nv := int(poly.VertCount)
+	var ed, et [detour.DT_VERTS_PER_POLYGON]float32
+	for t := 0; t < int(pd.TriCount); t++ {
+		tri := (int(pd.TriBase) + t) * 4
+		if tri+4 > len(tile.DetailTris) {
+			this.fail(CHECK_DETAIL_MESH, i, nil, "triangle %d out of range", t)
+			return
+		}
+		for k := 0; k < 3; k++ {
+			index := int(tile.DetailTris[tri+k])
+			var v []float32
+			if index < nv {
+				v = verts[index*3 : index*3+3]
+			} else {
+				dv := int(pd.VertBase) + index - nv
+				if index >= nv+int(pd.VertCount) || (dv+1)*3 > len(tile.DetailVerts) {
+					this.fail(CHECK_DETAIL_MESH, i, nil, "triangle %d vertex %d index %d out of range", t, k, index)
+					return
+				}
+				v = tile.DetailVerts[dv*3 : dv*3+3]
+			}
+			if detour.DtDistancePtPolyEdgesSqr(v, verts, nv, ed[:], et[:]) {
+				continue
+			}
+			dmin := ed[0]
+			for e := 1; e < nv; e++ {
+				dmin = float32(math.Min(float64(dmin), float64(ed[e])))
+			}
+			if dmin > this.tol*this.tol {
+				this.fail(CHECK_DETAIL_MESH, i, v, "triangle %d vertex %d is %.3f outside the polygon",
+					t, k, math.Sqrt(float64(dmin)))
+				return
+			}
+		}
+	}
+}
+
+// checkTileBorder checks that the portal edges of polygon i which are not
+// linked have no portal edge of a neighbour tile to link to, which happens
+// when the border vertices of the tiles do not match.
+func (this *linter) checkTileBorder(i int) {
+	const MAX_NEIS = 32
+	var neis [MAX_NEIS]*detour.DtMeshTile
+	header := this.tile.Header
+	poly := &this.tile.Polys[i]
+	links := this.links(i)
+next:
+	for j := 0; j < int(poly.VertCount); j++ {
+		if (poly.Neis[j] & detour.DT_EXT_LINK) == 0 {
+			continue
+		}
+		for _, link := range links {
+			if int(link.Edge) == j && link.Ref != 0 {
+				continue next
+			}
+		}
+		side := int(poly.Neis[j] & 0xff)
+		nneis := this.nav.GetNeighbourTilesAt(header.X, header.Y, side, neis[:], MAX_NEIS)
+		if nneis == 0 {
+			continue
+		}
+
+		// The portal edges are on a line along z for the x sides, and
+		// along x for the z sides.
+		axis, along := 0, 2
+		if side == 2 || side == 6 {
+			axis, along = 2, 0
+		}
+		a, b := this.vert(i, j), this.vert(i, j+1)
+		amin, amax := minMax(a[along], b[along])
+		mid := edgeMid(a, b)
+		found := false
+		var offset float32
+		portal := detour.DT_EXT_LINK | uint16(detour.DtOppositeTile(side))
+		for _, nei := range neis[:nneis] {
+			for p := 0; p < int(nei.Header.PolyCount); p++ {
+				other := &nei.Polys[p]
+				if other.GetType() != detour.DT_POLYTYPE_GROUND {
+					continue
+				}
+				for k := 0; k < int(other.VertCount); k++ {
+					if other.Neis[k] != portal {
+						continue
+					}
+					c := nei.Verts[int(other.Verts[k])*3:]
+					d := nei.Verts[int(other.Verts[(k+1)%int(other.VertCount)])*3:]
+					cmin, cmax := minMax(c[along], d[along])
+					if float32(math.Min(float64(amax), float64(cmax)))-float32(math.Max(float64(amin), float64(cmin))) <= this.tol {
+						continue
+					}
+					if d := float32(math.Abs(float64(c[axis] - a[axis]))); !found || d < offset {
+						offset = d
+					}
+					found = true
+				}
+			}
+		}
+		switch {
+		case !found:
+			this.addIssue(CHECK_TILE_BORDER, SEVERITY_WARNING, i, mid,
+				"portal edge %d has no portal edge of the neighbour tile on side %d to link to", j, side)
+		case offset > this.tol:
+			this.addIssue(CHECK_TILE_BORDER, SEVERITY_ERROR, i, mid,
+				"portal edge %d is %.3f away from the portal edges of the neighbour tile on side %d", j, offset, side)
+		default:
+			this.addIssue(CHECK_TILE_BORDER, SEVERITY_ERROR, i, mid,
+				"portal edge %d is not linked to the neighbour tile on side %d, heights differ by more than the walkable climb",
+				j, side)
+		}
+	}
+}
+
+func (this *linter) checkOffMeshConnections() {
+	const MAX_NEIS = 32
+	var neis [MAX_NEIS]*detour.DtMeshTile
+	tile := this.tile
+	for c := 0; c < int(tile.Header.OffMeshConCount) && c < len(tile.OffMeshCons); c++ {
+		con := &tile.OffMeshCons[c]
+		i := int(con.Poly)
+		if i >= int(tile.Header.PolyCount) || tile.Polys[i].GetType() != detour.DT_POLYTYPE_OFFMESH_CONNECTION {
+			this.fail(CHECK_OFFMESH_ENDPOINT, -1, con.Pos[0:3], "connection %d polygon %d is not an off-mesh connection", c, i)
+			continue
+		}
+		var start, end bool
+		for _, link := range this.links(i) {
+			start = start || link.Edge == 0
+			end = end || link.Edge == 1
+		}
+		if !start {
+			this.fail(CHECK_OFFMESH_ENDPOINT, i, con.Pos[0:3], "start point is not on the navmesh")
+		}
+		// The end point of a connection leaving the tile is only linked
+		// when the tile it lands on is loaded.
+		loaded := con.Side == 0xff ||
+			this.nav.GetNeighbourTilesAt(tile.Header.X, tile.Header.Y, int(con.Side), neis[:], MAX_NEIS) > 0
+		if loaded && !end {
+			this.fail(CHECK_OFFMESH_ENDPOINT, i, con.Pos[3:6], "end point is not on the navmesh")
+		}
+	}
+}
+
+func (this *linter) checkBVTree(valid []bool) {
+	tile := this.tile
+	header := tile.Header
+	if header.BvNodeCount == 0 {
+		return
+	}
+	if len(tile.BvTree) < int(header.BvNodeCount) {
+		this.fail(CHECK_BVTREE, -1, nil, "%d nodes out of %d", len(tile.BvTree), header.BvNodeCount)
+		return
+	}
+
+	// The builder may leave unused nodes after the tree, the escape index of
+	// the root gives its size.
+	end := 1
+	if tile.BvTree[0].I < 0 {
+		end = int(-tile.BvTree[0].I)
+	}
+	if end > int(header.BvNodeCount) {
+		this.fail(CHECK_BVTREE, -1, nil, "root escape index %d out of range", end)
+		return
+	}
+	seen := make([]bool, header.PolyCount)
+	for n := 0; n < end; n++ {
+		node := &tile.BvTree[n]
+		if node.I < 0 {
+			if n+int(-node.I) > end {
+				this.fail(CHECK_BVTREE, -1, nil, "node %d escape index %d out of range", n, -node.I)
+				return
+			}
+			continue
+		}
+		i := int(node.I)
+		if i >= int(header.PolyCount) {
+			this.fail(CHECK_BVTREE, -1, nil, "node %d polygon %d out of range", n, i)
+			continue
+		}
+		seen[i] = true
+		if !valid[i] {
+			continue
+		}
+		bmin, bmax := this.quantBounds(i)
+		for k := 0; k < 3; k++ {
+			// The builder truncates the bounds, allow one unit either way.
+			if int(node.Bmin[k]) > bmin[k]+1 || int(node.Bmax[k])+1 < bmax[k] {
+				this.fail(CHECK_BVTREE, i, this.vert(i, 0), "node %d bounds do not contain the polygon", n)
+				break
+			}
+		}
+	}
+	for i := range seen {
+		if !seen[i] && tile.Polys[i].GetType() == detour.DT_POLYTYPE_GROUND {
+			this.fail(CHECK_BVTREE, i, nil, "not in the tree, so it is not found by position")
+		}
+	}
+}
+
+// quantBounds returns the bounds of polygon i and of its detail mesh,
+// quantized like the nodes of the BV tree.
+func (this *linter) quantBounds(i int) (bmin, bmax [3]int) {
+	tile := this.tile
+	header := tile.Header
+	var fmin, fmax [3]float32
+	detour.DtVcopy(fmin[:], this.vert(i, 0))
+	detour.DtVcopy(fmax[:], this.vert(i, 0))
+	for k := 1; k < int(tile.Polys[i].VertCount); k++ {
+		detour.DtVmin(fmin[:], this.vert(i, k))
+		detour.DtVmax(fmax[:], this.vert(i, k))
+	}
+	if i < len(tile.DetailMeshes) {
+		pd := &tile.DetailMeshes[i]
+		for k := int(pd.VertBase); k < int(pd.VertBase)+int(pd.VertCount) && (k+1)*3 <= len(tile.DetailVerts); k++ {
+			detour.DtVmin(fmin[:], tile.DetailVerts[k*3:])
+			detour.DtVmax(fmax[:], tile.DetailVerts[k*3:])
+		}
+	}
+	for k := 0; k < 3; k++ {
+		bmin[k] = int(math.Floor(float64((fmin[k] - header.Bmin[k]) * header.BvQuantFactor)))
+		bmax[k] = int(math.Floor(float64((fmax[k] - header.Bmin[k]) * header.BvQuantFactor)))
+	}
+	return bmin, bmax
+}
+
+func edgeMid(a, b []float32) []float32 {
+	return []float32{(a[0] + b[0]) * 0.5, (a[1] + b[1]) * 0.5, (a[2] + b[2]) * 0.5}
+}
+
+func minMax(a, b float32) (float32, float32) {
+	if a < b {
+		return a, b
+	}
+	return b, a
+}
@@ -0,0 +1,219 @@
+// Package navlint checks the tiles of a navmesh for data that makes queries
+// fail or misbehave, such as polygons which are not convex or tile borders
+// which do not connect, and reports the problems found in a machine-readable
+// form, for example to reject broken map assets before they are published.
+package navlint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// Check identifies a kind of problem.
+type Check string
+
+const (
+	CHECK_DEGENERATE_POLY  Check = "degenerate-poly"  // Too few vertices, repeated vertices or no area.
+	CHECK_NONCONVEX_POLY   Check = "nonconvex-poly"   // A polygon is not convex.
+	CHECK_NEIGHBOURS       Check = "neighbours"       // The Neis of a polygon disagree with its links.
+	CHECK_OFFMESH_ENDPOINT Check = "offmesh-endpoint" // An off-mesh connection end point is not on the navmesh.
+	CHECK_DETAIL_MESH      Check = "detail-mesh"      // A detail triangle is outside its polygon.
+	CHECK_BVTREE           Check = "bvtree"           // A BV tree node does not contain its polygon.
+	CHECK_TILE_BORDER      Check = "tile-border"      // A portal edge does not connect to the neighbour tile.
+)
+
+// Checks lists every check, in the order they run.
+var Checks = []Check{
+	CHECK_DEGENERATE_POLY,
+	CHECK_NONCONVEX_POLY,
+	CHECK_NEIGHBOURS,
+	CHECK_OFFMESH_ENDPOINT,
+	CHECK_DETAIL_MESH,
+	CHECK_BVTREE,
+	CHECK_TILE_BORDER,
+}
+
+// Severity tells how bad a problem is.
+type Severity string
+
+const (
+	// SEVERITY_ERROR is for data the queries cannot handle correctly.
+	SEVERITY_ERROR Severity = "error"
+	// SEVERITY_WARNING is for data which is valid but likely unintended.
+	SEVERITY_WARNING Severity = "warning"
+)
+
+// DEFAULT_TOLERANCE is the default of Options.Tolerance. It is the
+// tolerance Detour uses to match the portal edges of neighbour tiles.
+const DEFAULT_TOLERANCE = 0.01
+
+// Options configures Lint. Zero fields get their defaults.
+type Options struct {
+	Tolerance float32 // Distance below which positions are considered equal, in world units.
+	Checks    []Check // Checks to run, all of them when empty.
+}
+
+// TileLocation is the location of a tile in the tile grid.
+type TileLocation struct {
+	X     int32 `json:"x"`
+	Y     int32 `json:"y"`
+	Layer int32 `json:"layer"`
+}
+
+// Issue is one problem found in a tile.
+type Issue struct {
+	Check    Check            `json:"check"`
+	Severity Severity         `json:"severity"`
+	Tile     TileLocation     `json:"tile"`
+	Poly     detour.DtPolyRef `json:"poly,omitempty"` // The polygon at fault, if any.
+	Pos      *[3]float32      `json:"pos,omitempty"`  // Where the problem is, if known.
+	Message  string           `json:"message"`
+}
+
+func (this Issue) String() string {
+	return fmt.Sprintf("%s: tile (%d, %d, %d) poly 0x%x: %s: %s",
+		this.Severity, this.Tile.X, this.Tile.Y, this.Tile.Layer, this.Poly, this.Check, this.Message)
+}
+
+// Report is the result of Lint.
+type Report struct {
+	Tiles    int     `json:"tiles"`
+	Polys    int     `json:"polys"`
+	Errors   int     `json:"errors"`
+	Warnings int     `json:"warnings"`
+	Issues   []Issue `json:"issues"`
+}
+
+// OK reports whether no error was found. Warnings are allowed.
+func (this *Report) OK() bool {
+	return this.Errors == 0
+}
+
+// WriteJSON writes the report as indented JSON.
+func (this *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(this)
+}
+
+// Lint checks every tile of navMesh. The tiles of the off-mesh connections
+// added at runtime are not tile data, and are left out.
+func Lint(navMesh *detour.DtNavMesh, opts *Options) *Report {
+	l := newLinter(navMesh, opts)
+	for i := 0; i < int(navMesh.GetMaxTiles()); i++ {
+		tile := navMesh.GetTile(i)
+		if tile.Header == nil || (tile.Flags&detour.DT_TILE_OFFMESH_CONNECTION) != 0 {
+			continue
+		}
+		l.lintTile(tile)
+	}
+	return l.report
+}
+
+// LintTile checks a single tile of navMesh. The tile is still checked
+// against its neighbours.
+func LintTile(navMesh *detour.DtNavMesh, tile *detour.DtMeshTile, opts *Options) *Report {
+	l := newLinter(navMesh, opts)
+	l.lintTile(tile)
+	return l.report
+}
+
+type linter struct {
+	nav    *detour.DtNavMesh
+	tol    float32
+	checks map[Check]bool
+	report *Report
+
+	// The tile being checked.
+	tile *detour.DtMeshTile
+	base detour.DtPolyRef
+	loc  TileLocation
+}
+
+func newLinter(navMesh *detour.DtNavMesh, opts *Options) *linter {
+	l := &linter{
+		nav:    navMesh,
+		tol:    DEFAULT_TOLERANCE,
+		checks: make(map[Check]bool),
+		report: &Report{Issues: []Issue{}},
+	}
+	checks := Checks
+	if opts != nil {
+		if opts.Tolerance > 0 {
+			l.tol = opts.Tolerance
+		}
+		if len(opts.Checks) != 0 {
+			checks = opts.Checks
+		}
+	}
+	for _, check := range checks {
+		l.checks[check] = true
+	}
+	return l
+}
+
+func (this *linter) lintTile(tile *detour.DtMeshTile) {
+	header := tile.Header
+	this.tile = tile
+	this.base = this.nav.GetPolyRefBase(tile)
+	this.loc = TileLocation{X: header.X, Y: header.Y, Layer: header.Layer}
+	this.report.Tiles++
+	this.report.Polys += int(header.PolyCount)
+
+	// The other checks rely on the polygon vertices being valid.
+	valid := make([]bool, header.PolyCount)
+	for i := range valid {
+		valid[i] = this.checkPolyVerts(i)
+	}
+	for i := range valid {
+		poly := &tile.Polys[i]
+		if !valid[i] || poly.GetType() != detour.DT_POLYTYPE_GROUND {
+			continue
+		}
+		if this.checks[CHECK_DEGENERATE_POLY] && !this.checkDegenerate(i) {
+			continue
+		}
+		if this.checks[CHECK_NONCONVEX_POLY] {
+			this.checkConvex(i)
+		}
+		if this.checks[CHECK_NEIGHBOURS] {
+			this.checkNeighbours(i, valid)
+		}
+		if this.checks[CHECK_DETAIL_MESH] {
+			this.checkDetailMesh(i)
+		}
+		if this.checks[CHECK_TILE_BORDER] {
+			this.checkTileBorder(i)
+		}
+	}
+	if this.checks[CHECK_OFFMESH_ENDPOINT] {
+		this.checkOffMeshConnections()
+	}
+	if this.checks[CHECK_BVTREE] {
+		this.checkBVTree(valid)
+	}
+}
+
+func (this *linter) addIssue(check Check, severity Severity, poly int, pos []float32, format string, args ...interface{}) {
+	issue := Issue{
+		Check:    check,
+		Severity: severity,
+		Tile:     this.loc,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if poly >= 0 {
+		issue.Poly = this.base | detour.DtPolyRef(poly)
+	}
+	if pos != nil {
+		issue.Pos = &[3]float32{pos[0], pos[1], pos[2]}
+	}
+	if severity == SEVERITY_ERROR {
+		this.report.Errors++
+	} else {
+		this.report.Warnings++
+	}
+	this.report.Issues = append(this.report.Issues, issue)
+}
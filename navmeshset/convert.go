@@ -0,0 +1,56 @@
+package navmeshset
+
+import (
+	"io"
+
+	dtcache "github.com/fananchong/recastnavigation-go/DetourTileCache"
+)
+
+// ConvertMeshSet converts a legacy navmesh set (MSET) to a version 2 container.
+// comp is only used with COMPRESSION_CUSTOM.
+func ConvertMeshSet(data []byte, w io.Writer, compression Compression, comp dtcache.DtTileCacheCompressor) error {
+	set, err := ParseMeshSet(data)
+	if err != nil {
+		return err
+	}
+	header := &Header{
+		Kind:        KIND_NAVMESH,
+		Compression: compression,
+		MeshParams:  set.Params,
+		BoundsMin:   set.BoundsMin,
+		BoundsMax:   set.BoundsMax,
+	}
+	return writeTiles(w, header, comp, set.Tiles)
+}
+
+// ConvertTileCacheSet converts a legacy tile cache set (TSET) to a version 2
+// container. The layers are already compressed by the tile cache, so
+// COMPRESSION_NONE is usually the right choice.
+func ConvertTileCacheSet(data []byte, w io.Writer, compression Compression, comp dtcache.DtTileCacheCompressor) error {
+	set, err := ParseTileCacheSet(data)
+	if err != nil {
+		return err
+	}
+	header := &Header{
+		Kind:        KIND_TILECACHE,
+		Compression: compression,
+		MeshParams:  set.MeshParams,
+		CacheParams: set.CacheParams,
+		BoundsMin:   set.BoundsMin,
+		BoundsMax:   set.BoundsMax,
+	}
+	return writeTiles(w, header, comp, set.Tiles)
+}
+
+func writeTiles(w io.Writer, header *Header, comp dtcache.DtTileCacheCompressor, tiles []MeshTile) error {
+	writer, err := NewWriter(w, header, comp)
+	if err != nil {
+		return err
+	}
+	for _, tile := range tiles {
+		if err := writer.WriteTile(tile.Ref, tile.Data); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
@@ -0,0 +1,34 @@
+// +build windows
+
+package navmeshset
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"unsafe"
+)
+
+func mapFile(f *os.File, size int) ([]byte, error) {
+	h, err := syscall.CreateFileMapping(syscall.Handle(f.Fd()), nil, syscall.PAGE_READONLY, 0, 0, nil)
+	if err != nil {
+		return nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	defer syscall.CloseHandle(h)
+
+	addr, err := syscall.MapViewOfFile(h, syscall.FILE_MAP_READ, 0, 0, uintptr(size))
+	if err != nil {
+		return nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+
+	var data []byte
+	sliceHeader := (*reflect.SliceHeader)((unsafe.Pointer(&data)))
+	sliceHeader.Cap = size
+	sliceHeader.Len = size
+	sliceHeader.Data = addr
+	return data, nil
+}
+
+func unmapFile(data []byte) error {
+	return syscall.UnmapViewOfFile(uintptr(unsafe.Pointer(&data[0])))
+}
@@ -0,0 +1,172 @@
+package navmeshset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+	dtcache "github.com/fananchong/recastnavigation-go/DetourTileCache"
+)
+
+// Magic numbers and versions of the legacy tile set files written by the
+// RecastDemo sample (Sample_TileMesh and Sample_TempObstacles).
+const (
+	NAVMESHSET_MAGIC       int32 = int32('M')<<24 | int32('S')<<16 | int32('A')<<8 | int32('T')
+	NAVMESHSET_VERSION     int32 = 1
+	TILECACHESET_MAGIC     int32 = int32('T')<<24 | int32('S')<<16 | int32('A')<<8 | int32('T')
+	TILECACHESET_VERSION   int32 = 1
+	navMeshSetHeaderSize         = 64
+	tileCacheSetHeaderSize       = 116
+
+	// The tile headers hold a tile reference and the data size, padded like
+	// the C struct: 8 bytes, or 16 with 64-bit references (dtpolyref64).
+	navMeshTileHeaderSize = 2 * detour.DT_REF_BITS / 8
+)
+
+var (
+	ErrWrongMagic   = errors.New("navmeshset: wrong magic")
+	ErrWrongVersion = errors.New("navmeshset: wrong version")
+	ErrTruncated    = errors.New("navmeshset: truncated file")
+	ErrUnaligned    = errors.New("navmeshset: tile data is not 4-byte aligned")
+)
+
+type navMeshSetHeader struct {
+	Magic     int32
+	Version   int32
+	NumTiles  int32
+	Params    detour.DtNavMeshParams
+	BoundsMin [3]float32
+	BoundsMax [3]float32
+}
+
+// MeshTile is one tile of a legacy navmesh set. Data points into the buffer
+// the set was parsed from.
+type MeshTile struct {
+	Ref  detour.DtTileRef
+	Data []byte
+}
+
+// MeshSet is a parsed legacy navmesh set (MSET) file.
+type MeshSet struct {
+	Params    detour.DtNavMeshParams
+	BoundsMin [3]float32
+	BoundsMax [3]float32
+	Tiles     []MeshTile
+}
+
+// ParseMeshSet parses a legacy navmesh set without copying the tile data.
+func ParseMeshSet(data []byte) (*MeshSet, error) {
+	if len(data) < navMeshSetHeaderSize {
+		return nil, ErrTruncated
+	}
+	var header navMeshSetHeader
+	if err := binary.Read(bytes.NewReader(data[:navMeshSetHeaderSize]), binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != NAVMESHSET_MAGIC {
+		return nil, ErrWrongMagic
+	}
+	if header.Version != NAVMESHSET_VERSION {
+		return nil, ErrWrongVersion
+	}
+
+	set := &MeshSet{
+		Params:    header.Params,
+		BoundsMin: header.BoundsMin,
+		BoundsMax: header.BoundsMax,
+	}
+	tiles, err := parseTiles(data, navMeshSetHeaderSize, int(header.NumTiles))
+	if err != nil {
+		return nil, err
+	}
+	set.Tiles = tiles
+	return set, nil
+}
+
+type tileCacheSetHeader struct {
+	Magic       int32
+	Version     int32
+	NumTiles    int32
+	MeshParams  detour.DtNavMeshParams
+	CacheParams dtcache.DtTileCacheParams
+	BoundsMin   [3]float32
+	BoundsMax   [3]float32
+}
+
+// TileCacheSet is a parsed legacy tile cache set (TSET) file. The tiles hold
+// compressed tile cache layers.
+type TileCacheSet struct {
+	MeshParams  detour.DtNavMeshParams
+	CacheParams dtcache.DtTileCacheParams
+	BoundsMin   [3]float32
+	BoundsMax   [3]float32
+	Tiles       []MeshTile
+}
+
+// ParseTileCacheSet parses a legacy tile cache set without copying the tile data.
+func ParseTileCacheSet(data []byte) (*TileCacheSet, error) {
+	if len(data) < tileCacheSetHeaderSize {
+		return nil, ErrTruncated
+	}
+	var header tileCacheSetHeader
+	if err := binary.Read(bytes.NewReader(data[:tileCacheSetHeaderSize]), binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != TILECACHESET_MAGIC {
+		return nil, ErrWrongMagic
+	}
+	if header.Version != TILECACHESET_VERSION {
+		return nil, ErrWrongVersion
+	}
+
+	set := &TileCacheSet{
+		MeshParams:  header.MeshParams,
+		CacheParams: header.CacheParams,
+		BoundsMin:   header.BoundsMin,
+		BoundsMax:   header.BoundsMax,
+	}
+	tiles, err := parseTiles(data, tileCacheSetHeaderSize, int(header.NumTiles))
+	if err != nil {
+		return nil, err
+	}
+	set.Tiles = tiles
+	return set, nil
+}
+
+func parseTiles(data []byte, d int, numTiles int) ([]MeshTile, error) {
+	var tiles []MeshTile
+	for i := 0; i < numTiles; i++ {
+		if len(data)-d < navMeshTileHeaderSize {
+			return nil, ErrTruncated
+		}
+		var tileRef detour.DtTileRef
+		var dataSize int32
+		if detour.DT_REF_BITS == 64 {
+			tileRef = detour.DtTileRef(binary.LittleEndian.Uint64(data[d:]))
+			dataSize = int32(binary.LittleEndian.Uint32(data[d+8:]))
+		} else {
+			tileRef = detour.DtTileRef(binary.LittleEndian.Uint32(data[d:]))
+			dataSize = int32(binary.LittleEndian.Uint32(data[d+4:]))
+		}
+		if tileRef == 0 || dataSize == 0 {
+			break
+		}
+		d += navMeshTileHeaderSize
+		if dataSize < 0 || len(data)-d < int(dataSize) {
+			return nil, ErrTruncated
+		}
+		tiles = append(tiles, MeshTile{
+			Ref:  tileRef,
+			Data: data[d : d+int(dataSize) : d+int(dataSize)],
+		})
+		d += int(dataSize)
+	}
+	return tiles, nil
+}
+
+// statusError wraps a failed detour status.
+func statusError(what string, status detour.DtStatus) error {
+	return fmt.Errorf("navmeshset: %s failed (status 0x%08x)", what, uint32(status))
+}
@@ -0,0 +1,95 @@
+package navmeshset
+
+import (
+	"os"
+	"unsafe"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// SharedMesh is a navmesh set mapped read-only into memory. Any number of
+// DtNavMesh instances can be created from it; they all reference the same
+// tile geometry, while polygon flags, areas and links are kept per instance.
+// See DtNavMesh.AddTileShared.
+type SharedMesh struct {
+	data   []byte
+	mapped bool
+	set    *MeshSet
+}
+
+// OpenShared memory-maps a legacy navmesh set file.
+func OpenShared(path string) (*SharedMesh, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < navMeshSetHeaderSize {
+		return nil, ErrTruncated
+	}
+	data, err := mapFile(f, int(fi.Size()))
+	if err != nil {
+		return nil, err
+	}
+	shared, err := NewShared(data)
+	if err != nil {
+		unmapFile(data)
+		return nil, err
+	}
+	shared.mapped = true
+	return shared, nil
+}
+
+// NewShared wraps a navmesh set that is already in memory. The buffer must
+// not be modified while navmeshes created from it are alive.
+func NewShared(data []byte) (*SharedMesh, error) {
+	set, err := ParseMeshSet(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, tile := range set.Tiles {
+		if uintptr(unsafe.Pointer(&tile.Data[0]))&3 != 0 {
+			return nil, ErrUnaligned
+		}
+	}
+	return &SharedMesh{data: data, set: set}, nil
+}
+
+// Set returns the parsed navmesh set.
+func (this *SharedMesh) Set() *MeshSet {
+	return this.set
+}
+
+// NewNavMesh creates a navmesh instance that references the shared tile data.
+func (this *SharedMesh) NewNavMesh() (*detour.DtNavMesh, error) {
+	navMesh := detour.DtAllocNavMesh()
+	status := navMesh.Init(&this.set.Params)
+	if detour.DtStatusFailed(status) {
+		return nil, statusError("init navmesh", status)
+	}
+	for _, tile := range this.set.Tiles {
+		status = navMesh.AddTileShared(tile.Data, len(tile.Data), tile.Ref, nil)
+		if detour.DtStatusFailed(status) {
+			return nil, statusError("add tile", status)
+		}
+	}
+	return navMesh, nil
+}
+
+// Close releases the mapping. It must only be called once no navmesh created
+// by NewNavMesh is used any more; buffers passed to NewShared are left alone.
+func (this *SharedMesh) Close() error {
+	data, mapped := this.data, this.mapped
+	this.data = nil
+	this.mapped = false
+	this.set = nil
+	if !mapped || data == nil {
+		return nil
+	}
+	return unmapFile(data)
+}
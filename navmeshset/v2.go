@@ -0,0 +1,525 @@
+package navmeshset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+	dtcache "github.com/fananchong/recastnavigation-go/DetourTileCache"
+	"github.com/fananchong/recastnavigation-go/fastlz"
+)
+
+// Version 2 tile set container.
+//
+// Layout (little-endian):
+//
+//	header      fileHeader
+//	tile data   one block per tile, each starting on a 16 byte boundary
+//	index       indexEntry * numTiles
+//	footer      fileFooter
+//
+// The index is written last so the file can be produced in a single pass,
+// and is located through the fixed size footer so a reader only has to touch
+// the header, the index and the tiles it actually loads.
+//
+// The index holds 64-bit tile references whatever the width of
+// detour.DtTileRef, and the header the width of the build which wrote them.
+const (
+	NAVSET2_MAGIC   int32 = int32('N')<<24 | int32('S')<<16 | int32('E')<<8 | int32('2')
+	NAVSET2_VERSION int32 = 2
+
+	fileHeaderSize = 124
+	indexEntrySize = 44
+	fileFooterSize = 20
+	tileAlignment  = 16
+)
+
+// Kind tells what the tiles of a container hold.
+type Kind int32
+
+const (
+	KIND_NAVMESH   Kind = 0 ///< Tiles are dtNavMesh tile data (See: #dtCreateNavMeshData).
+	KIND_TILECACHE Kind = 1 ///< Tiles are compressed tile cache layers.
+)
+
+// Compression is the codec applied to the stored tiles.
+type Compression int32
+
+const (
+	COMPRESSION_NONE   Compression = 0
+	COMPRESSION_FASTLZ Compression = 1
+	// Tiles are compressed with a caller supplied dtcache.DtTileCacheCompressor.
+	// The same compressor must be passed when the file is read back.
+	COMPRESSION_CUSTOM Compression = 2
+)
+
+const (
+	// The tile is stored compressed. Tiles that do not shrink are stored as is.
+	TILE_COMPRESSED uint32 = 0x01
+)
+
+var (
+	ErrChecksum         = errors.New("navmeshset: checksum mismatch")
+	ErrNoCompressor     = errors.New("navmeshset: custom compression needs a compressor")
+	ErrDecompress       = errors.New("navmeshset: tile decompression failed")
+	ErrWrongKind        = errors.New("navmeshset: wrong container kind")
+	ErrTileNotFound     = errors.New("navmeshset: tile not found")
+	ErrInvalidTile      = errors.New("navmeshset: invalid tile data")
+	ErrWriterClosed     = errors.New("navmeshset: writer closed")
+	ErrUnknownCodec     = errors.New("navmeshset: unknown compression")
+	ErrCompressorFailed = errors.New("navmeshset: tile compression failed")
+)
+
+// Header describes a version 2 container.
+type Header struct {
+	Kind        Kind
+	Compression Compression
+	MeshParams  detour.DtNavMeshParams
+	CacheParams dtcache.DtTileCacheParams // Only meaningful for KIND_TILECACHE.
+	BoundsMin   [3]float32
+	BoundsMax   [3]float32
+}
+
+type fileHeader struct {
+	Magic   int32
+	Version int32
+	RefBits int32 // detour.DT_REF_BITS of the writer.
+	Header
+}
+
+type fileFooter struct {
+	IndexOffset uint64
+	NumTiles    uint32
+	IndexCRC    uint32
+	Magic       int32
+}
+
+// TileInfo is one entry of the tile index.
+type TileInfo struct {
+	X, Y, Layer int32
+	// Tile reference the tile was saved with, 0 if unknown. The references
+	// saved by a build with another reference width do not decode the same
+	// way, and read as 0.
+	Ref     uint64
+	Offset  uint64 // Offset of the stored tile from the start of the file.
+	Size    uint32 // Stored size.
+	RawSize uint32 // Size after decompression.
+	CRC32   uint32 // IEEE CRC32 of the uncompressed tile.
+	Flags   uint32
+}
+
+func init() {
+	if binary.Size(fileHeader{}) != fileHeaderSize ||
+		binary.Size(TileInfo{}) != indexEntrySize ||
+		binary.Size(fileFooter{}) != fileFooterSize {
+		panic("navmeshset: unexpected on-disk structure size")
+	}
+}
+
+// TileLocation returns the grid location stored in the header of a tile.
+func TileLocation(kind Kind, data []byte) (x, y, layer int32, err error) {
+	switch kind {
+	case KIND_NAVMESH:
+		var header detour.DtMeshHeader
+		if len(data) < binary.Size(header) {
+			return 0, 0, 0, ErrInvalidTile
+		}
+		binary.Read(bytes.NewReader(data), binary.LittleEndian, &header)
+		if header.Magic != detour.DT_NAVMESH_MAGIC {
+			return 0, 0, 0, ErrWrongMagic
+		}
+		return header.X, header.Y, header.Layer, nil
+	case KIND_TILECACHE:
+		var header dtcache.DtTileCacheLayerHeader
+		if len(data) < binary.Size(header) {
+			return 0, 0, 0, ErrInvalidTile
+		}
+		binary.Read(bytes.NewReader(data), binary.LittleEndian, &header)
+		if header.Magic != dtcache.DT_TILECACHE_MAGIC {
+			return 0, 0, 0, ErrWrongMagic
+		}
+		return header.Tx, header.Ty, header.Tlayer, nil
+	}
+	return 0, 0, 0, ErrWrongKind
+}
+
+// Writer writes a version 2 container in a single pass.
+type Writer struct {
+	w       io.Writer
+	header  Header
+	comp    dtcache.DtTileCacheCompressor
+	offset  uint64
+	index   []TileInfo
+	scratch []byte
+	err     error
+	closed  bool
+}
+
+// NewWriter writes the container header to w and returns a writer for the tiles.
+// comp is only used, and then required, with COMPRESSION_CUSTOM.
+func NewWriter(w io.Writer, header *Header, comp dtcache.DtTileCacheCompressor) (*Writer, error) {
+	switch header.Compression {
+	case COMPRESSION_NONE, COMPRESSION_FASTLZ:
+	case COMPRESSION_CUSTOM:
+		if comp == nil {
+			return nil, ErrNoCompressor
+		}
+	default:
+		return nil, ErrUnknownCodec
+	}
+	if header.Kind != KIND_NAVMESH && header.Kind != KIND_TILECACHE {
+		return nil, ErrWrongKind
+	}
+
+	if header.Compression == COMPRESSION_FASTLZ {
+		comp = &fastlz.Compressor{}
+	}
+	this := &Writer{w: w, header: *header, comp: comp}
+	this.write(&fileHeader{Magic: NAVSET2_MAGIC, Version: NAVSET2_VERSION, RefBits: detour.DT_REF_BITS, Header: *header})
+	return this, this.err
+}
+
+func (this *Writer) write(v interface{}) {
+	if this.err != nil {
+		return
+	}
+	if b, ok := v.([]byte); ok {
+		_, this.err = this.w.Write(b)
+		this.offset += uint64(len(b))
+		return
+	}
+	this.err = binary.Write(this.w, binary.LittleEndian, v)
+	this.offset += uint64(binary.Size(v))
+}
+
+// WriteTile appends a tile. The grid location is read from the tile header.
+// ref is the tile reference to restore on load, or 0.
+func (this *Writer) WriteTile(ref detour.DtTileRef, data []byte) error {
+	if this.closed {
+		return ErrWriterClosed
+	}
+	if this.err != nil {
+		return this.err
+	}
+	x, y, layer, err := TileLocation(this.header.Kind, data)
+	if err != nil {
+		return err
+	}
+
+	info := TileInfo{
+		X:       x,
+		Y:       y,
+		Layer:   layer,
+		Ref:     uint64(ref),
+		RawSize: uint32(len(data)),
+		CRC32:   crc32.ChecksumIEEE(data),
+	}
+	stored, err := this.compress(data)
+	if err != nil {
+		return err
+	}
+	if len(stored) < len(data) {
+		info.Flags |= TILE_COMPRESSED
+	} else {
+		stored = data
+	}
+	info.Size = uint32(len(stored))
+
+	if pad := int(this.offset % tileAlignment); pad != 0 {
+		this.write(make([]byte, tileAlignment-pad))
+	}
+	info.Offset = this.offset
+	this.write(stored)
+	if this.err != nil {
+		return this.err
+	}
+	this.index = append(this.index, info)
+	return nil
+}
+
+func (this *Writer) compress(data []byte) ([]byte, error) {
+	if this.header.Compression == COMPRESSION_NONE {
+		return data, nil
+	}
+	max := this.comp.MaxCompressedSize(int32(len(data)))
+	this.scratch = growBuffer(this.scratch, int(max))
+	var n int32
+	status := this.comp.Compress(data, int32(len(data)), this.scratch, max, &n)
+	if detour.DtStatusFailed(status) {
+		return nil, ErrCompressorFailed
+	}
+	return this.scratch[:n], nil
+}
+
+// Close writes the tile index and the footer. It does not close the
+// underlying writer.
+func (this *Writer) Close() error {
+	if this.closed {
+		return this.err
+	}
+	this.closed = true
+
+	indexOffset := this.offset
+	var index bytes.Buffer
+	binary.Write(&index, binary.LittleEndian, this.index)
+	this.write(index.Bytes())
+	this.write(&fileFooter{
+		IndexOffset: indexOffset,
+		NumTiles:    uint32(len(this.index)),
+		IndexCRC:    crc32.ChecksumIEEE(index.Bytes()),
+		Magic:       NAVSET2_MAGIC,
+	})
+	return this.err
+}
+
+func growBuffer(b []byte, n int) []byte {
+	if cap(b) < n {
+		return make([]byte, n)
+	}
+	return b[:n]
+}
+
+type tileKey struct {
+	x, y, layer int32
+}
+
+// Reader gives random access to the tiles of a version 2 container. Only the
+// header and the index are read when it is opened; tiles are read on demand.
+type Reader struct {
+	r      io.ReaderAt
+	closer io.Closer
+	header Header
+	comp   dtcache.DtTileCacheCompressor
+	tiles  []TileInfo
+	lookup map[tileKey]int
+}
+
+// Open opens a version 2 container file. comp is only needed for files
+// written with COMPRESSION_CUSTOM.
+func Open(path string, comp dtcache.DtTileCacheCompressor) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	this, err := NewReader(f, fi.Size(), comp)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	this.closer = f
+	return this, nil
+}
+
+// NewReader reads the header and the tile index of a version 2 container of
+// the given size.
+func NewReader(r io.ReaderAt, size int64, comp dtcache.DtTileCacheCompressor) (*Reader, error) {
+	if size < fileHeaderSize+fileFooterSize {
+		return nil, ErrTruncated
+	}
+	var header fileHeader
+	if err := binary.Read(io.NewSectionReader(r, 0, fileHeaderSize), binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != NAVSET2_MAGIC {
+		return nil, ErrWrongMagic
+	}
+	if header.Version != NAVSET2_VERSION {
+		return nil, ErrWrongVersion
+	}
+	if header.Compression == COMPRESSION_CUSTOM && comp == nil {
+		return nil, ErrNoCompressor
+	}
+
+	var footer fileFooter
+	if err := binary.Read(io.NewSectionReader(r, size-fileFooterSize, fileFooterSize), binary.LittleEndian, &footer); err != nil {
+		return nil, err
+	}
+	if footer.Magic != NAVSET2_MAGIC {
+		return nil, ErrWrongMagic
+	}
+	indexSize := int64(footer.NumTiles) * indexEntrySize
+	if footer.IndexOffset < fileHeaderSize || int64(footer.IndexOffset)+indexSize > size-fileFooterSize {
+		return nil, ErrTruncated
+	}
+	index := make([]byte, indexSize)
+	if _, err := r.ReadAt(index, int64(footer.IndexOffset)); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(index) != footer.IndexCRC {
+		return nil, ErrChecksum
+	}
+
+	if header.Compression == COMPRESSION_FASTLZ {
+		comp = &fastlz.Compressor{}
+	}
+	this := &Reader{
+		r:      r,
+		header: header.Header,
+		comp:   comp,
+		tiles:  make([]TileInfo, footer.NumTiles),
+		lookup: make(map[tileKey]int, footer.NumTiles),
+	}
+	binary.Read(bytes.NewReader(index), binary.LittleEndian, this.tiles)
+	for i := range this.tiles {
+		info := &this.tiles[i]
+		if header.RefBits != detour.DT_REF_BITS {
+			info.Ref = 0
+		}
+		if info.Offset+uint64(info.Size) > footer.IndexOffset {
+			return nil, ErrTruncated
+		}
+		this.lookup[tileKey{info.X, info.Y, info.Layer}] = i
+	}
+	return this, nil
+}
+
+// Close closes the file opened by Open.
+func (this *Reader) Close() error {
+	if this.closer == nil {
+		return nil
+	}
+	err := this.closer.Close()
+	this.closer = nil
+	return err
+}
+
+// Header returns the container header.
+func (this *Reader) Header() *Header {
+	return &this.header
+}
+
+// Tiles returns the tile index.
+func (this *Reader) Tiles() []TileInfo {
+	return this.tiles
+}
+
+// FindTile looks up a tile in the index by grid location.
+func (this *Reader) FindTile(x, y, layer int32) (TileInfo, bool) {
+	i, ok := this.lookup[tileKey{x, y, layer}]
+	if !ok {
+		return TileInfo{}, false
+	}
+	return this.tiles[i], true
+}
+
+// TilesAt returns the index entries of all layers at a grid location.
+func (this *Reader) TilesAt(x, y int32) []TileInfo {
+	var tiles []TileInfo
+	for i := range this.tiles {
+		if this.tiles[i].X == x && this.tiles[i].Y == y {
+			tiles = append(tiles, this.tiles[i])
+		}
+	}
+	return tiles
+}
+
+// ReadTile reads, decompresses and verifies a tile. The returned buffer is
+// owned by the caller.
+func (this *Reader) ReadTile(info TileInfo) ([]byte, error) {
+	stored := make([]byte, info.Size)
+	if _, err := this.r.ReadAt(stored, int64(info.Offset)); err != nil {
+		return nil, err
+	}
+
+	data := stored
+	if info.Flags&TILE_COMPRESSED != 0 {
+		data = make([]byte, info.RawSize)
+		if this.comp == nil {
+			return nil, ErrUnknownCodec
+		}
+		var n int32
+		status := this.comp.Decompress(stored, int32(len(stored)), data, int32(len(data)), &n)
+		if detour.DtStatusFailed(status) || int(n) != len(data) {
+			return nil, ErrDecompress
+		}
+	}
+
+	if crc32.ChecksumIEEE(data) != info.CRC32 {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
+
+// ReadTileAt reads the tile at a grid location.
+func (this *Reader) ReadTileAt(x, y, layer int32) ([]byte, error) {
+	info, ok := this.FindTile(x, y, layer)
+	if !ok {
+		return nil, ErrTileNotFound
+	}
+	return this.ReadTile(info)
+}
+
+// AddTilesAt loads every layer at a grid location into a navmesh. Tiles are
+// added with the reference they were saved with, so poly refs stay stable
+// across unload and reload. It returns the number of tiles added.
+func (this *Reader) AddTilesAt(navMesh *detour.DtNavMesh, x, y int32) (int, error) {
+	if this.header.Kind != KIND_NAVMESH {
+		return 0, ErrWrongKind
+	}
+	count := 0
+	for _, info := range this.TilesAt(x, y) {
+		data, err := this.ReadTile(info)
+		if err != nil {
+			return count, err
+		}
+		status := navMesh.AddTile(data, len(data), detour.DT_TILE_FREE_DATA, detour.DtTileRef(info.Ref), nil)
+		if detour.DtStatusFailed(status) {
+			return count, statusError("add tile", status)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// AddCompressedTilesAt loads every layer at a grid location into a tile cache.
+func (this *Reader) AddCompressedTilesAt(tileCache *dtcache.DtTileCache, x, y int32) ([]dtcache.DtCompressedTileRef, error) {
+	if this.header.Kind != KIND_TILECACHE {
+		return nil, ErrWrongKind
+	}
+	var refs []dtcache.DtCompressedTileRef
+	for _, info := range this.TilesAt(x, y) {
+		data, err := this.ReadTile(info)
+		if err != nil {
+			return refs, err
+		}
+		var ref dtcache.DtCompressedTileRef
+		status := tileCache.AddTile(data, int32(len(data)), dtcache.DT_COMPRESSEDTILE_FREE_DATA, &ref)
+		if detour.DtStatusFailed(status) {
+			return refs, statusError("add compressed tile", status)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// LoadNavMesh creates a navmesh holding every tile of the container.
+func (this *Reader) LoadNavMesh() (*detour.DtNavMesh, error) {
+	if this.header.Kind != KIND_NAVMESH {
+		return nil, ErrWrongKind
+	}
+	navMesh := detour.DtAllocNavMesh()
+	status := navMesh.Init(&this.header.MeshParams)
+	if detour.DtStatusFailed(status) {
+		return nil, statusError("init navmesh", status)
+	}
+	for _, info := range this.tiles {
+		data, err := this.ReadTile(info)
+		if err != nil {
+			return nil, err
+		}
+		status = navMesh.AddTile(data, len(data), detour.DT_TILE_FREE_DATA, detour.DtTileRef(info.Ref), nil)
+		if detour.DtStatusFailed(status) {
+			return nil, statusError("add tile", status)
+		}
+	}
+	return navMesh, nil
+}
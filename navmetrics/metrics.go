@@ -0,0 +1,107 @@
+package navmetrics
+
+import (
+	"strconv"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+	dtcache "github.com/fananchong/recastnavigation-go/DetourTileCache"
+)
+
+type gatherer struct {
+	byName map[string]*Family
+	order  []string
+}
+
+func newGatherer() *gatherer {
+	return &gatherer{byName: make(map[string]*Family)}
+}
+
+// add appends a sample to the family name, creating the family on first use.
+// labels alternate names and values.
+func (this *gatherer) add(name, help string, value int, labels ...string) {
+	family := this.byName[name]
+	if family == nil {
+		family = &Family{Name: name, Help: help}
+		this.byName[name] = family
+		this.order = append(this.order, name)
+	}
+	sample := Sample{Value: float64(value)}
+	for i := 0; i+1 < len(labels); i += 2 {
+		sample.Labels = append(sample.Labels, Label{Name: labels[i], Value: labels[i+1]})
+	}
+	family.Samples = append(family.Samples, sample)
+}
+
+func (this *gatherer) families() []Family {
+	families := make([]Family, 0, len(this.order))
+	for _, name := range this.order {
+		families = append(families, *this.byName[name])
+	}
+	return sortedFamilies(families)
+}
+
+func (this *gatherer) navMesh(name string, stats detour.DtNavMeshStats, tileMetrics bool) {
+	const P = "detour_navmesh_"
+	this.add(P+"max_tiles", "Maximum number of tiles of the navmesh.", stats.MaxTiles, "mesh", name)
+	this.add(P+"tiles", "Number of tiles in use.", stats.Tiles, "mesh", name)
+	this.add(P+"offmesh_connection_tiles", "Number of tiles used by runtime off-mesh connections.", stats.OffMeshConTiles, "mesh", name)
+	this.add(P+"polys", "Number of polygons, off-mesh connections included.", stats.Polys, "mesh", name)
+	this.add(P+"verts", "Number of polygon vertices.", stats.Verts, "mesh", name)
+	this.add(P+"links", "Number of polygon links, by state.", stats.LinksUsed, "mesh", name, "state", "used")
+	this.add(P+"links", "", stats.LinksFree, "mesh", name, "state", "free")
+	this.add(P+"detail_meshes", "Number of detail sub-meshes.", stats.DetailMeshes, "mesh", name)
+	this.add(P+"detail_verts", "Number of unique detail mesh vertices.", stats.DetailVerts, "mesh", name)
+	this.add(P+"detail_tris", "Number of detail mesh triangles.", stats.DetailTris, "mesh", name)
+	this.add(P+"bv_nodes", "Number of bounding volume tree nodes.", stats.BvNodes, "mesh", name)
+	this.add(P+"offmesh_connections", "Number of off-mesh connections.", stats.OffMeshCons, "mesh", name)
+	this.add(P+"bytes", "Memory used by the tiles, by kind: tile data or private copies.", stats.DataBytes, "mesh", name, "kind", "data")
+	this.add(P+"bytes", "", stats.PrivateBytes, "mesh", name, "kind", "private")
+	if !tileMetrics {
+		return
+	}
+	for _, ts := range stats.TileStats {
+		x := strconv.Itoa(int(ts.X))
+		y := strconv.Itoa(int(ts.Y))
+		layer := strconv.Itoa(int(ts.Layer))
+		this.add(P+"tile_polys", "Number of polygons of a tile.", ts.Polys, "mesh", name, "x", x, "y", y, "layer", layer)
+		this.add(P+"tile_verts", "Number of polygon vertices of a tile.", ts.Verts, "mesh", name, "x", x, "y", y, "layer", layer)
+		this.add(P+"tile_links", "Number of links of a tile, by state.", ts.LinksUsed, "mesh", name, "x", x, "y", y, "layer", layer, "state", "used")
+		this.add(P+"tile_links", "", ts.LinksFree, "mesh", name, "x", x, "y", y, "layer", layer, "state", "free")
+		this.add(P+"tile_detail_tris", "Number of detail mesh triangles of a tile.", ts.DetailTris, "mesh", name, "x", x, "y", y, "layer", layer)
+		this.add(P+"tile_bv_nodes", "Number of bounding volume tree nodes of a tile.", ts.BvNodes, "mesh", name, "x", x, "y", y, "layer", layer)
+		this.add(P+"tile_bytes", "Memory used by a tile.", ts.DataBytes+ts.PrivateBytes, "mesh", name, "x", x, "y", y, "layer", layer)
+	}
+}
+
+func (this *gatherer) tileCache(name string, stats dtcache.DtTileCacheStats) {
+	const P = "detour_tilecache_"
+	this.add(P+"max_tiles", "Maximum number of compressed tiles.", stats.MaxTiles, "cache", name)
+	this.add(P+"tiles", "Number of compressed tiles in use.", stats.Tiles, "cache", name)
+	this.add(P+"compressed_bytes", "Size of the stored tile data.", stats.CompressedBytes, "cache", name)
+	this.add(P+"uncompressed_bytes", "Size of the tile data once decompressed.", stats.UncompressedBytes, "cache", name)
+	this.add(P+"max_obstacles", "Number of obstacle slots.", stats.MaxObstacles, "cache", name)
+	this.add(P+"obstacles", "Number of obstacle slots in use.", stats.Obstacles, "cache", name)
+	this.add(P+"pending_requests", "Number of obstacle requests waiting for the next update.", stats.PendingRequests, "cache", name)
+	this.add(P+"pending_updates", "Number of tiles waiting to be rebuilt.", stats.PendingUpdates, "cache", name)
+}
+
+func (this *gatherer) query(name string, stats detour.DtNavMeshQueryStats) {
+	const P = "detour_query_node_pool_"
+	for _, pool := range []struct {
+		name  string
+		stats detour.DtNodePoolStats
+	}{
+		{"main", stats.NodePool},
+		{"tiny", stats.TinyNodePool},
+		{"back", stats.BackNodePool},
+	} {
+		if pool.stats.MaxNodes == 0 {
+			continue
+		}
+		this.add(P+"capacity", "Number of nodes of the node pool.", pool.stats.MaxNodes, "query", name, "pool", pool.name)
+		this.add(P+"nodes", "Number of nodes used by the last search.", pool.stats.Nodes, "query", name, "pool", pool.name)
+		this.add(P+"high_water", "Largest number of nodes used by a search since the last reset.", pool.stats.HighWater, "query", name, "pool", pool.name)
+		this.add(P+"exhausted", "Number of nodes refused because the pool was full, since the last reset.", pool.stats.Exhausted, "query", name, "pool", pool.name)
+		this.add(P+"bytes", "Memory used by the node pool.", pool.stats.MemUsed, "query", name, "pool", pool.name)
+	}
+}
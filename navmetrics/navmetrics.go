@@ -0,0 +1,208 @@
+// Package navmetrics exports the statistics of navmeshes, tile caches and
+// query objects as Prometheus metrics, in the text exposition format, without
+// depending on a Prometheus client library.
+//
+// Sources are registered with a name, which becomes the value of the mesh,
+// cache or query label, and a function returning their statistics:
+//
+//	reg := navmetrics.NewRegistry()
+//	reg.AddNavMesh("world", func() detour.DtNavMeshStats { return mesh.Stats() })
+//	http.Handle("/metrics", reg)
+//
+// The functions are called on every scrape, from the goroutine serving it, so
+// they must synchronise with the goroutines using the source, for example
+// with SyncNavMesh.Read of package navigation.
+package navmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+	dtcache "github.com/fananchong/recastnavigation-go/DetourTileCache"
+)
+
+// CONTENT_TYPE is the content type of the text exposition format.
+const CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// Label is a metric label.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is one value of a metric family.
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Family is a named group of samples. Every metric is a gauge.
+type Family struct {
+	Name    string
+	Help    string
+	Samples []Sample
+}
+
+// Registry holds the sources to export. It is safe for concurrent use.
+type Registry struct {
+	// TileMetrics adds per-tile metrics, labelled with the tile location.
+	// They are off by default because their number grows with the tiles.
+	TileMetrics bool
+
+	mu         sync.Mutex
+	navMeshes  []navMeshSource
+	tileCaches []tileCacheSource
+	queries    []querySource
+}
+
+type navMeshSource struct {
+	name  string
+	stats func() detour.DtNavMeshStats
+}
+
+type tileCacheSource struct {
+	name  string
+	stats func() dtcache.DtTileCacheStats
+}
+
+type querySource struct {
+	name  string
+	stats func() detour.DtNavMeshQueryStats
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// AddNavMesh registers a navmesh under the mesh label name.
+func (this *Registry) AddNavMesh(name string, stats func() detour.DtNavMeshStats) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.navMeshes = append(this.navMeshes, navMeshSource{name, stats})
+}
+
+// AddTileCache registers a tile cache under the cache label name.
+func (this *Registry) AddTileCache(name string, stats func() dtcache.DtTileCacheStats) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.tileCaches = append(this.tileCaches, tileCacheSource{name, stats})
+}
+
+// AddQuery registers a query object under the query label name.
+func (this *Registry) AddQuery(name string, stats func() detour.DtNavMeshQueryStats) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.queries = append(this.queries, querySource{name, stats})
+}
+
+// Gather collects the statistics of every source. Families without samples
+// are left out.
+func (this *Registry) Gather() []Family {
+	this.mu.Lock()
+	navMeshes := append([]navMeshSource(nil), this.navMeshes...)
+	tileCaches := append([]tileCacheSource(nil), this.tileCaches...)
+	queries := append([]querySource(nil), this.queries...)
+	tileMetrics := this.TileMetrics
+	this.mu.Unlock()
+
+	g := newGatherer()
+	for _, src := range navMeshes {
+		g.navMesh(src.name, src.stats(), tileMetrics)
+	}
+	for _, src := range tileCaches {
+		g.tileCache(src.name, src.stats())
+	}
+	for _, src := range queries {
+		g.query(src.name, src.stats())
+	}
+	return g.families()
+}
+
+// WriteTo writes the metrics in the text exposition format.
+func (this *Registry) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, family := range this.Gather() {
+		fmt.Fprintf(cw, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		fmt.Fprintf(cw, "# TYPE %s gauge\n", family.Name)
+		for _, sample := range family.Samples {
+			cw.WriteString(family.Name)
+			if len(sample.Labels) != 0 {
+				cw.WriteString("{")
+				for i, label := range sample.Labels {
+					if i != 0 {
+						cw.WriteString(",")
+					}
+					fmt.Fprintf(cw, "%s=\"%s\"", label.Name, escapeLabelValue(label.Value))
+				}
+				cw.WriteString("}")
+			}
+			cw.WriteString(" ")
+			cw.WriteString(formatValue(sample.Value))
+			cw.WriteString("\n")
+		}
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves the metrics, so the registry can be used as the handler
+// of a scrape endpoint.
+func (this *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", CONTENT_TYPE)
+	this.WriteTo(w)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (this *countingWriter) Write(p []byte) (int, error) {
+	if this.err != nil {
+		return 0, this.err
+	}
+	n, err := this.w.Write(p)
+	this.n += int64(n)
+	this.err = err
+	return n, err
+}
+
+func (this *countingWriter) WriteString(s string) {
+	this.Write([]byte(s))
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabelValue(s string) string { return labelReplacer.Replace(s) }
+func escapeHelp(s string) string       { return helpReplacer.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedFamilies orders families by name, as some consumers expect.
+func sortedFamilies(families []Family) []Family {
+	sort.SliceStable(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+	return families
+}
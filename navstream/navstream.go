@@ -0,0 +1,475 @@
+// Package navstream keeps only the navmesh tiles near points of interest,
+// such as players, resident in a navmesh, for worlds too large to load
+// whole.
+//
+// A Manager owns a TileSource and a set of interest points. Each call to
+// Update loads the tiles within the load radius of an interest point and
+// unloads the tiles farther than the unload radius from all of them. The gap
+// between the two radii keeps tiles from being reloaded over and over by a
+// player walking along a tile border.
+//
+// Tiles are reloaded with the reference they had when they were unloaded, so
+// the polygon references held by agents stay valid across an unload and
+// reload. While a tile is unloaded its references are invalid; listeners are
+// told which tiles went away so agents whose corridors cross them can repath.
+package navstream
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navigation"
+	"github.com/fananchong/recastnavigation-go/navmeshset"
+)
+
+var (
+	ErrInvalidRadius = errors.New("navstream: unload radius smaller than load radius")
+	ErrTileLocation  = errors.New("navstream: tile data is for another grid location")
+	ErrNoFreeTile    = errors.New("navstream: no free tile in the navmesh")
+)
+
+// InterestID identifies an interest point, for example by player id.
+type InterestID uint64
+
+// Options configures a Manager. Zero fields get their defaults.
+type Options struct {
+	// LoadRadius is the distance around an interest point, on the xz-plane,
+	// within which tiles are loaded. Defaults to the tile width.
+	LoadRadius float32
+
+	// UnloadRadius is the distance from every interest point beyond which
+	// tiles are unloaded. It must not be smaller than LoadRadius. Defaults
+	// to LoadRadius plus half the tile width.
+	UnloadRadius float32
+
+	// MaxLoadsPerUpdate caps the number of grid locations loaded by one
+	// Update, nearest first, to spread the cost over several frames.
+	// Unlimited when 0.
+	MaxLoadsPerUpdate int
+
+	// Lock is held while tiles are added to or removed from the navmesh,
+	// for example SyncNavMesh.Locker of package navigation. Tiles are read
+	// from the source without holding it.
+	Lock sync.Locker
+}
+
+// Listener is told about the tiles a Manager loads and unloads. It is called
+// by the goroutine running Update, once the navmesh lock is released.
+type Listener interface {
+	// TilesLoaded is called once the tiles of every layer at grid location
+	// (x, y) are added to the navmesh.
+	TilesLoaded(x, y int32, refs []detour.DtTileRef)
+
+	// TilesUnloaded is called once the tiles of grid location (x, y) are
+	// removed from the navmesh. The polygon references of the tiles stay
+	// invalid until they are loaded again; see CrossesTiles.
+	TilesUnloaded(x, y int32, refs []detour.DtTileRef)
+}
+
+// UpdateResult tells what an Update did.
+type UpdateResult struct {
+	Loaded   int // Grid locations loaded.
+	Unloaded int // Grid locations unloaded.
+	Pending  int // Grid locations left to load because of MaxLoadsPerUpdate or errors.
+}
+
+type cell struct {
+	x, y int32
+}
+
+type tileKey struct {
+	x, y, layer int32
+}
+
+type resident struct {
+	refs   []detour.DtTileRef
+	layers []int32
+}
+
+// Manager loads and unloads the tiles of a navmesh around interest points.
+// Its methods are safe for concurrent use; Update calls are serialised.
+type Manager struct {
+	mesh   *detour.DtNavMesh
+	source TileSource
+	opts   Options
+	orig   [3]float32
+	width  float32
+	height float32
+
+	update sync.Mutex // Held by Update and UnloadAll.
+
+	mu        sync.Mutex
+	interests map[InterestID][3]float32
+	resident  map[cell]*resident
+	lastRefs  map[tileKey]detour.DtTileRef // Reference of every tile unloaded so far.
+	reserved  map[uint32]bool              // Tile indices of the unloaded tiles.
+	listeners []Listener
+	nextSlot  int
+}
+
+// NewManager returns a manager streaming the tiles of source into mesh. The
+// tiles already in mesh are left alone.
+func NewManager(mesh *detour.DtNavMesh, source TileSource, opts *Options) (*Manager, error) {
+	params := mesh.GetParams()
+	this := &Manager{
+		mesh:      mesh,
+		source:    source,
+		orig:      params.Orig,
+		width:     params.TileWidth,
+		height:    params.TileHeight,
+		interests: make(map[InterestID][3]float32),
+		resident:  make(map[cell]*resident),
+		lastRefs:  make(map[tileKey]detour.DtTileRef),
+		reserved:  make(map[uint32]bool),
+	}
+	if opts != nil {
+		this.opts = *opts
+	}
+	if this.opts.LoadRadius <= 0 {
+		this.opts.LoadRadius = this.width
+	}
+	if this.opts.UnloadRadius == 0 {
+		this.opts.UnloadRadius = this.opts.LoadRadius + this.width/2
+	}
+	if this.opts.UnloadRadius < this.opts.LoadRadius {
+		return nil, ErrInvalidRadius
+	}
+	return this, nil
+}
+
+// SetInterest adds an interest point, or moves it. The tiles around it are
+// loaded by the next Update.
+func (this *Manager) SetInterest(id InterestID, pos [3]float32) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.interests[id] = pos
+}
+
+// RemoveInterest removes an interest point. The tiles only it kept resident
+// are unloaded by the next Update.
+func (this *Manager) RemoveInterest(id InterestID) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	delete(this.interests, id)
+}
+
+// AddListener registers a listener.
+func (this *Manager) AddListener(listener Listener) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.listeners = append(this.listeners, listener)
+}
+
+// RemoveListener unregisters a listener added with AddListener.
+func (this *Manager) RemoveListener(listener Listener) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	for i, l := range this.listeners {
+		if l == listener {
+			this.listeners = append(this.listeners[:i:i], this.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// CellAt returns the grid location of the tiles containing pos.
+func (this *Manager) CellAt(pos [3]float32) (x, y int32) {
+	x = int32(math.Floor(float64((pos[0] - this.orig[0]) / this.width)))
+	y = int32(math.Floor(float64((pos[2] - this.orig[2]) / this.height)))
+	return x, y
+}
+
+// IsResident reports whether the manager has loaded grid location (x, y).
+// Locations without tiles in the source count as resident once visited.
+func (this *Manager) IsResident(x, y int32) bool {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	return this.resident[cell{x, y}] != nil
+}
+
+// ResidentTiles returns the number of tiles the manager has loaded.
+func (this *Manager) ResidentTiles() int {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	n := 0
+	for _, r := range this.resident {
+		n += len(r.refs)
+	}
+	return n
+}
+
+// Update unloads the tiles out of range of every interest point, then loads
+// the missing tiles in range, nearest first. A location that fails to load
+// is left unloaded and retried by the next Update; the errors are joined.
+func (this *Manager) Update() (UpdateResult, error) {
+	this.update.Lock()
+	defer this.update.Unlock()
+
+	this.mu.Lock()
+	points := make([][3]float32, 0, len(this.interests))
+	for _, pos := range this.interests {
+		points = append(points, pos)
+	}
+	var unload []cell
+	for c := range this.resident {
+		if this.distanceSqr(c, points) > this.opts.UnloadRadius*this.opts.UnloadRadius {
+			unload = append(unload, c)
+		}
+	}
+	wanted := make(map[cell]float32)
+	r := this.opts.LoadRadius
+	for _, pos := range points {
+		x0, y0 := this.CellAt([3]float32{pos[0] - r, 0, pos[2] - r})
+		x1, y1 := this.CellAt([3]float32{pos[0] + r, 0, pos[2] + r})
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				c := cell{x, y}
+				if this.resident[c] != nil {
+					continue
+				}
+				if d := this.distanceSqr(c, points); d <= r*r {
+					wanted[c] = d
+				}
+			}
+		}
+	}
+	this.mu.Unlock()
+
+	var result UpdateResult
+	var errs []error
+	sortCells(unload, nil)
+	for _, c := range unload {
+		if err := this.unloadCell(c); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result.Unloaded++
+	}
+
+	load := make([]cell, 0, len(wanted))
+	for c := range wanted {
+		load = append(load, c)
+	}
+	sortCells(load, wanted)
+	for i, c := range load {
+		if this.opts.MaxLoadsPerUpdate > 0 && result.Loaded == this.opts.MaxLoadsPerUpdate {
+			result.Pending += len(load) - i
+			break
+		}
+		if err := this.loadCell(c); err != nil {
+			errs = append(errs, err)
+			result.Pending++
+			continue
+		}
+		result.Loaded++
+	}
+	return result, errors.Join(errs...)
+}
+
+// UnloadAll unloads every tile the manager has loaded.
+func (this *Manager) UnloadAll() error {
+	this.update.Lock()
+	defer this.update.Unlock()
+
+	this.mu.Lock()
+	cells := make([]cell, 0, len(this.resident))
+	for c := range this.resident {
+		cells = append(cells, c)
+	}
+	this.mu.Unlock()
+
+	sortCells(cells, nil)
+	var errs []error
+	for _, c := range cells {
+		if err := this.unloadCell(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (this *Manager) loadCell(c cell) error {
+	tiles, err := this.source.LoadTiles(c.x, c.y)
+	if err != nil {
+		return fmt.Errorf("navstream: load tiles at (%d, %d): %w", c.x, c.y, err)
+	}
+	keys := make([]tileKey, len(tiles))
+	for i, tile := range tiles {
+		x, y, layer, err := navmeshset.TileLocation(navmeshset.KIND_NAVMESH, tile.Data)
+		if err == nil && (x != c.x || y != c.y) {
+			err = ErrTileLocation
+		}
+		if err != nil {
+			return fmt.Errorf("navstream: load tiles at (%d, %d): %w", c.x, c.y, err)
+		}
+		keys[i] = tileKey{x, y, layer}
+	}
+
+	r := &resident{}
+	this.lock()
+	this.mu.Lock()
+	for i, tile := range tiles {
+		if this.mesh.GetTileRefAt(keys[i].x, keys[i].y, keys[i].layer) != 0 {
+			// Added by someone else, so not ours to manage.
+			continue
+		}
+		ref, ok := this.chooseRef(keys[i], tile.Ref)
+		if !ok {
+			err = ErrNoFreeTile
+			break
+		}
+		status := this.mesh.AddTile(tile.Data, len(tile.Data), detour.DT_TILE_FREE_DATA, ref, nil)
+		if detour.DtStatusFailed(status) {
+			err = &navigation.StatusError{Op: "add tile", Status: status}
+			break
+		}
+		delete(this.reserved, this.mesh.DecodePolyIdTile(detour.DtPolyRef(ref)))
+		r.refs = append(r.refs, ref)
+		r.layers = append(r.layers, keys[i].layer)
+	}
+	if err != nil {
+		// Leave the location unloaded, to be retried as a whole.
+		this.removeTiles(c, r)
+		this.mu.Unlock()
+		this.unlock()
+		return fmt.Errorf("navstream: load tiles at (%d, %d): %w", c.x, c.y, err)
+	}
+	this.resident[c] = r
+	listeners := append([]Listener(nil), this.listeners...)
+	this.mu.Unlock()
+	this.unlock()
+
+	if len(r.refs) != 0 {
+		for _, l := range listeners {
+			l.TilesLoaded(c.x, c.y, r.refs)
+		}
+	}
+	return nil
+}
+
+func (this *Manager) unloadCell(c cell) error {
+	this.lock()
+	this.mu.Lock()
+	r := this.resident[c]
+	err := this.removeTiles(c, r)
+	delete(this.resident, c)
+	listeners := append([]Listener(nil), this.listeners...)
+	this.mu.Unlock()
+	this.unlock()
+
+	if len(r.refs) != 0 {
+		for _, l := range listeners {
+			l.TilesUnloaded(c.x, c.y, r.refs)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("navstream: unload tiles at (%d, %d): %w", c.x, c.y, err)
+	}
+	return nil
+}
+
+// removeTiles removes the tiles of r from the navmesh and remembers their
+// references for the next load. Called with both locks held.
+func (this *Manager) removeTiles(c cell, r *resident) error {
+	var err error
+	for i, ref := range r.refs {
+		status := this.mesh.RemoveTile(ref, nil, nil)
+		if detour.DtStatusFailed(status) && err == nil {
+			err = &navigation.StatusError{Op: "remove tile", Status: status}
+		}
+		this.lastRefs[tileKey{c.x, c.y, r.layers[i]}] = ref
+		this.reserved[this.mesh.DecodePolyIdTile(detour.DtPolyRef(ref))] = true
+	}
+	return err
+}
+
+// chooseRef returns the reference to add a tile with: the one it had when it
+// was last unloaded, else the one it was saved with, else a free tile index
+// not reserved by another unloaded tile. Called with both locks held.
+func (this *Manager) chooseRef(key tileKey, saved detour.DtTileRef) (detour.DtTileRef, bool) {
+	if ref, ok := this.lastRefs[key]; ok && this.isFree(ref) {
+		return ref, true
+	}
+	if saved != 0 && this.isFree(saved) && !this.reserved[this.mesh.DecodePolyIdTile(detour.DtPolyRef(saved))] {
+		return saved, true
+	}
+	maxTiles := int(this.mesh.GetMaxTiles())
+	for n := 0; n < maxTiles; n++ {
+		i := (this.nextSlot + n) % maxTiles
+		tile := this.mesh.GetTile(i)
+		if tile.Header == nil && !this.reserved[uint32(i)] {
+			this.nextSlot = (i + 1) % maxTiles
+			return this.mesh.GetTileRef(tile), true
+		}
+	}
+	return 0, false
+}
+
+func (this *Manager) isFree(ref detour.DtTileRef) bool {
+	it := this.mesh.DecodePolyIdTile(detour.DtPolyRef(ref))
+	return it < uint32(this.mesh.GetMaxTiles()) && this.mesh.GetTile(int(it)).Header == nil
+}
+
+func (this *Manager) lock() {
+	if this.opts.Lock != nil {
+		this.opts.Lock.Lock()
+	}
+}
+
+func (this *Manager) unlock() {
+	if this.opts.Lock != nil {
+		this.opts.Lock.Unlock()
+	}
+}
+
+// distanceSqr returns the squared distance on the xz-plane from the nearest
+// point to the bounds of grid location c.
+func (this *Manager) distanceSqr(c cell, points [][3]float32) float32 {
+	minX := this.orig[0] + float32(c.x)*this.width
+	minZ := this.orig[2] + float32(c.y)*this.height
+	best := float32(math.MaxFloat32)
+	for _, pos := range points {
+		dx := float32(math.Max(float64(minX-pos[0]), math.Max(0, float64(pos[0]-minX-this.width))))
+		dz := float32(math.Max(float64(minZ-pos[2]), math.Max(0, float64(pos[2]-minZ-this.height))))
+		if d := dx*dx + dz*dz; d < best {
+			best = d
+		}
+	}
+	return best
+}
+
+// sortCells orders cells by distance when given, then by location, so
+// updates are deterministic.
+func sortCells(cells []cell, dist map[cell]float32) {
+	sort.Slice(cells, func(i, j int) bool {
+		a, b := cells[i], cells[j]
+		if dist != nil && dist[a] != dist[b] {
+			return dist[a] < dist[b]
+		}
+		if a.y != b.y {
+			return a.y < b.y
+		}
+		return a.x < b.x
+	})
+}
+
+// CrossesTiles reports whether a polygon path, such as the corridor of an
+// agent, goes through one of the given tiles, for example those passed to
+// Listener.TilesUnloaded.
+func CrossesTiles(mesh *detour.DtNavMesh, path []detour.DtPolyRef, refs []detour.DtTileRef) bool {
+	for _, polyRef := range path {
+		var salt, it, ip uint32
+		mesh.DecodePolyId(polyRef, &salt, &it, &ip)
+		base := detour.DtTileRef(mesh.EncodePolyId(salt, it, 0))
+		for _, ref := range refs {
+			if base == ref {
+				return true
+			}
+		}
+	}
+	return false
+}
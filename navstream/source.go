@@ -0,0 +1,58 @@
+package navstream
+
+import (
+	detour "github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navmeshset"
+)
+
+// Tile is the data of one navmesh tile, as built by dtCreateNavMeshData.
+type Tile struct {
+	Data []byte
+	// Ref is the reference the tile was saved with, or 0 if unknown. It is
+	// only used the first time the tile is loaded; afterwards the manager
+	// reuses the reference the tile had when it was unloaded.
+	Ref detour.DtTileRef
+}
+
+// TileSource supplies the tiles of the world by grid location.
+type TileSource interface {
+	// LoadTiles returns the tiles of every layer at grid location (x, y),
+	// or none if there is no tile there. The manager takes ownership of the
+	// data. It is called by the goroutine running Manager.Update, without
+	// holding the navmesh lock.
+	LoadTiles(x, y int32) ([]Tile, error)
+}
+
+// SourceFunc adapts a function to TileSource, for tiles that are built or
+// fetched on demand.
+type SourceFunc func(x, y int32) ([]Tile, error)
+
+func (this SourceFunc) LoadTiles(x, y int32) ([]Tile, error) {
+	return this(x, y)
+}
+
+type readerSource struct {
+	r *navmeshset.Reader
+}
+
+// ReaderSource returns a source reading the tiles of a version 2 navmesh
+// container through its tile index. The reader must stay open while the
+// source is in use.
+func ReaderSource(r *navmeshset.Reader) TileSource {
+	return &readerSource{r}
+}
+
+func (this *readerSource) LoadTiles(x, y int32) ([]Tile, error) {
+	if this.r.Header().Kind != navmeshset.KIND_NAVMESH {
+		return nil, navmeshset.ErrWrongKind
+	}
+	var tiles []Tile
+	for _, info := range this.r.TilesAt(x, y) {
+		data, err := this.r.ReadTile(info)
+		if err != nil {
+			return nil, err
+		}
+		tiles = append(tiles, Tile{Data: data, Ref: detour.DtTileRef(info.Ref)})
+	}
+	return tiles, nil
+}
@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navimport"
+)
+
+func findPolyPathBidirectional(t *testing.T, mesh *detour.DtNavMesh, filter *detour.DtQueryFilter, start, end [3]float32) []detour.DtPolyRef {
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	var startRef, endRef detour.DtPolyRef
+	var startPos, endPos [3]float32
+	query.FindNearestPoly(start[:], ushapeHalfExtents[:], filter, &startRef, startPos[:])
+	query.FindNearestPoly(end[:], ushapeHalfExtents[:], filter, &endRef, endPos[:])
+	path := make([]detour.DtPolyRef, 32)
+	var pathCount int
+	stat := query.FindPathBidirectional(startRef, endRef, startPos[:], endPos[:], filter, path, &pathCount, len(path))
+	if stat != detour.DT_SUCCESS {
+		t.Fatalf("FindPathBidirectional: status 0x%x", stat)
+	}
+	return path[:pathCount]
+}
+
+func equalPaths(a, b []detour.DtPolyRef) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// loadOneWayUShape loads ushape.json with the jump made one-way, from the
+// left arm to the right arm.
+func loadOneWayUShape(t *testing.T) *detour.DtNavMesh {
+	f, err := os.Open("ushape.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := navimport.ParseJSON(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc.OffMeshConnections[0].Bidir = false
+	mesh, err := navimport.BuildNavMesh(nil, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mesh
+}
+
+func Test_FindPathBidirectional(t *testing.T) {
+	mesh := loadOneWayUShape(t)
+
+	left := [3]float32{5, 0, 25}
+	right := [3]float32{25, 0, 25}
+	walk := detour.DtAllocDtQueryFilter()
+	walk.SetExcludeFlags(USHAPE_FLAG_JUMP)
+	jump := detour.DtAllocDtQueryFilter()
+
+	for _, c := range []struct {
+		name       string
+		filter     *detour.DtQueryFilter
+		start, end [3]float32
+		polys      int
+	}{
+		{"walk", walk, left, right, 7},
+		{"jump", jump, left, right, 3},
+		{"jump back", jump, right, left, 7},
+	} {
+		want := findPolyPath(t, mesh, c.filter, c.start, c.end)
+		got := findPolyPathBidirectional(t, mesh, c.filter, c.start, c.end)
+		if len(got) != c.polys || !equalPaths(got, want) {
+			t.Errorf("%s: got %v, FindPath %v", c.name, got, want)
+		}
+	}
+}
+
+// corridorCost returns the cost of a path through the midpoints of its
+// portals, the way #FindPath computes it.
+func corridorCost(t *testing.T, mesh *detour.DtNavMesh, filter *detour.DtQueryFilter, path []detour.DtPolyRef, startPos, endPos []float32) float32 {
+	cost := float32(0)
+	pos := startPos
+	for i, ref := range path {
+		var tile *detour.DtMeshTile
+		var poly *detour.DtPoly
+		mesh.GetTileAndPolyByRefUnsafe(ref, &tile, &poly)
+		next := endPos
+		if i+1 < len(path) {
+			var link *detour.DtLink
+			for k := poly.FirstLink; k != detour.DT_NULL_LINK; k = tile.Links[k].Next {
+				if tile.Links[k].Ref == path[i+1] {
+					link = &tile.Links[k]
+				}
+			}
+			if link == nil || poly.GetType() != detour.DT_POLYTYPE_GROUND {
+				t.Fatalf("no portal from 0x%x to 0x%x", ref, path[i+1])
+			}
+			v0 := tile.Verts[uint32(poly.Verts[link.Edge])*3:]
+			v1 := tile.Verts[uint32(poly.Verts[(int(link.Edge)+1)%int(poly.VertCount)])*3:]
+			tmin, tmax := float32(0), float32(1)
+			if link.Side != 0xff {
+				tmin, tmax = float32(link.Bmin)/255, float32(link.Bmax)/255
+			}
+			var mid [3]float32
+			detour.DtVlerp(mid[:], v0, v1, (tmin+tmax)/2)
+			next = mid[:]
+		}
+		cost += filter.GetCost(pos, next, 0, nil, nil, ref, tile, poly, 0, nil, nil)
+		pos = next
+	}
+	return cost
+}
+
+func Test_FindPathBidirectionalCost(t *testing.T) {
+	mesh, _ := LoadDynamicMesh("scene1.obj.tilecache.bin")
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	filter := detour.DtAllocDtQueryFilter()
+	path := make([]detour.DtPolyRef, 256)
+	var pathCount int
+
+	paths, equal, exhausted := 0, 0, 0
+	sum := 0.0
+	for seed := int64(1); seed <= 20; seed++ {
+		rnd := rand.New(rand.NewSource(seed))
+		r := func() float32 { return rnd.Float32() }
+		for i := 0; i < 100; i++ {
+			var startRef, endRef detour.DtPolyRef
+			var startPos, endPos [3]float32
+			FindRandomPoint(query, filter, r, &startRef, startPos[:])
+			FindRandomPoint(query, filter, r, &endRef, endPos[:])
+			query.ResetStats()
+			status := query.FindPath(startRef, endRef, startPos[:], endPos[:], filter, path, &pathCount, len(path))
+			if status != detour.DT_SUCCESS || pathCount < 3 {
+				continue
+			}
+			paths++
+			want := corridorCost(t, mesh, filter, path[:pathCount], startPos[:], endPos[:])
+			maxNodes := query.Stats().NodePool.HighWater
+
+			status = query.FindPathBidirectional(startRef, endRef, startPos[:], endPos[:], filter, path, &pathCount, len(path))
+			if !detour.DtStatusSucceed(status) || detour.DtStatusDetail(status, detour.DT_PARTIAL_RESULT) ||
+				path[0] != startRef || path[pathCount-1] != endRef {
+				t.Fatalf("seed %d, pair %d: FindPathBidirectional: status 0x%x", seed, i, status)
+			}
+			// The two searches place their nodes on the edges they first
+			// reach, from opposite sides, so the path can cost a little
+			// more or less than the one of FindPath.
+			ratio := float64(corridorCost(t, mesh, filter, path[:pathCount], startPos[:], endPos[:]) / want)
+			if ratio > 1.03 {
+				t.Fatalf("seed %d, pair %d: path cost %v times that of FindPath", seed, i, ratio)
+			}
+			sum += ratio
+			if math.Abs(ratio-1) <= 1e-4 {
+				equal++
+			}
+
+			// With the nodes FindPath needed, the path is found even when the
+			// backward search runs out of nodes.
+			small := CreateQuery(mesh, maxNodes)
+			status = small.FindPathBidirectional(startRef, endRef, startPos[:], endPos[:], filter, path, &pathCount, len(path))
+			if detour.DtStatusFailed(status) || detour.DtStatusDetail(status, detour.DT_PARTIAL_RESULT) || path[pathCount-1] != endRef {
+				t.Fatalf("seed %d, pair %d: FindPathBidirectional with %d nodes: status 0x%x", seed, i, maxNodes, status)
+			}
+			if small.Stats().BackNodePool.Exhausted != 0 {
+				exhausted++
+			}
+		}
+	}
+	if paths == 0 || equal < paths*8/10 || sum/float64(paths) > 1.001 || exhausted == 0 {
+		t.Fatalf("%d of %d paths cost the same as FindPath, %v times on average, %d exhausted the backward pool",
+			equal, paths, sum/float64(paths), exhausted)
+	}
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navimport"
+)
+
+func Test_ComponentMap(t *testing.T) {
+	f, err := os.Open("ushape.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := navimport.ParseJSON(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cut the bottom of the U, leaving the arms joined by the one-way jump.
+	const FLAG_CUT = 2
+	cut := uint16(FLAG_CUT)
+	desc.Polys[1].Flags = &cut
+	desc.OffMeshConnections[0].Bidir = false
+	data, err := desc.CreateNavMeshData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mesh := detour.DtAllocNavMesh()
+	params := detour.DtNavMeshParams{TileWidth: 30, TileHeight: 30, MaxTiles: 2, MaxPolys: 16}
+	if status := mesh.Init(&params); detour.DtStatusFailed(status) {
+		t.Fatalf("Init: 0x%x", status)
+	}
+	var tileRef detour.DtTileRef
+	if status := mesh.AddTile(data, len(data), 0, 0, &tileRef); detour.DtStatusFailed(status) {
+		t.Fatalf("AddTile: 0x%x", status)
+	}
+	base := mesh.GetPolyRefBase(mesh.GetTileByRef(tileRef))
+	left, right, bottom := base|5, base|6, base|1
+
+	filter := detour.DtAllocDtQueryFilter()
+	filter.SetExcludeFlags(FLAG_CUT)
+	for _, c := range []struct {
+		options    detour.DtComponentOptions
+		components int
+	}{
+		{0, 2},
+		{detour.DT_COMPONENTS_OFFMESH, 2},
+		{detour.DT_COMPONENTS_ONEWAY, 1},
+	} {
+		components := detour.DtAllocComponentMap()
+		if status := components.Init(mesh, filter, c.options); detour.DtStatusFailed(status) {
+			t.Fatalf("Init: 0x%x", status)
+		}
+		if n := components.GetComponentCount(); n != c.components {
+			t.Fatalf("options %d: %d components, want %d", c.options, n, c.components)
+		}
+		if !components.IsReachable(base|0, left) || components.IsReachable(left, right) != (c.components == 1) {
+			t.Fatalf("options %d: wrong reachability", c.options)
+		}
+		if components.GetComponent(bottom) != 0 {
+			t.Fatalf("options %d: excluded polygon in a component", c.options)
+		}
+		detour.DtFreeComponentMap(components)
+	}
+
+	components := detour.DtAllocComponentMap()
+	if status := components.Init(mesh, filter, detour.DT_COMPONENTS_OFFMESH); detour.DtStatusFailed(status) {
+		t.Fatalf("Init: 0x%x", status)
+	}
+	defer detour.DtFreeComponentMap(components)
+
+	// Random points stay on the left arm.
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	r := rand.New(rand.NewSource(1))
+	frand := func() float32 { return r.Float32() }
+	leftComponent := components.GetComponent(left)
+	for i := 0; i < 50; i++ {
+		var ref detour.DtPolyRef
+		var pt [3]float32
+		status := query.FindRandomPointInComponent(components, leftComponent, filter, frand, &ref, pt[:])
+		if detour.DtStatusFailed(status) {
+			t.Fatalf("FindRandomPointInComponent: 0x%x", status)
+		}
+		if components.GetComponent(ref) != leftComponent || pt[0] > 10 {
+			t.Fatalf("random point %v on polygon 0x%x, off the left arm", pt, ref)
+		}
+	}
+
+	// Runtime connections join the arms while they exist.
+	con, status := mesh.AddOffMeshConnection([]float32{5, 0, 15}, []float32{25, 0, 15}, 1, true,
+		USHAPE_AREA_JUMP, USHAPE_FLAG_JUMP, 0)
+	if detour.DtStatusFailed(status) {
+		t.Fatalf("AddOffMeshConnection: 0x%x", status)
+	}
+	if !components.IsReachable(left, right) || components.GetComponentCount() != 1 {
+		t.Fatal("arms not joined by the connection")
+	}
+	if components.GetComponentPolyCount(components.GetComponent(con)) != 8 {
+		t.Fatalf("%d polygons in the component", components.GetComponentPolyCount(components.GetComponent(con)))
+	}
+
+	// Removing the tile splits them again.
+	if status := mesh.RemoveTile(tileRef, nil, nil); detour.DtStatusFailed(status) {
+		t.Fatalf("RemoveTile: 0x%x", status)
+	}
+	if components.GetComponentCount() != 1 || components.GetComponent(left) != 0 {
+		t.Fatalf("%d components without the tile", components.GetComponentCount())
+	}
+	if status := mesh.AddTile(data, len(data), 0, tileRef, nil); detour.DtStatusFailed(status) {
+		t.Fatalf("AddTile: 0x%x", status)
+	}
+	if !components.IsReachable(left, right) {
+		t.Fatal("arms not joined again")
+	}
+	if status := mesh.RemoveOffMeshConnection(con); detour.DtStatusFailed(status) {
+		t.Fatalf("RemoveOffMeshConnection: 0x%x", status)
+	}
+	if components.IsReachable(left, right) || components.GetComponentCount() != 2 {
+		t.Fatal("arms still joined")
+	}
+}
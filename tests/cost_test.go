@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/navigation"
+)
+
+func Test_PathDetails(t *testing.T) {
+	nav := newUShapeNavigator(t, nil)
+
+	// Across the road of the bottom arm.
+	details, err := nav.PathDetails(navigation.Vec3{5, 0, 5}, navigation.Vec3{25, 0, 5})
+	if err != nil || len(details.Points) != 4 || len(details.Corridor) != 3 {
+		t.Fatalf("PathDetails: %+v %v", details, err)
+	}
+	if details.Areas[0] != 0 || details.Areas[1] != 2 || details.Areas[2] != 0 {
+		t.Fatalf("Areas: %v", details.Areas)
+	}
+	if !IsEquals(details.Length, 20) || !IsEquals(details.Cost, 20) {
+		t.Fatalf("Length %v, Cost %v", details.Length, details.Cost)
+	}
+
+	start, end := navigation.Vec3{5, 0, 25}, navigation.Vec3{25, 0, 25}
+	around, err := nav.PathDetails(start, end)
+	if err != nil || len(around.Points) != 4 {
+		t.Fatalf("PathDetails: %+v %v", around, err)
+	}
+
+	targets := []navigation.Vec3{end, {5, 0, 5}, {15, 0, 25}, {5, 0, 25}}
+	costs, err := nav.PathCosts(start, targets, 0)
+	if err != nil || !IsEquals(costs[0], around.Cost) || !IsEquals(costs[1], 20) ||
+		costs[2] != navigation.Unreachable || costs[3] != 0 {
+		t.Fatalf("PathCosts: %v %v, want %v to the end", costs, err, around.Cost)
+	}
+	distances, err := nav.PathDistances(start, targets, 0)
+	if err != nil || !IsEquals(distances[0], around.Length) || !IsEquals(distances[1], 20) ||
+		distances[2] != navigation.Unreachable || distances[3] != 0 {
+		t.Fatalf("PathDistances: %v %v, want %v to the end", distances, err, around.Length)
+	}
+
+	// The end is beyond the cost limit.
+	costs, err = nav.PathCosts(start, targets, 30)
+	if err != nil || costs[0] != navigation.Unreachable || !IsEquals(costs[1], 20) {
+		t.Fatalf("PathCosts: %v %v", costs, err)
+	}
+
+	if _, err := nav.PathCosts(navigation.Vec3{15, 0, 25}, targets, 0); !errors.Is(err, navigation.ErrNotFound) {
+		t.Fatalf("PathCosts from the hole: %v", err)
+	}
+}
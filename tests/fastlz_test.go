@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/fastlz"
+)
+
+func Test_FastLZBound(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	comp := &fastlz.Compressor{}
+	for _, size := range []int{0, 1, 3, 4, 15, 16, 17, 100, 1000, 65535, 65536, 200000} {
+		input := make([]byte, size)
+		r.Read(input)
+
+		max := comp.MaxCompressedSize(int32(size))
+		compressed := make([]byte, max)
+		var compressedSize int32
+		stat := comp.Compress(input, int32(size), compressed, max, &compressedSize)
+		if !detour.DtStatusSucceed(stat) || compressedSize > max {
+			t.Fatalf("size %d: status 0x%x, %d > %d", size, stat, compressedSize, max)
+		}
+
+		output := make([]byte, size)
+		var outputSize int32
+		stat = comp.Decompress(compressed, compressedSize, output, int32(size), &outputSize)
+		if !detour.DtStatusSucceed(stat) || int(outputSize) != size || !bytes.Equal(input, output) {
+			t.Fatalf("size %d: round trip failed", size)
+		}
+	}
+}
+
+func Test_FastLZStream(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	input := make([]byte, 300000)
+	r.Read(input[:100000])
+	for i := 100000; i < len(input); i++ {
+		input[i] = byte(i / 1000)
+	}
+
+	var stream bytes.Buffer
+	w, err := fastlz.NewWriterSize(&stream, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(input); i += 777 {
+		end := i + 777
+		if end > len(input) {
+			end = len(input)
+		}
+		if _, err := w.Write(input[i:end]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stream.Len() >= len(input) {
+		t.Fatalf("stream not compressed: %d bytes", stream.Len())
+	}
+
+	output, err := ioutil.ReadAll(fastlz.NewReader(bytes.NewReader(stream.Bytes())))
+	if err != nil || !bytes.Equal(input, output) {
+		t.Fatalf("round trip failed: %v", err)
+	}
+
+	// Truncated stream.
+	_, err = ioutil.ReadAll(fastlz.NewReader(bytes.NewReader(stream.Bytes()[:stream.Len()-1])))
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("truncated stream: %v", err)
+	}
+
+	// Damaged payload.
+	damaged := append([]byte(nil), stream.Bytes()...)
+	damaged[len(damaged)/2] ^= 0x55
+	_, err = ioutil.ReadAll(fastlz.NewReader(bytes.NewReader(damaged)))
+	if err != fastlz.ErrChecksum && err != fastlz.ErrCorrupt {
+		t.Fatalf("damaged stream: %v", err)
+	}
+}
+
+func Test_FastLZCorrupt(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	input := make([]byte, 20000)
+	r.Read(input[:2000])
+	for i := 2000; i < len(input); i++ {
+		input[i] = input[i%1500] ^ byte(i/3000)
+	}
+	output := make([]byte, len(input))
+
+	for level := 1; level <= 2; level++ {
+		compressed := make([]byte, 2*len(input))
+		compressed = compressed[:fastlz.Fastlz_compress_level(level, input, len(input), compressed)]
+		if n := fastlz.Decompress(compressed, output); n != len(input) || !bytes.Equal(input, output) {
+			t.Fatalf("level %d: round trip failed", level)
+		}
+
+		// Truncated blocks decompress short, or not at all.
+		for n := 1; n < len(compressed); n++ {
+			if got := fastlz.Decompress(compressed[:n], output); got >= len(input) {
+				t.Fatalf("level %d: %d of %d bytes decompressed to %d", level, n, len(compressed), got)
+			}
+		}
+
+		// Damaged blocks do not write out of the output.
+		damaged := make([]byte, len(compressed))
+		for i := 0; i < 2000; i++ {
+			copy(damaged, compressed)
+			for k := 0; k < 1+i%4; k++ {
+				damaged[r.Intn(len(damaged))] = byte(r.Intn(256))
+			}
+			if got := fastlz.Decompress(damaged, output[:len(input)/2]); got > len(input)/2 {
+				t.Fatalf("level %d: %d bytes decompressed", level, got)
+			}
+		}
+	}
+
+	var compressedSize int32
+	comp := &fastlz.Compressor{}
+	if stat := comp.Decompress(input[:10], 100, output, int32(len(output)), &compressedSize); !detour.DtStatusFailed(stat) {
+		t.Fatalf("Decompress past the input: status 0x%x", stat)
+	}
+}
+
+func Test_FastLZStreamCorrupt(t *testing.T) {
+	input := make([]byte, 50000)
+	for i := range input {
+		input[i] = byte(i / 7 % 251)
+	}
+	var stream bytes.Buffer
+	w, err := fastlz.NewWriterSize(&stream, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(input)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := stream.Bytes()
+	const first = len(fastlz.STREAM_MAGIC)
+	stored := binary.LittleEndian.Uint32(data[first:])
+	if stored&fastlz.STREAM_BLOCK_RAW != 0 || stored <= 100 {
+		t.Fatalf("first block stored as 0x%x", stored)
+	}
+
+	// A block shorter than its compressed data.
+	damaged := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(damaged[first:], stored-100)
+	if _, err := ioutil.ReadAll(fastlz.NewReader(bytes.NewReader(damaged))); err != fastlz.ErrCorrupt {
+		t.Fatalf("shortened block: %v", err)
+	}
+
+	// Streams cut anywhere.
+	for n := 0; n < len(data); n++ {
+		if _, err := ioutil.ReadAll(fastlz.NewReader(bytes.NewReader(data[:n]))); err != io.ErrUnexpectedEOF {
+			t.Fatalf("stream cut at %d of %d: %v", n, len(data), err)
+		}
+	}
+
+	// Every damaged byte is reported.
+	for i := 0; i < len(data); i++ {
+		copy(damaged, data)
+		damaged[i] ^= 0x5a
+		if _, err := ioutil.ReadAll(fastlz.NewReader(bytes.NewReader(damaged))); err == nil {
+			t.Fatalf("stream damaged at %d of %d read", i, len(data))
+		}
+	}
+}
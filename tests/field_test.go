@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/navigation"
+)
+
+func Test_FlowField(t *testing.T) {
+	nav, err := navigation.New(loadOneWayUShape(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, right := navigation.Vec3{5, 0, 25}, navigation.Vec3{25, 0, 25}
+
+	// Seven polygons and the jump. The jump only goes from the left arm to the right one, so the fields
+	// toward each end must lead different ways.
+	for _, ends := range [][2]navigation.Vec3{{left, right}, {right, left}} {
+		from, to := ends[0], ends[1]
+		field, err := nav.FlowField(to, 0)
+		if err != nil || field.Target() != to || field.Polys() != 8 {
+			t.Fatalf("FlowField: %v", err)
+		}
+		want, err := nav.PathDetails(from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, err := nav.FlowPath(field, from)
+		if err != nil || len(path) != len(want.Points) {
+			t.Fatalf("FlowPath from %v: %v %v, want %v", from, path, err, want.Points)
+		}
+		for i := range path {
+			if !IsEquals(path[i][0], want.Points[i][0]) || !IsEquals(path[i][2], want.Points[i][2]) {
+				t.Fatalf("FlowPath from %v: %v, want %v", from, path, want.Points)
+			}
+		}
+		cost, err := nav.FlowCost(field, from)
+		if err != nil || math.Abs(float64(cost-want.Cost)) > 0.01*float64(want.Cost) {
+			t.Fatalf("FlowCost from %v: %v %v, want %v", from, cost, err, want.Cost)
+		}
+
+		dir, err := nav.FlowDirection(field, from)
+		dx, dz := path[1][0]-path[0][0], path[1][2]-path[0][2]
+		d := float32(math.Hypot(float64(dx), float64(dz)))
+		if err != nil || !IsEquals(dir[0], dx/d) || !IsEquals(dir[2], dz/d) {
+			t.Fatalf("FlowDirection from %v: %v %v", from, dir, err)
+		}
+	}
+
+	// Beyond the cost limit.
+	field, err := nav.FlowField(left, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nav.FlowDirection(field, right); err != navigation.ErrUnreachable {
+		t.Fatalf("FlowDirection beyond the limit: %v", err)
+	}
+	if cost, err := nav.FlowCost(field, right); err != nil || cost != navigation.Unreachable {
+		t.Fatalf("FlowCost beyond the limit: %v %v", cost, err)
+	}
+	if dir, err := nav.FlowDirection(field, left); err != nil || dir != (navigation.Vec3{}) {
+		t.Fatalf("FlowDirection at the target: %v %v", dir, err)
+	}
+}
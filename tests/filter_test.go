@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+)
+
+// blockingFilter is a custom filter that blocks single polygons and doubles
+// the cost of others.
+type blockingFilter struct {
+	*detour.DtQueryFilter
+	blocked   detour.DtPolyRef
+	expensive detour.DtPolyRef
+}
+
+func (this *blockingFilter) PassFilter(ref detour.DtPolyRef, tile *detour.DtMeshTile, poly *detour.DtPoly) bool {
+	return ref != this.blocked && this.DtQueryFilter.PassFilter(ref, tile, poly)
+}
+
+func (this *blockingFilter) GetCost(pa, pb []float32,
+	prevRef detour.DtPolyRef, prevTile *detour.DtMeshTile, prevPoly *detour.DtPoly,
+	curRef detour.DtPolyRef, curTile *detour.DtMeshTile, curPoly *detour.DtPoly,
+	nextRef detour.DtPolyRef, nextTile *detour.DtMeshTile, nextPoly *detour.DtPoly) float32 {
+	cost := this.DtQueryFilter.GetCost(pa, pb, prevRef, prevTile, prevPoly, curRef, curTile, curPoly, nextRef, nextTile, nextPoly)
+	if curRef == this.expensive {
+		cost *= 2
+	}
+	return cost
+}
+
+func Test_CustomFilter(t *testing.T) {
+	mesh := LoadJSONMesh("ushape.json")
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	base := mesh.GetPolyRefBase(mesh.GetTileAt(0, 0, 0))
+
+	filter := &blockingFilter{DtQueryFilter: detour.DtAllocDtQueryFilter()}
+	filter.SetExcludeFlags(USHAPE_FLAG_JUMP)
+
+	start := [3]float32{5, 0, 25}
+	end := [3]float32{25, 0, 25}
+	startRef, endRef := base|5, base|6
+
+	var path [16]detour.DtPolyRef
+	var pathCount int
+	stat := query.FindPath(startRef, endRef, start[:], end[:], filter, path[:], &pathCount, len(path))
+	if !detour.DtStatusSucceed(stat) || pathCount != 7 {
+		t.Fatalf("FindPath: status 0x%x, %d polys", stat, pathCount)
+	}
+
+	// Blocking the bottom middle square cuts the map in two.
+	filter.blocked = base | 1
+	stat = query.FindPath(startRef, endRef, start[:], end[:], filter, path[:], &pathCount, len(path))
+	if !detour.DtStatusDetail(stat, detour.DT_PARTIAL_RESULT) || path[pathCount-1] == endRef {
+		t.Fatalf("FindPath through blocked poly: status 0x%x, %v", stat, path[:pathCount])
+	}
+
+	// The jump is cheaper than walking around, even when its cost is doubled.
+	filter.blocked = 0
+	filter.SetExcludeFlags(0)
+	var offMeshRef detour.DtPolyRef = base | USHAPE_POLYS
+	filter.expensive = offMeshRef
+	stat = query.FindPath(startRef, endRef, start[:], end[:], filter, path[:], &pathCount, len(path))
+	if !detour.DtStatusSucceed(stat) || pathCount != 3 || path[1] != offMeshRef {
+		t.Fatalf("FindPath over the jump: status 0x%x, %v", stat, path[:pathCount])
+	}
+
+	if !query.IsValidPolyRef(offMeshRef, filter) {
+		t.Fatalf("IsValidPolyRef(%d) with custom filter", offMeshRef)
+	}
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/navdiff"
+	"github.com/fananchong/recastnavigation-go/navimport"
+)
+
+func Test_NavDiff(t *testing.T) {
+	landmarks := []navdiff.Landmark{
+		{Name: "left", Pos: [3]float32{5, 0, 25}},
+		{Name: "right", Pos: [3]float32{25, 0, 25}},
+	}
+	oldMesh := LoadJSONMesh("ushape.json")
+	report, err := navdiff.Compare(oldMesh, LoadJSONMesh("ushape.json"), &navdiff.Options{Landmarks: landmarks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Changed() || report.LandmarkPairs != 2 {
+		t.Fatalf("same builds differ: %+v", report)
+	}
+
+	// Remove the bottom of the U and the jump, and change the area of the
+	// polygon above the bottom left one.
+	f, err := os.Open("ushape.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := navimport.ParseJSON(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc.Polys[3].Area = 2
+	desc.Polys = append(desc.Polys[:1], desc.Polys[2:]...)
+	desc.OffMeshConnections = nil
+	newMesh, err := navimport.BuildNavMesh(nil, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err = navdiff.Compare(oldMesh, newMesh, &navdiff.Options{Landmarks: landmarks})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Tiles) != 1 {
+		t.Fatalf("%d tiles changed", len(report.Tiles))
+	}
+	diff := report.Tiles[0]
+	if diff.Change != navdiff.TILE_CHANGED || len(diff.RemovedPolys) != 2 || len(diff.AddedPolys) != 0 {
+		t.Fatalf("polygons: %+v", diff)
+	}
+	if len(diff.AreaChanges) != 1 || diff.AreaChanges[0].OldArea != 0 || diff.AreaChanges[0].NewArea != 2 {
+		t.Fatalf("area changes: %+v", diff.AreaChanges)
+	}
+	// Both sides of the bottom, and both ends of the jump.
+	if len(diff.LinkChanges) != 4 {
+		t.Fatalf("link changes: %+v", diff.LinkChanges)
+	}
+	if len(report.Reachability) != 2 || report.Reachability[0].Old != navdiff.REACHABLE ||
+		report.Reachability[0].New != navdiff.UNREACHABLE {
+		t.Fatalf("reachability: %+v", report.Reachability)
+	}
+}
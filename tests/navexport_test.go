@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navexport"
+)
+
+// gltfFile is the part of a glTF document the test reads.
+type gltfFile struct {
+	Meshes []struct {
+		Primitives []struct {
+			Attributes map[string]int `json:"attributes"`
+			Indices    *int           `json:"indices"`
+			Mode       int            `json:"mode"`
+		} `json:"primitives"`
+	} `json:"meshes"`
+	Accessors []struct {
+		BufferView    int    `json:"bufferView"`
+		ComponentType int    `json:"componentType"`
+		Count         int    `json:"count"`
+		Type          string `json:"type"`
+	} `json:"accessors"`
+	BufferViews []struct {
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+	} `json:"bufferViews"`
+	Buffers []struct {
+		ByteLength int    `json:"byteLength"`
+		URI        string `json:"uri"`
+	} `json:"buffers"`
+}
+
+// floats returns the values of an accessor, checking its count and type.
+func (this *gltfFile) floats(t *testing.T, buffer []byte, accessor int, count int, typ string) []float32 {
+	a := this.Accessors[accessor]
+	if a.Count != count || a.Type != typ || a.ComponentType != 5126 {
+		t.Fatalf("accessor %d: %d %s of type %d, want %d %s", accessor, a.Count, a.Type, a.ComponentType, count, typ)
+	}
+	view := this.BufferViews[a.BufferView]
+	values := make([]float32, view.ByteLength/4)
+	binary.Read(bytes.NewReader(buffer[view.ByteOffset:view.ByteOffset+view.ByteLength]), binary.LittleEndian, values)
+	if n := map[string]int{"SCALAR": 1, "VEC3": 3, "VEC4": 4}[typ]; len(values) != count*n {
+		t.Fatalf("accessor %d: %d values, want %d", accessor, len(values), count*n)
+	}
+	return values
+}
+
+// exportMesh returns scene1, with the flags of a polygon of its first tile
+// changed so they do not all read the same.
+func exportMesh() *detour.DtNavMesh {
+	mesh, _ := LoadDynamicMesh("scene1.obj.tilecache.bin")
+	for i := 0; i < int(mesh.GetMaxTiles()); i++ {
+		if tile := mesh.GetTile(i); tile.Header != nil {
+			mesh.SetPolyFlags(mesh.GetPolyRefBase(tile), 0x21)
+			break
+		}
+	}
+	return mesh
+}
+
+func Test_ExportGLTF(t *testing.T) {
+	mesh := exportMesh()
+
+	var out bytes.Buffer
+	if err := navexport.WriteGLTF(&out, mesh); err != nil {
+		t.Fatal(err)
+	}
+	var doc gltfFile
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	const prefix = "data:application/octet-stream;base64,"
+	if len(doc.Buffers) != 1 || !strings.HasPrefix(doc.Buffers[0].URI, prefix) {
+		t.Fatalf("buffers %+v", doc.Buffers)
+	}
+	buffer, err := base64.StdEncoding.DecodeString(doc.Buffers[0].URI[len(prefix):])
+	if err != nil || len(buffer) != doc.Buffers[0].ByteLength {
+		t.Fatalf("buffer of %d bytes: %v", len(buffer), err)
+	}
+
+	// The detail triangles of the ground polygons, by polygon.
+	want := make(map[detour.DtPolyRef]int)
+	areas := make(map[uint8]bool)
+	for i := 0; i < int(mesh.GetMaxTiles()); i++ {
+		tile := mesh.GetTile(i)
+		if tile.Header == nil {
+			continue
+		}
+		base := mesh.GetPolyRefBase(tile)
+		for j := 0; j < int(tile.Header.PolyCount); j++ {
+			if tile.Polys[j].GetType() == detour.DT_POLYTYPE_GROUND {
+				want[base|detour.DtPolyRef(j)] = int(tile.DetailMeshes[j].TriCount)
+				areas[tile.Polys[j].GetArea()] = true
+			}
+		}
+	}
+
+	// One primitive per area, the vertices of the triangles not being
+	// shared.
+	if len(doc.Meshes) != 1 || len(doc.Meshes[0].Primitives) != len(areas) {
+		t.Fatalf("%d meshes, want 1 with %d primitives", len(doc.Meshes), len(areas))
+	}
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	tris := make(map[detour.DtPolyRef]int)
+	for _, prim := range doc.Meshes[0].Primitives {
+		if prim.Mode != 4 || prim.Indices != nil {
+			t.Fatalf("primitive mode %d, indices %v", prim.Mode, prim.Indices)
+		}
+		count := doc.Accessors[prim.Attributes["POSITION"]].Count
+		if count%3 != 0 {
+			t.Fatalf("%d vertices", count)
+		}
+		pos := doc.floats(t, buffer, prim.Attributes["POSITION"], count, "VEC3")
+		area := doc.floats(t, buffer, prim.Attributes["_AREA"], count, "SCALAR")
+		flags := doc.floats(t, buffer, prim.Attributes["_FLAGS"], count, "SCALAR")
+		refs := doc.floats(t, buffer, prim.Attributes["_POLYREF"], count, "VEC4")
+		for i := 0; i < count; i++ {
+			var ref detour.DtPolyRef
+			for part := 0; part < 4; part++ {
+				ref |= detour.DtPolyRef(uint64(refs[i*4+part]) << (16 * uint(part)))
+			}
+			var polyTile *detour.DtMeshTile
+			var poly *detour.DtPoly
+			if detour.DtStatusFailed(mesh.GetTileAndPolyByRef(ref, &polyTile, &poly)) {
+				t.Fatalf("vertex %d: invalid ref 0x%x", i, ref)
+			}
+			if uint8(area[i]) != poly.GetArea() || uint16(flags[i]) != poly.Flags || area[i] != area[0] {
+				t.Fatalf("vertex %d of 0x%x: area %v flags %v, want %d %d", i, ref, area[i], flags[i], poly.GetArea(), poly.Flags)
+			}
+			// The vertex lies on the polygon.
+			var closest [3]float32
+			query.ClosestPointOnPolyBoundary(ref, pos[i*3:i*3+3], closest[:])
+			if detour.DtVdist2DSqr(closest[:], pos[i*3:i*3+3]) > 1e-6 {
+				t.Fatalf("vertex %d (%v) off polygon 0x%x", i, pos[i*3:i*3+3], ref)
+			}
+			if i%3 == 0 {
+				tris[ref]++
+			}
+		}
+	}
+
+	// Every ground polygon is exported, with its detail triangles.
+	if len(tris) != len(want) {
+		t.Fatalf("%d polygons exported, want %d", len(tris), len(want))
+	}
+	for ref, n := range want {
+		if tris[ref] != n {
+			t.Fatalf("poly 0x%x: %d triangles, want %d", ref, tris[ref], n)
+		}
+	}
+}
+
+func Test_ExportJSON(t *testing.T) {
+	mesh := exportMesh()
+
+	var out bytes.Buffer
+	if err := navexport.WriteJSON(&out, mesh); err != nil {
+		t.Fatal(err)
+	}
+	var graph navexport.Graph
+	if err := json.Unmarshal(out.Bytes(), &graph); err != nil {
+		t.Fatal(err)
+	}
+	if graph.Params != *mesh.GetParams() {
+		t.Fatalf("params %+v", graph.Params)
+	}
+
+	tiles := 0
+	for i := 0; i < int(mesh.GetMaxTiles()); i++ {
+		tile := mesh.GetTile(i)
+		if tile.Header == nil {
+			continue
+		}
+		if tiles >= len(graph.Tiles) {
+			t.Fatalf("%d tiles exported", len(graph.Tiles))
+		}
+		gt := &graph.Tiles[tiles]
+		tiles++
+		if gt.Ref != mesh.GetTileRef(tile) || gt.X != tile.Header.X || gt.Y != tile.Header.Y || gt.Layer != tile.Header.Layer ||
+			len(gt.Polys) != int(tile.Header.PolyCount) || len(gt.OffMeshConnections) != int(tile.Header.OffMeshConCount) {
+			t.Fatalf("tile 0x%x at (%d, %d, %d) with %d polys", gt.Ref, gt.X, gt.Y, gt.Layer, len(gt.Polys))
+		}
+
+		base := mesh.GetPolyRefBase(tile)
+		for j, gp := range gt.Polys {
+			poly := &tile.Polys[j]
+			if gp.Ref != base|detour.DtPolyRef(j) || gp.Area != poly.GetArea() || gp.Flags != poly.Flags ||
+				(gp.Type == "offmesh") != (poly.GetType() == detour.DT_POLYTYPE_OFFMESH_CONNECTION) {
+				t.Fatalf("poly 0x%x: %s area %d flags 0x%x, want %d 0x%x", gp.Ref, gp.Type, gp.Area, gp.Flags, poly.GetArea(), poly.Flags)
+			}
+			if len(gp.Verts) != int(poly.VertCount) {
+				t.Fatalf("poly 0x%x: %d verts, want %d", gp.Ref, len(gp.Verts), poly.VertCount)
+			}
+
+			// The neighbours are the links of the polygon, in order.
+			k := 0
+			for l := poly.FirstLink; l != detour.DT_NULL_LINK; l = tile.Links[l].Next {
+				link := &tile.Links[l]
+				if k >= len(gp.Neighbours) || gp.Neighbours[k] != (navexport.GraphLink{Ref: link.Ref, Edge: link.Edge, Side: link.Side}) {
+					t.Fatalf("poly 0x%x: neighbours %+v, link %d to 0x%x", gp.Ref, gp.Neighbours, k, link.Ref)
+				}
+				k++
+			}
+			if k != len(gp.Neighbours) {
+				t.Fatalf("poly 0x%x: %d neighbours, want %d", gp.Ref, len(gp.Neighbours), k)
+			}
+		}
+	}
+	if tiles != len(graph.Tiles) {
+		t.Fatalf("%d tiles exported, want %d", len(graph.Tiles), tiles)
+	}
+}
@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/DetourTileCache"
+	"github.com/fananchong/recastnavigation-go/navhpa"
+	"github.com/fananchong/recastnavigation-go/navimport"
+)
+
+// checkCorridor fails unless every polygon of path is valid and linked to the next.
+func checkCorridor(t *testing.T, mesh *detour.DtNavMesh, path []detour.DtPolyRef) {
+	for i, ref := range path {
+		var tile *detour.DtMeshTile
+		var poly *detour.DtPoly
+		if detour.DtStatusFailed(mesh.GetTileAndPolyByRef(ref, &tile, &poly)) {
+			t.Fatalf("path[%d] = 0x%x is not valid", i, ref)
+		}
+		if i == len(path)-1 {
+			break
+		}
+		linked := false
+		for l := poly.FirstLink; l != detour.DT_NULL_LINK; l = tile.Links[l].Next {
+			if tile.Links[l].Ref == path[i+1] {
+				linked = true
+				break
+			}
+		}
+		if !linked {
+			t.Fatalf("path[%d] = 0x%x is not linked to 0x%x", i, ref, path[i+1])
+		}
+	}
+}
+
+func Test_NavHPA(t *testing.T) {
+	mesh, tileCache := LoadDynamicMesh("scene1.obj.tilecache.bin")
+	filter := detour.DtAllocDtQueryFilter()
+	graph := navhpa.New(mesh, filter)
+	defer graph.Close()
+
+	// The refining query is much smaller than a single search needs.
+	query := CreateQuery(mesh, 256)
+	fullQuery := CreateQuery(mesh, 65535)
+	r := rand.New(rand.NewSource(1))
+	frand := func() float32 { return r.Float32() }
+
+	type point struct {
+		ref detour.DtPolyRef
+		pos [3]float32
+	}
+	randomPoint := func() point {
+		var p point
+		status := FindRandomPoint(fullQuery, filter, frand, &p.ref, p.pos[:])
+		if detour.DtStatusFailed(status) {
+			t.Fatalf("FindRandomPoint failed: 0x%x", status)
+		}
+		return p
+	}
+
+	check := func() {
+		found := 0
+		for i := 0; i < 50; i++ {
+			start, end := randomPoint(), randomPoint()
+			path := make([]detour.DtPolyRef, PATH_MAX_NODE)
+			var count int
+			status := fullQuery.FindPath(start.ref, end.ref, start.pos[:], end.pos[:], filter, path, &count, len(path))
+			got, err := graph.FindPath(query, start.ref, end.ref, start.pos, end.pos, PATH_MAX_NODE)
+			if detour.DtStatusDetail(status, detour.DT_PARTIAL_RESULT) {
+				if err != navhpa.ErrNoPath {
+					t.Fatalf("unreachable end: got error %v, want ErrNoPath", err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("FindPath: %v", err)
+			}
+			if got[0] != start.ref || got[len(got)-1] != end.ref {
+				t.Fatalf("path from 0x%x to 0x%x, want 0x%x to 0x%x", got[0], got[len(got)-1], start.ref, end.ref)
+			}
+			checkCorridor(t, mesh, got)
+			found++
+		}
+		if found == 0 {
+			t.Fatal("no reachable pairs")
+		}
+	}
+	check()
+
+	// Rebuilding tiles under obstacles must update the graph.
+	for i := 0; i < 8; i++ {
+		p := randomPoint()
+		var ob dtcache.DtObstacleRef
+		if status := tileCache.AddObstacle(p.pos[:], 2, 2, &ob); detour.DtStatusFailed(status) {
+			t.Fatalf("AddObstacle failed: 0x%x", status)
+		}
+	}
+	for upToDate := false; !upToDate; {
+		if status := tileCache.Update(0, mesh, &upToDate); detour.DtStatusFailed(status) {
+			t.Fatalf("Update failed: 0x%x", status)
+		}
+	}
+	check()
+}
+
+func Test_NavHPAOffMeshConnection(t *testing.T) {
+	// Three ushape tiles side by side, A, B and C, with room for a
+	// connection.
+	mesh := detour.DtAllocNavMesh()
+	params := detour.DtNavMeshParams{TileWidth: 30, TileHeight: 30, MaxTiles: 4, MaxPolys: 16}
+	if status := mesh.Init(&params); detour.DtStatusFailed(status) {
+		t.Fatalf("Init: 0x%x", status)
+	}
+	var bases [3]detour.DtPolyRef
+	for x := range bases {
+		f, err := os.Open("ushape.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc, err := navimport.ParseJSON(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc.OffMeshConnections = nil
+		desc.TileX = int32(x)
+		desc.BorderPortals = true
+		for i := range desc.Verts {
+			desc.Verts[i][0] += float32(30 * x)
+		}
+		data, err := desc.CreateNavMeshData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ref detour.DtTileRef
+		if status := mesh.AddTile(data, len(data), detour.DT_TILE_FREE_DATA, 0, &ref); detour.DtStatusFailed(status) {
+			t.Fatalf("AddTile: 0x%x", status)
+		}
+		bases[x] = mesh.GetPolyRefBase(mesh.GetTileByRef(ref))
+	}
+
+	// With the bottom of A blocked, its left arm is only left through a
+	// connection landing on the bottom of B, on the way to C.
+	overlay := detour.DtAllocCostOverlay()
+	overlay.SetPolyBlocked(bases[0]|1, true)
+	filter := detour.DtAllocOverlayFilter(detour.DtAllocDtQueryFilter(), overlay)
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	startRef, endRef := bases[0]|3, bases[2]|3
+	startPos, endPos := [3]float32{5, 0, 15}, [3]float32{65, 0, 15}
+
+	addConnection := func() detour.DtPolyRef {
+		ref, status := mesh.AddOffMeshConnection([]float32{8, 0, 25}, []float32{45, 0, 5}, 1, false, 0, 1, 0)
+		if detour.DtStatusFailed(status) {
+			t.Fatalf("AddOffMeshConnection: 0x%x", status)
+		}
+		return ref
+	}
+	check := func(graph *navhpa.Graph, con detour.DtPolyRef) {
+		path := make([]detour.DtPolyRef, 32)
+		var count int
+		status := query.FindPath(startRef, endRef, startPos[:], endPos[:], filter, path, &count, len(path))
+		if status != detour.DT_SUCCESS {
+			t.Fatalf("FindPath: status 0x%x", status)
+		}
+		got, err := graph.FindPath(query, startRef, endRef, startPos, endPos, len(path))
+		if err != nil {
+			t.Fatalf("navhpa FindPath: %v", err)
+		}
+		checkCorridor(t, mesh, got)
+		crosses := false
+		for _, ref := range got {
+			crosses = crosses || ref == con
+		}
+		if got[0] != startRef || got[len(got)-1] != endRef || !crosses {
+			t.Fatalf("navhpa FindPath: %v, want a path through 0x%x", got, con)
+		}
+	}
+
+	// The connection is added before the graph is built, then again after.
+	con := addConnection()
+	graph := navhpa.New(mesh, filter)
+	defer graph.Close()
+	check(graph, con)
+	if status := mesh.RemoveOffMeshConnection(con); detour.DtStatusFailed(status) {
+		t.Fatalf("RemoveOffMeshConnection: 0x%x", status)
+	}
+	if _, err := graph.FindPath(query, startRef, endRef, startPos, endPos, 32); err != navhpa.ErrNoPath {
+		t.Fatalf("navhpa FindPath without the connection: %v", err)
+	}
+	check(graph, addConnection())
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navigation"
+)
+
+func newUShapeNavigator(t *testing.T, opts *navigation.Options) *navigation.Navigator {
+	nav, err := navigation.New(LoadJSONMesh("ushape.json"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := detour.DtAllocDtQueryFilter()
+	filter.SetExcludeFlags(USHAPE_FLAG_JUMP)
+	nav.SetFilter(filter)
+	return nav
+}
+
+func Test_NavigationPath(t *testing.T) {
+	nav := newUShapeNavigator(t, nil)
+
+	path, err := nav.Path(navigation.Vec3{5, 0, 25}, navigation.Vec3{25, 0, 25})
+	if err != nil || len(path) != 4 || path[1] != (navigation.Vec3{10, 0, 10}) {
+		t.Fatalf("Path: %v %v", path, err)
+	}
+
+	if _, _, err := nav.Nearest(navigation.Vec3{15, 0, 25}); err != navigation.ErrNotFound {
+		t.Fatalf("Nearest in the hole: %v", err)
+	}
+
+	hit, err := nav.Raycast(navigation.Vec3{5, 0, 25}, navigation.Vec3{25, 0, 25})
+	if err != nil || !hit.Hit || !IsEquals(hit.T, 0.25) || !IsEquals(hit.Point[0], 10) {
+		t.Fatalf("Raycast: %+v %v", hit, err)
+	}
+	hit, err = nav.Raycast(navigation.Vec3{5, 0, 5}, navigation.Vec3{25, 0, 5})
+	if err != nil || hit.Hit || len(hit.Path) != 3 {
+		t.Fatalf("Raycast: %+v %v", hit, err)
+	}
+}
+
+func Test_NavigationErrors(t *testing.T) {
+	// Too few nodes to go around the hole.
+	nav := newUShapeNavigator(t, &navigation.Options{MaxNodes: 4})
+	path, err := nav.Path(navigation.Vec3{5, 0, 25}, navigation.Vec3{25, 0, 25})
+	if !errors.Is(err, navigation.ErrOutOfNodes) || !errors.Is(err, navigation.ErrPartialResult) ||
+		errors.Is(err, navigation.ErrFailure) || !navigation.IsIncomplete(err) || len(path) == 0 {
+		t.Fatalf("Path: %v %v", path, err)
+	}
+
+	// Corridor longer than the result buffer.
+	nav = newUShapeNavigator(t, &navigation.Options{MaxPath: 4})
+	corridor, err := nav.PolyPath(navigation.Vec3{5, 0, 25}, navigation.Vec3{25, 0, 25})
+	if !errors.Is(err, navigation.ErrBufferTooSmall) || len(corridor) != 4 {
+		t.Fatalf("PolyPath: %v %v", corridor, err)
+	}
+}
+
+func Test_NavigationContext(t *testing.T) {
+	nav := newUShapeNavigator(t, nil)
+	start, end := navigation.Vec3{5, 0, 25}, navigation.Vec3{25, 0, 25}
+
+	want, err := nav.Path(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := nav.PathContext(context.Background(), start, end)
+	if err != nil || len(path) != len(want) {
+		t.Fatalf("PathContext: %v %v, want %v", path, err, want)
+	}
+
+	// A done context still returns the best path found so far.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	corridor, err := nav.PolyPathContext(ctx, start, end)
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, navigation.ErrPartialResult) ||
+		!navigation.IsIncomplete(err) || len(corridor) != 1 {
+		t.Fatalf("PolyPathContext: %v %v", corridor, err)
+	}
+	path, err = nav.PathContext(ctx, start, end)
+	if !errors.Is(err, context.Canceled) || len(path) == 0 || path[0] != start {
+		t.Fatalf("PathContext: %v %v", path, err)
+	}
+
+	// The sliced driver stops at a done context and can be resumed.
+	query := nav.Query()
+	startRef, startPos, _ := nav.Nearest(start)
+	endRef, endPos, _ := nav.Nearest(end)
+	status := query.InitSlicedFindPath(startRef, endRef, startPos[:], endPos[:], nav.Filter(), 0)
+	var iters int
+	status = query.UpdateSlicedFindPathContext(ctx, 1000, 1, &iters)
+	if !detour.DtStatusInProgress(status) || iters != 0 {
+		t.Fatalf("UpdateSlicedFindPathContext: status 0x%x after %d iterations", status, iters)
+	}
+	status = query.UpdateSlicedFindPathContext(context.Background(), 1000, 1, &iters)
+	if status != detour.DT_SUCCESS || iters == 0 {
+		t.Fatalf("UpdateSlicedFindPathContext: status 0x%x after %d iterations", status, iters)
+	}
+	refs := make([]detour.DtPolyRef, 16)
+	var count int
+	status = query.FinalizeSlicedFindPath(refs, &count, len(refs))
+	if status != detour.DT_SUCCESS || count < 3 || refs[count-1] != endRef {
+		t.Fatalf("FinalizeSlicedFindPath: status 0x%x %v", status, refs[:count])
+	}
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navexport"
+	"github.com/fananchong/recastnavigation-go/navimport"
+)
+
+// The U shaped test map: seven 10x10 squares around a hole at x in [10, 20],
+// z in [10, 30], and a bidirectional off-mesh connection across the hole.
+const (
+	USHAPE_POLYS     = 7
+	USHAPE_AREA_JUMP = 5
+	USHAPE_FLAG_JUMP = 8
+)
+
+var ushapeHalfExtents = [3]float32{1, 1, 1}
+
+func findPolyPath(t *testing.T, mesh *detour.DtNavMesh, filter *detour.DtQueryFilter, start, end [3]float32) []detour.DtPolyRef {
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	var startRef, endRef detour.DtPolyRef
+	var startPos, endPos [3]float32
+	query.FindNearestPoly(start[:], ushapeHalfExtents[:], filter, &startRef, startPos[:])
+	query.FindNearestPoly(end[:], ushapeHalfExtents[:], filter, &endRef, endPos[:])
+	if startRef == 0 || endRef == 0 {
+		t.Fatalf("FindNearestPoly: start %d end %d", startRef, endRef)
+	}
+	path := make([]detour.DtPolyRef, 32)
+	var pathCount int
+	stat := query.FindPath(startRef, endRef, startPos[:], endPos[:], filter, path, &pathCount, len(path))
+	if !detour.DtStatusSucceed(stat) || detour.DtStatusDetail(stat, detour.DT_PARTIAL_RESULT) {
+		t.Fatalf("FindPath: status 0x%x", stat)
+	}
+	return path[:pathCount]
+}
+
+func Test_ImportJSON(t *testing.T) {
+	mesh := LoadJSONMesh("ushape.json")
+	tile := mesh.GetTileAt(0, 0, 0)
+	if tile == nil || tile.Header.PolyCount != USHAPE_POLYS+1 || tile.Header.OffMeshConCount != 1 {
+		t.Fatalf("unexpected tile %+v", tile)
+	}
+
+	var area uint8
+	mesh.GetPolyArea(mesh.GetPolyRefBase(tile)|1, &area)
+	if area != 2 {
+		t.Fatalf("area of poly 1 = %d, want 2", area)
+	}
+
+	start := [3]float32{5, 0, 25}
+	end := [3]float32{25, 0, 25}
+
+	// Walking around the hole.
+	filter := detour.DtAllocDtQueryFilter()
+	filter.SetExcludeFlags(USHAPE_FLAG_JUMP)
+	path := findPolyPath(t, mesh, filter, start, end)
+	if len(path) != 7 {
+		t.Fatalf("walking path has %d polys, want 7", len(path))
+	}
+
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	var straight [8 * 3]float32
+	var straightCount int
+	query.FindStraightPath(start[:], end[:], path, len(path), straight[:], nil, nil, &straightCount, 8, 0)
+	if straightCount != 4 ||
+		!IsEquals(straight[3], 10) || !IsEquals(straight[5], 10) ||
+		!IsEquals(straight[6], 20) || !IsEquals(straight[8], 10) {
+		t.Fatalf("straight path %v (%d points)", straight[:straightCount*3], straightCount)
+	}
+
+	var hitT float32
+	var hitNormal [3]float32
+	var rayPath [8]detour.DtPolyRef
+	var rayCount int
+	query.Raycast(path[0], start[:], end[:], filter, &hitT, hitNormal[:], rayPath[:], &rayCount, len(rayPath))
+	if !IsEquals(hitT, 0.25) {
+		t.Fatalf("raycast t = %f, want 0.25", hitT)
+	}
+
+	// Jumping across.
+	filter = detour.DtAllocDtQueryFilter()
+	path = findPolyPath(t, mesh, filter, start, end)
+	if len(path) != 3 {
+		t.Fatalf("jumping path has %d polys, want 3", len(path))
+	}
+	con := mesh.GetOffMeshConnectionByRef(path[1])
+	if con == nil || con.UserId != 42 {
+		t.Fatalf("middle of the path is not the off-mesh connection")
+	}
+}
+
+func Test_ImportOBJ(t *testing.T) {
+	mesh := LoadJSONMesh("ushape.json")
+	var obj bytes.Buffer
+	if err := navexport.WriteOBJ(&obj, mesh, ""); err != nil {
+		t.Fatal(err)
+	}
+	desc, err := navimport.ParseOBJ(&obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The exporter writes the detail triangles.
+	if len(desc.Polys) != USHAPE_POLYS*2 || desc.Polys[2].Area != 2 {
+		t.Fatalf("read %d polys", len(desc.Polys))
+	}
+	mesh2, err := navimport.BuildNavMesh(nil, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := findPolyPath(t, mesh2, detour.DtAllocDtQueryFilter(), [3]float32{5, 0, 25}, [3]float32{25, 0, 25})
+	if len(path) < 7 {
+		t.Fatalf("path has %d polys", len(path))
+	}
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navimport"
+	"github.com/fananchong/recastnavigation-go/navlint"
+)
+
+func lintChecks(mesh *detour.DtNavMesh) map[navlint.Check]int {
+	checks := make(map[navlint.Check]int)
+	for _, issue := range navlint.Lint(mesh, nil).Issues {
+		checks[issue.Check]++
+	}
+	return checks
+}
+
+func Test_NavLint(t *testing.T) {
+	if report := navlint.Lint(LoadJSONMesh("ushape.json"), nil); !report.OK() || len(report.Issues) != 0 || report.Polys != 8 {
+		t.Fatalf("ushape: %+v", report)
+	}
+
+	// Moving the inner corner of the bottom left polygon into it makes it
+	// non-convex.
+	mesh := LoadJSONMesh("ushape.json")
+	tile := mesh.GetTile(0)
+	v := tile.Verts[tile.Polys[0].Verts[2]*3:]
+	v[0], v[2] = 3, 3
+	if checks := lintChecks(mesh); checks[navlint.CHECK_NONCONVEX_POLY] == 0 {
+		t.Fatalf("non-convex polygon not found: %v", checks)
+	}
+
+	// An internal edge turned into a border still has its link.
+	mesh = LoadJSONMesh("ushape.json")
+	tile = mesh.GetTile(0)
+	for j := range tile.Polys[0].Neis {
+		if n := tile.Polys[0].Neis[j]; n != 0 && n&detour.DT_EXT_LINK == 0 {
+			tile.Polys[0].Neis[j] = 0
+			break
+		}
+	}
+	if checks := lintChecks(mesh); checks[navlint.CHECK_NEIGHBOURS] != 2 {
+		t.Fatalf("wrong neighbours not found: %v", checks)
+	}
+
+	// A connection landing in the hole of the U.
+	f, err := os.Open("ushape.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := navimport.ParseJSON(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc.OffMeshConnections[0].End = [3]float32{15, 0, 15}
+	mesh, err = navimport.BuildNavMesh(nil, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := navlint.Lint(mesh, nil)
+	if report.OK() || len(report.Issues) != 1 || report.Issues[0].Check != navlint.CHECK_OFFMESH_ENDPOINT ||
+		*report.Issues[0].Pos != desc.OffMeshConnections[0].End {
+		t.Fatalf("unreachable end point not found: %+v", report.Issues)
+	}
+}
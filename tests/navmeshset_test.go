@@ -0,0 +1,337 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/fastlz"
+	"github.com/fananchong/recastnavigation-go/navmeshset"
+)
+
+// countingCompressor is a custom compressor, counting its calls.
+type countingCompressor struct {
+	fastlz.Compressor
+	compress, decompress int
+}
+
+func (this *countingCompressor) Compress(buffer []byte, bufferSize int32, compressed []byte, maxCompressedSize int32, compressedSize *int32) detour.DtStatus {
+	this.compress++
+	return this.Compressor.Compress(buffer, bufferSize, compressed, maxCompressedSize, compressedSize)
+}
+
+func (this *countingCompressor) Decompress(compressed []byte, compressedSize int32, buffer []byte, maxBufferSize int32, bufferSize *int32) detour.DtStatus {
+	this.decompress++
+	return this.Compressor.Decompress(compressed, compressedSize, buffer, maxBufferSize, bufferSize)
+}
+
+// sceneTiles returns the navmesh parameters of scene1 and copies of its
+// first tiles, with their references.
+func sceneTiles(n int) (*detour.DtNavMeshParams, []navmeshset.MeshTile) {
+	mesh, _ := LoadDynamicMesh("scene1.obj.tilecache.bin")
+	var tiles []navmeshset.MeshTile
+	for i := 0; i < int(mesh.GetMaxTiles()) && len(tiles) < n; i++ {
+		tile := mesh.GetTile(i)
+		if tile.Header == nil {
+			continue
+		}
+		data := make([]byte, len(tile.Data))
+		copy(data, tile.Data)
+		tiles = append(tiles, navmeshset.MeshTile{Ref: mesh.GetTileRef(tile), Data: data})
+	}
+	return mesh.GetParams(), tiles
+}
+
+func writeSet(t *testing.T, params *detour.DtNavMeshParams, tiles []navmeshset.MeshTile,
+	compression navmeshset.Compression, comp *countingCompressor) []byte {
+	var buf bytes.Buffer
+	header := &navmeshset.Header{Kind: navmeshset.KIND_NAVMESH, Compression: compression, MeshParams: *params}
+	var w *navmeshset.Writer
+	var err error
+	if comp != nil {
+		w, err = navmeshset.NewWriter(&buf, header, comp)
+	} else {
+		w, err = navmeshset.NewWriter(&buf, header, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tile := range tiles {
+		if err := w.WriteTile(tile.Ref, tile.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// checkSet checks that a container holds the tiles, with their references.
+func checkSet(t *testing.T, r *navmeshset.Reader, tiles []navmeshset.MeshTile) {
+	if len(r.Tiles()) != len(tiles) {
+		t.Fatalf("%d tiles, want %d", len(r.Tiles()), len(tiles))
+	}
+	for _, tile := range tiles {
+		x, y, layer, err := navmeshset.TileLocation(r.Header().Kind, tile.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, ok := r.FindTile(x, y, layer)
+		if !ok || info.Ref != uint64(tile.Ref) {
+			t.Fatalf("FindTile(%d, %d, %d): %+v, want ref 0x%x", x, y, layer, info, tile.Ref)
+		}
+		data, err := r.ReadTileAt(x, y, layer)
+		if err != nil || !bytes.Equal(data, tile.Data) {
+			t.Fatalf("ReadTileAt(%d, %d, %d): %v", x, y, layer, err)
+		}
+	}
+}
+
+func Test_NavMeshSetRoundTrip(t *testing.T) {
+	params, tiles := sceneTiles(64)
+
+	for _, c := range []struct {
+		name        string
+		compression navmeshset.Compression
+		comp        *countingCompressor
+	}{
+		{"none", navmeshset.COMPRESSION_NONE, nil},
+		{"fastlz", navmeshset.COMPRESSION_FASTLZ, nil},
+		{"custom", navmeshset.COMPRESSION_CUSTOM, &countingCompressor{}},
+	} {
+		data := writeSet(t, params, tiles, c.compression, c.comp)
+		var r *navmeshset.Reader
+		var err error
+		if c.comp != nil {
+			if _, err := navmeshset.NewReader(bytes.NewReader(data), int64(len(data)), nil); err != navmeshset.ErrNoCompressor {
+				t.Fatalf("%s: NewReader without a compressor: %v", c.name, err)
+			}
+			r, err = navmeshset.NewReader(bytes.NewReader(data), int64(len(data)), c.comp)
+		} else {
+			r, err = navmeshset.NewReader(bytes.NewReader(data), int64(len(data)), nil)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if h := r.Header(); h.Kind != navmeshset.KIND_NAVMESH || h.Compression != c.compression || h.MeshParams != *params {
+			t.Fatalf("%s: header %+v", c.name, h)
+		}
+		checkSet(t, r, tiles)
+
+		compressed := 0
+		for _, info := range r.Tiles() {
+			if info.Flags&navmeshset.TILE_COMPRESSED != 0 {
+				compressed++
+			}
+		}
+		if (c.compression == navmeshset.COMPRESSION_NONE) != (compressed == 0) {
+			t.Fatalf("%s: %d tiles compressed", c.name, compressed)
+		}
+		if c.comp != nil && (c.comp.compress != len(tiles) || c.comp.decompress != compressed) {
+			t.Fatalf("%s: %d compressions, %d decompressions", c.name, c.comp.compress, c.comp.decompress)
+		}
+		if _, err := r.ReadTileAt(-1, -1, 0); err != navmeshset.ErrTileNotFound {
+			t.Fatalf("%s: ReadTileAt outside of the set: %v", c.name, err)
+		}
+
+		mesh, err := r.LoadNavMesh()
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		// The links are stored in the tile data once loaded, so compare the
+		// headers.
+		for _, tile := range tiles {
+			x, y, layer, _ := navmeshset.TileLocation(navmeshset.KIND_NAVMESH, tile.Data)
+			if loaded := mesh.GetTileByRef(tile.Ref); loaded == nil || loaded.Header.X != x || loaded.Header.Y != y || loaded.Header.Layer != layer {
+				t.Fatalf("%s: tile 0x%x not loaded with its reference", c.name, tile.Ref)
+			}
+		}
+	}
+}
+
+func Test_NavMeshSetChecksum(t *testing.T) {
+	params, tiles := sceneTiles(4)
+	data := writeSet(t, params, tiles, navmeshset.COMPRESSION_NONE, nil)
+	r, err := navmeshset.NewReader(bytes.NewReader(data), int64(len(data)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := r.Tiles()[1]
+
+	// A corrupted tile fails to read, the others do not.
+	corrupt := append([]byte(nil), data...)
+	corrupt[info.Offset+uint64(info.Size/2)] ^= 0x55
+	r, err = navmeshset.NewReader(bytes.NewReader(corrupt), int64(len(corrupt)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadTile(info); err != navmeshset.ErrChecksum {
+		t.Fatalf("ReadTile of a corrupted tile: %v", err)
+	}
+	if _, err := r.ReadTile(r.Tiles()[0]); err != nil {
+		t.Fatalf("ReadTile: %v", err)
+	}
+	if _, err := r.LoadNavMesh(); err != navmeshset.ErrChecksum {
+		t.Fatalf("LoadNavMesh with a corrupted tile: %v", err)
+	}
+
+	// A corrupted index fails to open.
+	corrupt = append([]byte(nil), data...)
+	corrupt[len(corrupt)-20-1] ^= 0x55
+	if _, err := navmeshset.NewReader(bytes.NewReader(corrupt), int64(len(corrupt)), nil); err != navmeshset.ErrChecksum {
+		t.Fatalf("NewReader with a corrupted index: %v", err)
+	}
+}
+
+// writeMeshSet writes a legacy navmesh set, as saved by RecastDemo.
+func writeMeshSet(params *detour.DtNavMeshParams, tiles []navmeshset.MeshTile) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, []int32{navmeshset.NAVMESHSET_MAGIC, navmeshset.NAVMESHSET_VERSION, int32(len(tiles))})
+	binary.Write(&buf, binary.LittleEndian, params)
+	binary.Write(&buf, binary.LittleEndian, make([]float32, 6))
+	for _, tile := range tiles {
+		if detour.DT_REF_BITS == 64 {
+			binary.Write(&buf, binary.LittleEndian, uint64(tile.Ref))
+			binary.Write(&buf, binary.LittleEndian, []int32{int32(len(tile.Data)), 0})
+		} else {
+			binary.Write(&buf, binary.LittleEndian, uint32(tile.Ref))
+			binary.Write(&buf, binary.LittleEndian, int32(len(tile.Data)))
+		}
+		buf.Write(tile.Data)
+	}
+	return buf.Bytes()
+}
+
+func Test_ConvertMeshSet(t *testing.T) {
+	params, tiles := sceneTiles(64)
+	var buf bytes.Buffer
+	if err := navmeshset.ConvertMeshSet(writeMeshSet(params, tiles), &buf, navmeshset.COMPRESSION_FASTLZ, nil); err != nil {
+		t.Fatal(err)
+	}
+	r, err := navmeshset.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header().MeshParams != *params {
+		t.Fatalf("mesh params %+v, want %+v", r.Header().MeshParams, *params)
+	}
+	checkSet(t, r, tiles)
+}
+
+func Test_ConvertTileCacheSet(t *testing.T) {
+	if detour.DT_REF_BITS != 32 {
+		t.Skip("scene1 was saved with 32-bit references")
+	}
+	data, err := ioutil.ReadFile("scene1.obj.tilecache.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := navmeshset.ParseTileCacheSet(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := navmeshset.ConvertTileCacheSet(data, &buf, navmeshset.COMPRESSION_NONE, nil); err != nil {
+		t.Fatal(err)
+	}
+	r, err := navmeshset.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := r.Header(); h.Kind != navmeshset.KIND_TILECACHE || h.MeshParams != set.MeshParams || h.CacheParams != set.CacheParams {
+		t.Fatalf("header %+v", h)
+	}
+	checkSet(t, r, set.Tiles)
+	if _, err := r.LoadNavMesh(); err != navmeshset.ErrWrongKind {
+		t.Fatalf("LoadNavMesh of a tile cache set: %v", err)
+	}
+}
+
+// countLinks counts the links of the polygons of a tile.
+func countLinks(tile *detour.DtMeshTile) int {
+	n := 0
+	for i := 0; i < int(tile.Header.PolyCount); i++ {
+		for j := tile.Polys[i].FirstLink; j != detour.DT_NULL_LINK; j = tile.Links[j].Next {
+			n++
+		}
+	}
+	return n
+}
+
+func Test_SharedMesh(t *testing.T) {
+	params, tiles := sceneTiles(64)
+	data := writeMeshSet(params, tiles)
+	original := append([]byte(nil), data...)
+
+	path := t.TempDir() + "/scene1.navmesh"
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, open := range []struct {
+		name string
+		fn   func() (*navmeshset.SharedMesh, error)
+	}{
+		{"memory", func() (*navmeshset.SharedMesh, error) { return navmeshset.NewShared(data) }},
+		{"mapped", func() (*navmeshset.SharedMesh, error) { return navmeshset.OpenShared(path) }},
+	} {
+		shared, err := open.fn()
+		if err != nil {
+			t.Fatalf("%s: %v", open.name, err)
+		}
+		a, err := shared.NewNavMesh()
+		if err != nil {
+			t.Fatalf("%s: %v", open.name, err)
+		}
+		b, err := shared.NewNavMesh()
+		if err != nil {
+			t.Fatalf("%s: %v", open.name, err)
+		}
+		links := make([]int, len(tiles))
+		for i, tile := range tiles {
+			links[i] = countLinks(b.GetTileByRef(tile.Ref))
+			if n := countLinks(a.GetTileByRef(tile.Ref)); n != links[i] {
+				t.Fatalf("%s: tile 0x%x has %d and %d links", open.name, tile.Ref, n, links[i])
+			}
+		}
+
+		// Flags, areas and links changed on one instance do not show on the
+		// other.
+		ref := a.GetPolyRefBase(a.GetTileByRef(tiles[0].Ref))
+		var flags uint16
+		var area uint8
+		b.GetPolyFlags(ref, &flags)
+		b.GetPolyArea(ref, &area)
+		a.SetPolyFlags(ref, flags^0x8000)
+		a.SetPolyArea(ref, area^1)
+		for _, tile := range tiles[1:] {
+			a.RemoveTile(tile.Ref, nil, nil)
+		}
+		var bFlags uint16
+		var bArea uint8
+		b.GetPolyFlags(ref, &bFlags)
+		b.GetPolyArea(ref, &bArea)
+		if bFlags != flags || bArea != area {
+			t.Fatalf("%s: flags 0x%x area %d, want 0x%x %d", open.name, bFlags, bArea, flags, area)
+		}
+		if n := countLinks(a.GetTileByRef(tiles[0].Ref)); n >= links[0] {
+			t.Fatalf("%s: %d links left of %d after removing the neighbours", open.name, n, links[0])
+		}
+		for i, tile := range tiles {
+			if n := countLinks(b.GetTileByRef(tile.Ref)); n != links[i] {
+				t.Fatalf("%s: tile 0x%x has %d links, want %d", open.name, tile.Ref, n, links[i])
+			}
+		}
+		if err := shared.Close(); err != nil {
+			t.Fatalf("%s: %v", open.name, err)
+		}
+	}
+	if !bytes.Equal(data, original) {
+		t.Fatal("shared tile data written to")
+	}
+	if mapped, _ := ioutil.ReadFile(path); !bytes.Equal(mapped, original) {
+		t.Fatal("mapped tile data written to")
+	}
+}
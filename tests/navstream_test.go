@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navstream"
+)
+
+type streamListener struct {
+	loaded, unloaded []detour.DtTileRef
+}
+
+func (this *streamListener) TilesLoaded(x, y int32, refs []detour.DtTileRef) {
+	this.loaded = append(this.loaded, refs...)
+}
+
+func (this *streamListener) TilesUnloaded(x, y int32, refs []detour.DtTileRef) {
+	this.unloaded = append(this.unloaded, refs...)
+}
+
+func Test_Stream(t *testing.T) {
+	// Stream the tiles of the scene into an empty navmesh.
+	full, _ := LoadDynamicMesh("scene1.obj.tilecache.bin")
+	type location struct{ x, y int32 }
+	tiles := make(map[location][]navstream.Tile)
+	for i := 0; i < int(full.GetMaxTiles()); i++ {
+		tile := full.GetTile(i)
+		if tile.Header == nil {
+			continue
+		}
+		data := make([]byte, len(tile.Data))
+		copy(data, tile.Data)
+		loc := location{tile.Header.X, tile.Header.Y}
+		tiles[loc] = append(tiles[loc], navstream.Tile{Data: data})
+	}
+	source := navstream.SourceFunc(func(x, y int32) ([]navstream.Tile, error) {
+		return tiles[location{x, y}], nil
+	})
+	mesh := detour.DtAllocNavMesh()
+	if status := mesh.Init(full.GetParams()); detour.DtStatusFailed(status) {
+		t.Fatalf("Init: 0x%x", status)
+	}
+	width := full.GetParams().TileWidth
+	manager, err := navstream.NewManager(mesh, source, &navstream.Options{LoadRadius: 2 * width})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := &streamListener{}
+	manager.AddListener(listener)
+
+	// Points on the first polygons of the leftmost and rightmost tiles.
+	var first, last *detour.DtMeshTile
+	for i := 0; i < int(full.GetMaxTiles()); i++ {
+		tile := full.GetTile(i)
+		if tile.Header == nil || tile.Header.PolyCount == 0 {
+			continue
+		}
+		if first == nil || tile.Header.X < first.Header.X {
+			first = tile
+		}
+		if last == nil || tile.Header.X > last.Header.X {
+			last = tile
+		}
+	}
+	a, b := polyCenter(first), polyCenter(last)
+
+	manager.SetInterest(1, a)
+	result, err := manager.Update()
+	if err != nil || result.Loaded == 0 || result.Unloaded != 0 {
+		t.Fatalf("first update: %+v, %v", result, err)
+	}
+	x, y := manager.CellAt(a)
+	if !manager.IsResident(x, y) || manager.ResidentTiles() != len(listener.loaded) {
+		t.Fatalf("%d tiles resident, %d loaded", manager.ResidentTiles(), len(listener.loaded))
+	}
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	filter := detour.DtAllocDtQueryFilter()
+	var ref detour.DtPolyRef
+	var nearest [3]float32
+	query.FindNearestPoly(a[:], []float32{1, 2, 1}, filter, &ref, nearest[:])
+	if ref == 0 {
+		t.Fatal("no polygon at the interest point")
+	}
+
+	// Moving the interest point away unloads the tile, and the listener is
+	// told the corridor through it is gone.
+	manager.SetInterest(1, b)
+	if result, err = manager.Update(); err != nil || result.Unloaded == 0 {
+		t.Fatalf("second update: %+v, %v", result, err)
+	}
+	if manager.IsResident(x, y) || mesh.IsValidPolyRef(ref) {
+		t.Fatal("tile still resident")
+	}
+	if !navstream.CrossesTiles(mesh, []detour.DtPolyRef{ref}, listener.unloaded) {
+		t.Fatal("unloaded tiles do not include the polygon")
+	}
+
+	// Coming back reloads the tile with the same reference.
+	manager.SetInterest(2, a)
+	if result, err = manager.Update(); err != nil || result.Loaded == 0 || result.Unloaded != 0 {
+		t.Fatalf("third update: %+v, %v", result, err)
+	}
+	if !mesh.IsValidPolyRef(ref) {
+		t.Fatal("polygon reference changed across a reload")
+	}
+
+	manager.RemoveInterest(1)
+	manager.RemoveInterest(2)
+	if result, err = manager.Update(); err != nil || manager.ResidentTiles() != 0 {
+		t.Fatalf("last update: %+v, %v, %d tiles resident", result, err, manager.ResidentTiles())
+	}
+}
+
+func polyCenter(tile *detour.DtMeshTile) [3]float32 {
+	var center [3]float32
+	poly := &tile.Polys[0]
+	for i := 0; i < int(poly.VertCount); i++ {
+		v := tile.Verts[int(poly.Verts[i])*3:]
+		center[0] += v[0] / float32(poly.VertCount)
+		center[1] += v[1] / float32(poly.VertCount)
+		center[2] += v[2] / float32(poly.VertCount)
+	}
+	return center
+}
@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navimport"
+)
+
+// loadRuntimeUShape loads ushape.json without its off-mesh connection into
+// a mesh with room for maxTiles tiles, the connections being added at
+// runtime.
+func loadRuntimeUShape(t *testing.T, maxTiles uint32) (*detour.DtNavMesh, []byte, detour.DtTileRef) {
+	f, err := os.Open("ushape.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := navimport.ParseJSON(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc.OffMeshConnections = nil
+	data, err := desc.CreateNavMeshData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mesh := detour.DtAllocNavMesh()
+	params := detour.DtNavMeshParams{TileWidth: 30, TileHeight: 30, MaxTiles: maxTiles, MaxPolys: 16}
+	if status := mesh.Init(&params); detour.DtStatusFailed(status) {
+		t.Fatalf("Init: 0x%x", status)
+	}
+	var tileRef detour.DtTileRef
+	if status := mesh.AddTile(data, len(data), 0, 0, &tileRef); detour.DtStatusFailed(status) {
+		t.Fatalf("AddTile: 0x%x", status)
+	}
+	return mesh, data, tileRef
+}
+
+func Test_AddOffMeshConnection(t *testing.T) {
+	// Room for the tile and one connection.
+	mesh, data, tileRef := loadRuntimeUShape(t, 2)
+
+	filter := detour.DtAllocDtQueryFilter()
+	left := [3]float32{5, 0, 25}
+	right := [3]float32{25, 0, 25}
+	checkPaths := func(there, back int) {
+		if path := findPolyPath(t, mesh, filter, left, right); len(path) != there {
+			t.Fatalf("path there: %d polygons, want %d", len(path), there)
+		}
+		if path := findPolyPath(t, mesh, filter, right, left); len(path) != back {
+			t.Fatalf("path back: %d polygons, want %d", len(path), back)
+		}
+	}
+	checkPaths(7, 7)
+
+	ref, status := mesh.AddOffMeshConnection([]float32{8, 0, 25}, []float32{22, 0, 25}, 1, false,
+		USHAPE_AREA_JUMP, USHAPE_FLAG_JUMP, 42)
+	if detour.DtStatusFailed(status) {
+		t.Fatalf("AddOffMeshConnection: 0x%x", status)
+	}
+	if con := mesh.GetOffMeshConnectionByRef(ref); con == nil || con.UserId != 42 {
+		t.Fatalf("GetOffMeshConnectionByRef: %+v", con)
+	}
+	checkPaths(3, 7)
+	if _, status := mesh.AddOffMeshConnection(left[:], right[:], 1, true, 0, 1, 0); status != detour.DT_FAILURE|detour.DT_OUT_OF_MEMORY {
+		t.Fatalf("AddOffMeshConnection without a free tile: 0x%x", status)
+	}
+
+	// The connection is linked again when the tile comes back.
+	if status := mesh.RemoveTile(tileRef, nil, nil); detour.DtStatusFailed(status) {
+		t.Fatalf("RemoveTile: 0x%x", status)
+	}
+	if !mesh.IsValidPolyRef(ref) {
+		t.Fatal("connection removed with the tile")
+	}
+	if status := mesh.AddTile(data, len(data), 0, tileRef, nil); detour.DtStatusFailed(status) {
+		t.Fatalf("AddTile: 0x%x", status)
+	}
+	checkPaths(3, 7)
+
+	if status := mesh.RemoveOffMeshConnection(ref); detour.DtStatusFailed(status) {
+		t.Fatalf("RemoveOffMeshConnection: 0x%x", status)
+	}
+	if mesh.IsValidPolyRef(ref) {
+		t.Fatal("connection still valid")
+	}
+	if status := mesh.RemoveOffMeshConnection(ref); !detour.DtStatusFailed(status) {
+		t.Fatal("RemoveOffMeshConnection succeeded twice")
+	}
+	checkPaths(7, 7)
+
+	// Bidirectional connections are used both ways.
+	if _, status := mesh.AddOffMeshConnection([]float32{8, 0, 25}, []float32{22, 0, 25}, 1, true,
+		USHAPE_AREA_JUMP, USHAPE_FLAG_JUMP, 43); detour.DtStatusFailed(status) {
+		t.Fatalf("AddOffMeshConnection: 0x%x", status)
+	}
+	checkPaths(3, 3)
+}
+
+func Test_OffMeshConnectionReverseSearch(t *testing.T) {
+	mesh, _, _ := loadRuntimeUShape(t, 2)
+	if _, status := mesh.AddOffMeshConnection([]float32{8, 0, 25}, []float32{22, 0, 25}, 1, false,
+		USHAPE_AREA_JUMP, USHAPE_FLAG_JUMP, 42); detour.DtStatusFailed(status) {
+		t.Fatalf("AddOffMeshConnection: 0x%x", status)
+	}
+
+	filter := detour.DtAllocDtQueryFilter()
+	left := [3]float32{5, 0, 25}
+	right := [3]float32{25, 0, 25}
+	want := findPolyPath(t, mesh, filter, left, right)
+	if got := findPolyPathBidirectional(t, mesh, filter, left, right); len(got) != 3 || !equalPaths(got, want) {
+		t.Fatalf("FindPathBidirectional: got %v, FindPath %v", got, want)
+	}
+
+	// The cost of the jump to the right arm is the same from the left arm,
+	// and to the right arm.
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	var leftRef, rightRef detour.DtPolyRef
+	var leftPos, rightPos [3]float32
+	query.FindNearestPoly(left[:], ushapeHalfExtents[:], filter, &leftRef, leftPos[:])
+	query.FindNearestPoly(right[:], ushapeHalfExtents[:], filter, &rightRef, rightPos[:])
+	from := detour.DtAllocDistanceField()
+	if status := query.BuildDistanceField(leftRef, leftPos[:], math.MaxFloat32, filter,
+		detour.DT_DISTANCEFIELD_FROM_SOURCE, from); detour.DtStatusFailed(status) {
+		t.Fatalf("BuildDistanceField: 0x%x", status)
+	}
+	to := detour.DtAllocDistanceField()
+	if status := query.BuildDistanceField(rightRef, rightPos[:], math.MaxFloat32, filter,
+		detour.DT_DISTANCEFIELD_TO_SOURCE, to); detour.DtStatusFailed(status) {
+		t.Fatalf("BuildDistanceField: 0x%x", status)
+	}
+	var there, back float32
+	from.GetCostAt(rightRef, rightPos[:], filter, &there)
+	to.GetCostAt(leftRef, leftPos[:], filter, &back)
+	if math.Abs(float64(there-back)) > 0.01 {
+		t.Fatalf("cost to the source %v, from the source %v", back, there)
+	}
+}
+
+func Test_OffMeshConnectionKeepsRemovedTile(t *testing.T) {
+	mesh, data, tileRef := loadRuntimeUShape(t, 2)
+
+	// The connection must not take the slot of the tile just removed.
+	if status := mesh.RemoveTile(tileRef, nil, nil); detour.DtStatusFailed(status) {
+		t.Fatalf("RemoveTile: 0x%x", status)
+	}
+	if _, status := mesh.AddOffMeshConnection([]float32{8, 0, 25}, []float32{22, 0, 25}, 1, false,
+		USHAPE_AREA_JUMP, USHAPE_FLAG_JUMP, 42); detour.DtStatusFailed(status) {
+		t.Fatalf("AddOffMeshConnection: 0x%x", status)
+	}
+	var ref detour.DtTileRef
+	if status := mesh.AddTile(data, len(data), 0, tileRef, &ref); detour.DtStatusFailed(status) {
+		t.Fatalf("AddTile with the last reference: 0x%x", status)
+	}
+	if ref != tileRef {
+		t.Fatalf("tile reference %d, want %d", ref, tileRef)
+	}
+	filter := detour.DtAllocDtQueryFilter()
+	if path := findPolyPath(t, mesh, filter, [3]float32{5, 0, 25}, [3]float32{25, 0, 25}); len(path) != 3 {
+		t.Fatalf("path: %d polygons, want 3", len(path))
+	}
+}
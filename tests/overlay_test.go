@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+)
+
+func Test_CostOverlay(t *testing.T) {
+	mesh := LoadJSONMesh("ushape.json")
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	tile := mesh.GetTileAt(0, 0, 0)
+	base := mesh.GetPolyRefBase(tile)
+	filter := detour.DtAllocDtQueryFilter()
+
+	start := [3]float32{5, 0, 25}
+	end := [3]float32{25, 0, 25}
+	startRef, endRef := base|5, base|6
+	jump := base | USHAPE_POLYS
+	findPath := func(filter detour.DtQueryFilterI) (detour.DtStatus, []detour.DtPolyRef) {
+		var path [16]detour.DtPolyRef
+		var pathCount int
+		stat := query.FindPath(startRef, endRef, start[:], end[:], filter, path[:], &pathCount, len(path))
+		return stat, path[:pathCount]
+	}
+
+	// One team sees the jump as dangerous and walks around, the other
+	// cannot cross the bottom and takes the jump.
+	walkers := detour.DtAllocCostOverlay()
+	walkers.SetPolyCost(jump, 1000)
+	jumpers := detour.DtAllocCostOverlay()
+	jumpers.SetPolyBlocked(base|1, true)
+
+	if stat, path := findPath(detour.DtAllocOverlayFilter(filter, walkers)); !detour.DtStatusSucceed(stat) || len(path) != 7 {
+		t.Fatalf("walkers: status 0x%x, %v", stat, path)
+	}
+	if stat, path := findPath(detour.DtAllocOverlayFilter(filter, jumpers)); !detour.DtStatusSucceed(stat) || len(path) != 3 || path[1] != jump {
+		t.Fatalf("jumpers: status 0x%x, %v", stat, path)
+	}
+	filter.SetExcludeFlags(USHAPE_FLAG_JUMP)
+	if stat, path := findPath(detour.DtAllocOverlayFilter(filter, jumpers)); !detour.DtStatusDetail(stat, detour.DT_PARTIAL_RESULT) {
+		t.Fatalf("jumpers without the jump: status 0x%x, %v", stat, path)
+	}
+
+	// The mesh itself is untouched.
+	if stat, path := findPath(filter); !detour.DtStatusSucceed(stat) || len(path) != 7 {
+		t.Fatalf("without overlay: status 0x%x, %v", stat, path)
+	}
+
+	// Resetting an entry to its defaults drops it, and removed tiles are pruned.
+	jumpers.SetPolyBlocked(base|1, false)
+	if jumpers.GetPolyCount() != 0 {
+		t.Fatalf("%d entries after unblocking", jumpers.GetPolyCount())
+	}
+	if status := mesh.RemoveTile(mesh.GetTileRef(tile), nil, nil); detour.DtStatusFailed(status) {
+		t.Fatalf("RemoveTile: 0x%x", status)
+	}
+	if n := walkers.Prune(mesh); n != 1 || walkers.GetPolyCount() != 0 {
+		t.Fatalf("%d entries pruned, %d left", n, walkers.GetPolyCount())
+	}
+}
@@ -0,0 +1,88 @@
+// +build dtpolyref64
+
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navmeshset"
+)
+
+func Test_PolyRef64(t *testing.T) {
+	// Too many tile and polygon bits for 32-bit references.
+	mesh := detour.DtAllocNavMesh()
+	params := detour.DtNavMeshParams{TileWidth: 30, TileHeight: 30, MaxTiles: 1 << 16, MaxPolys: 1 << 16}
+	if status := mesh.Init(&params); detour.DtStatusFailed(status) {
+		t.Fatalf("Init: 0x%x", status)
+	}
+	salt, it, ip := uint32(1<<31-1), uint32(1<<16-1), uint32(1<<16-1)
+	ref := mesh.EncodePolyId(salt, it, ip)
+	var s, i, p uint32
+	mesh.DecodePolyId(ref, &s, &i, &p)
+	if s != salt || i != it || p != ip {
+		t.Fatalf("DecodePolyId(0x%x) = %d %d %d", ref, s, i, p)
+	}
+
+	// The queries work with 64-bit references.
+	mesh = LoadJSONMesh("ushape.json")
+	if path := findPolyPath(t, mesh, detour.DtAllocDtQueryFilter(), [3]float32{5, 0, 25}, [3]float32{25, 0, 25}); len(path) != 3 {
+		t.Fatalf("path: %d polygons", len(path))
+	}
+}
+
+func Test_PolyRef64Sets(t *testing.T) {
+	_, data, _ := loadRuntimeUShape(t, 1)
+	params := detour.DtNavMeshParams{TileWidth: 30, TileHeight: 30, MaxTiles: 4, MaxPolys: 16}
+	mesh := detour.DtAllocNavMesh()
+	mesh.Init(&params)
+	// A salt which does not fit in 32-bit references.
+	ref := detour.DtTileRef(mesh.EncodePolyId(1<<20, 2, 0))
+	if status := mesh.AddTile(data, len(data), 0, ref, nil); detour.DtStatusFailed(status) {
+		t.Fatalf("AddTile: 0x%x", status)
+	}
+
+	var buf bytes.Buffer
+	w, err := navmeshset.NewWriter(&buf, &navmeshset.Header{Kind: navmeshset.KIND_NAVMESH, MeshParams: params}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteTile(ref, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := navmeshset.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Tiles()[0].Ref; got != uint64(ref) {
+		t.Fatalf("index ref 0x%x, want 0x%x", got, ref)
+	}
+	loaded, err := r.LoadNavMesh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.GetTileRef(loaded.GetTileAt(0, 0, 0)); got != ref {
+		t.Fatalf("loaded tile ref 0x%x, want 0x%x", got, ref)
+	}
+
+	// Legacy sets written with 64-bit references have 16 byte tile headers.
+	var set bytes.Buffer
+	binary.Write(&set, binary.LittleEndian, []int32{navmeshset.NAVMESHSET_MAGIC, navmeshset.NAVMESHSET_VERSION, 1})
+	binary.Write(&set, binary.LittleEndian, &params)
+	binary.Write(&set, binary.LittleEndian, make([]float32, 6))
+	binary.Write(&set, binary.LittleEndian, uint64(ref))
+	binary.Write(&set, binary.LittleEndian, []int32{int32(len(data)), 0})
+	set.Write(data)
+	ms, err := navmeshset.ParseMeshSet(set.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms.Tiles) != 1 || ms.Tiles[0].Ref != ref || !bytes.Equal(ms.Tiles[0].Data, data) {
+		t.Fatalf("ParseMeshSet: %d tiles", len(ms.Tiles))
+	}
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navigation"
+)
+
+// Run with -race to check the pool hands out independent query state.
+func Test_QueryPool(t *testing.T) {
+	mesh := LoadJSONMesh("ushape.json")
+	filter := detour.DtAllocDtQueryFilter()
+	filter.SetExcludeFlags(USHAPE_FLAG_JUMP)
+	pool, err := navigation.NewQueryPool(mesh, &navigation.Options{Filter: filter})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Random points on the U shape.
+	randomPoint := func(r *rand.Rand) navigation.Vec3 {
+		cells := [][2]float32{{0, 0}, {10, 0}, {20, 0}, {0, 10}, {20, 10}, {0, 20}, {20, 20}}
+		c := cells[r.Intn(len(cells))]
+		return navigation.Vec3{c[0] + 0.5 + r.Float32()*9, 0, c[1] + 0.5 + r.Float32()*9}
+	}
+
+	const goroutines = 8
+	const queries = 200
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < queries; i++ {
+				start, end := randomPoint(r), randomPoint(r)
+				path, err := pool.Path(start, end)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if len(path) < 2 || path[len(path)-1] != end {
+					t.Errorf("path from %v to %v ends at %v", start, end, path[len(path)-1])
+					return
+				}
+				if _, err := pool.Raycast(start, end); err != nil {
+					errs <- err
+					return
+				}
+				// Concurrent reads of the navmesh itself.
+				ref, _, err := pool.Nearest(start)
+				if err != nil || !mesh.IsValidPolyRef(ref) {
+					t.Errorf("Nearest(%v) = %d, %v", start, ref, err)
+					return
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
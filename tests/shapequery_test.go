@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+)
+
+func Test_ShapeQueries(t *testing.T) {
+	mesh := LoadJSONMesh("ushape.json")
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	base := mesh.GetPolyRefBase(mesh.GetTileAt(0, 0, 0))
+	filter := detour.DtAllocDtQueryFilter()
+
+	check := func(name string, want []int, run func(polys []detour.DtPolyRef, polyCount *int) detour.DtStatus) {
+		var polys [16]detour.DtPolyRef
+		var polyCount int
+		if status := run(polys[:], &polyCount); status != detour.DT_SUCCESS {
+			t.Fatalf("%s: status 0x%x", name, status)
+		}
+		got := make([]int, polyCount)
+		for i, ref := range polys[:polyCount] {
+			got[i] = int(ref - base)
+		}
+		sort.Ints(got)
+		if len(got) != len(want) {
+			t.Fatalf("%s: polygons %v, want %v", name, got, want)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("%s: polygons %v, want %v", name, got, want)
+			}
+		}
+	}
+
+	// The segment crosses the gap between the arms, without a link.
+	check("segment", []int{3, 4}, func(polys []detour.DtPolyRef, polyCount *int) detour.DtStatus {
+		return query.QueryPolygonsSegment([]float32{2, 0, 15}, []float32{28, 0, 15}, 1, filter, polys, polyCount, len(polys))
+	})
+	check("segment above", nil, func(polys []detour.DtPolyRef, polyCount *int) detour.DtStatus {
+		return query.QueryPolygonsSegment([]float32{2, 5, 15}, []float32{28, 5, 15}, 1, filter, polys, polyCount, len(polys))
+	})
+
+	// A long thin box along z, then turned along x.
+	center := []float32{15, 0, 15}
+	halfExtents := []float32{2, 1, 16}
+	check("box", []int{1}, func(polys []detour.DtPolyRef, polyCount *int) detour.DtStatus {
+		return query.QueryPolygonsBox(center, halfExtents, 0, filter, polys, polyCount, len(polys))
+	})
+	check("turned box", []int{3, 4}, func(polys []detour.DtPolyRef, polyCount *int) detour.DtStatus {
+		return query.QueryPolygonsBox(center, halfExtents, math.Pi/2, filter, polys, polyCount, len(polys))
+	})
+
+	// The sphere reaches the edges of the polygons around the gap, but not
+	// the corner of polygon 0, which its bounds do.
+	check("sphere", []int{1, 3, 4}, func(polys []detour.DtPolyRef, polyCount *int) detour.DtStatus {
+		return query.QueryPolygonsSphere(center, 6, filter, polys, polyCount, len(polys))
+	})
+	check("sphere above", nil, func(polys []detour.DtPolyRef, polyCount *int) detour.DtStatus {
+		return query.QueryPolygonsSphere([]float32{15, 5, 15}, 6, filter, polys, polyCount, len(polys))
+	})
+
+	// The bounds of the triangle reach the top of the arms, the triangle does not.
+	triangle := []float32{5, 0, 5, 15, 0, 25, 25, 0, 5}
+	check("triangle", []int{0, 1, 2, 3, 4}, func(polys []detour.DtPolyRef, polyCount *int) detour.DtStatus {
+		return query.QueryPolygonsConvex(triangle, 3, 1, filter, polys, polyCount, len(polys))
+	})
+
+	var polys [2]detour.DtPolyRef
+	var polyCount int
+	status := query.QueryPolygonsConvex(triangle, 3, 1, filter, polys[:], &polyCount, len(polys))
+	if !detour.DtStatusDetail(status, detour.DT_BUFFER_TOO_SMALL) || polyCount != 2 {
+		t.Fatalf("small buffer: status 0x%x, %d polygons", status, polyCount)
+	}
+}
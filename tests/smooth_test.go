@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navigation"
+)
+
+func Test_SmoothPath(t *testing.T) {
+	nav := newUShapeNavigator(t, nil)
+	start, end := navigation.Vec3{5, 0, 25}, navigation.Vec3{25, 0, 25}
+
+	// The inner corners (10, 10) and (20, 10) are passed at the radius, each
+	// with a single corner where the tangents to the circle around it meet.
+	walls := []navigation.Vec3{{10, 0, 10}, {20, 0, 10}}
+	path, err := nav.SmoothPath(start, end, &navigation.SmoothOptions{Radius: 1})
+	if err != nil || len(path) != 4 || path[0] != start || path[3] != end ||
+		!IsEquals(path[1][2], 9) || !IsEquals(path[2][2], 9) || !IsEquals(path[1][0]+path[2][0], 30) {
+		t.Fatalf("SmoothPath: %v %v", path, err)
+	}
+	if d := clearance(path, walls); !IsEquals(d, 1) {
+		t.Fatalf("SmoothPath: %v at %v from the corners", path, d)
+	}
+
+	// Arcs of the radius follow the circles around the corners.
+	path, err = nav.SmoothPath(start, end, &navigation.SmoothOptions{Radius: 1, Corners: navigation.CORNERS_ARC})
+	if err != nil || len(path) != 2+2*(navigation.DEFAULT_CURVE_SEGMENTS+1) {
+		t.Fatalf("SmoothPath(arcs): %v %v", path, err)
+	}
+	if d := clearance(path, walls); d < 1-1e-4 {
+		t.Fatalf("SmoothPath(arcs): %v at %v from the corners", path, d)
+	}
+
+	for _, corners := range []navigation.Corners{navigation.CORNERS_ARC, navigation.CORNERS_CATMULL_ROM} {
+		path, err := nav.SmoothPath(start, end, &navigation.SmoothOptions{Radius: 1, Corners: corners, ArcRadius: 3})
+		if err != nil || len(path) <= 4 || path[0] != start || path[len(path)-1] != end {
+			t.Fatalf("SmoothPath(%d): %v %v", corners, path, err)
+		}
+		if n, err := nav.ValidatePath(path); err != nil || n != len(path)-1 {
+			t.Fatalf("ValidatePath(%d): %d %v", corners, n, err)
+		}
+	}
+
+	// Straight through the hole.
+	n, err := nav.ValidatePath([]navigation.Vec3{{5, 0, 5}, start, end})
+	var blocked *navigation.BlockedError
+	if !errors.Is(err, navigation.ErrBlocked) || !errors.As(err, &blocked) || n != 1 || blocked.Segment != 1 {
+		t.Fatalf("ValidatePath: %d %v", n, err)
+	}
+}
+
+// clearance returns the smallest distance on the xz-plane from the segments
+// of path to the points.
+func clearance(path []navigation.Vec3, points []navigation.Vec3) float32 {
+	d := float32(math.MaxFloat32)
+	for i := 0; i+1 < len(path); i++ {
+		for _, p := range points {
+			var t float32
+			if s := detour.DtDistancePtSegSqr2D(p[:], path[i][:], path[i+1][:], &t); s < d*d {
+				d = float32(math.Sqrt(float64(s)))
+			}
+		}
+	}
+	return d
+}
+
+func Test_ValidatePathScene(t *testing.T) {
+	mesh, _ := LoadDynamicMesh("scene1.obj.tilecache.bin")
+	nav, err := navigation.New(mesh, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	rnd := rand.New(rand.NewSource(1))
+	r := func() float32 { return rnd.Float32() }
+
+	// String pulled paths end their segments on the wall vertices they turn
+	// around, where the ray of the next segment starts.
+	paths := 0
+	for i := 0; i < 400; i++ {
+		var startRef, endRef detour.DtPolyRef
+		var start, end navigation.Vec3
+		FindRandomPoint(query, nav.Filter(), r, &startRef, start[:])
+		FindRandomPoint(query, nav.Filter(), r, &endRef, end[:])
+		path, err := nav.Path(start, end)
+		if err != nil {
+			continue
+		}
+		paths++
+		if n, err := nav.ValidatePath(path); err != nil || n != len(path)-1 {
+			t.Fatalf("pair %d: ValidatePath(%v): %d %v", i, path, n, err)
+		}
+	}
+	if paths == 0 {
+		t.Fatal("no path")
+	}
+}
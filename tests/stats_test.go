@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/DetourTileCache"
+	"github.com/fananchong/recastnavigation-go/navmetrics"
+)
+
+func Test_Stats(t *testing.T) {
+	mesh, tileCache := LoadDynamicMesh("scene1.obj.tilecache.bin")
+	stats := mesh.Stats()
+	if stats.Tiles == 0 || stats.Tiles != len(stats.TileStats) || stats.Polys == 0 || stats.DataBytes == 0 {
+		t.Fatalf("navmesh stats: %d tiles, %d polys, %d bytes", stats.Tiles, stats.Polys, stats.DataBytes)
+	}
+	for _, ts := range stats.TileStats {
+		header := mesh.GetTileByRef(ts.Ref).Header
+		if ts.LinksUsed+ts.LinksFree != int(header.MaxLinkCount) {
+			t.Fatalf("tile (%d, %d, %d): %d used and %d free links of %d",
+				ts.X, ts.Y, ts.Layer, ts.LinksUsed, ts.LinksFree, header.MaxLinkCount)
+		}
+	}
+
+	cacheStats := tileCache.Stats()
+	if cacheStats.Tiles == 0 || cacheStats.CompressedBytes == 0 || cacheStats.UncompressedBytes <= cacheStats.CompressedBytes {
+		t.Fatalf("tile cache stats %+v", cacheStats)
+	}
+	var ref dtcache.DtObstacleRef
+	tileCache.AddObstacle([]float32{-10, 0, -10}, 1, 2, &ref)
+	if n := tileCache.Stats().Obstacles; n != cacheStats.Obstacles+1 {
+		t.Fatalf("%d obstacles after adding one to %d", n, cacheStats.Obstacles)
+	}
+
+	// A search larger than the pool exhausts it and leaves the high-water
+	// mark at the capacity.
+	query := CreateQuery(mesh, 16)
+	filter := detour.DtAllocDtQueryFilter()
+	rnd := rand.New(rand.NewSource(1))
+	r := func() float32 { return rnd.Float32() }
+	var startRef, endRef detour.DtPolyRef
+	var startPos, endPos [3]float32
+	FindRandomPoint(query, filter, r, &startRef, startPos[:])
+	for i := 0; i < 100 && query.Stats().NodePool.Exhausted == 0; i++ {
+		FindRandomPoint(query, filter, r, &endRef, endPos[:])
+		path := make([]detour.DtPolyRef, 256)
+		var pathCount int
+		query.FindPath(startRef, endRef, startPos[:], endPos[:], filter, path, &pathCount, len(path))
+	}
+	queryStats := query.Stats()
+	if queryStats.NodePool.MaxNodes != 16 || queryStats.NodePool.HighWater != 16 || queryStats.NodePool.Exhausted == 0 {
+		t.Fatalf("node pool stats %+v", queryStats.NodePool)
+	}
+	query.ResetStats()
+	if n := query.Stats().NodePool.Exhausted; n != 0 {
+		t.Fatalf("%d exhausted after reset", n)
+	}
+
+	// Runtime connections live outside the tile data.
+	if _, status := mesh.AddOffMeshConnection(startPos[:], endPos[:], 1, true, 0, 1, 0); detour.DtStatusFailed(status) {
+		t.Fatalf("AddOffMeshConnection: 0x%x", status)
+	}
+	after := mesh.Stats()
+	if after.Tiles != stats.Tiles || len(after.TileStats) != stats.Tiles || after.OffMeshConTiles != stats.OffMeshConTiles+1 ||
+		after.OffMeshCons != stats.OffMeshCons+1 || after.PrivateBytes <= stats.PrivateBytes {
+		t.Fatalf("%d tiles, %d connection tiles, %d connections and %d private bytes after adding a connection",
+			after.Tiles, after.OffMeshConTiles, after.OffMeshCons, after.PrivateBytes)
+	}
+
+	reg := navmetrics.NewRegistry()
+	reg.AddNavMesh(`scene "1"`, mesh.Stats)
+	reg.AddTileCache("scene1", tileCache.Stats)
+	reg.AddQuery("scene1", query.Stats)
+	var buf bytes.Buffer
+	if _, err := reg.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE detour_navmesh_polys gauge",
+		`detour_navmesh_links{mesh="scene \"1\"",state="free"} `,
+		`detour_navmesh_offmesh_connection_tiles{mesh="scene \"1\""} 1`,
+		`detour_tilecache_obstacles{cache="scene1"} `,
+		`detour_query_node_pool_capacity{query="scene1",pool="main"} 16`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("%q missing from\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/DetourTileCache"
+	"github.com/fananchong/recastnavigation-go/navigation"
+)
+
+// Run with -race: obstacles are rebuilt on one goroutine while others query.
+func Test_SyncNavMesh(t *testing.T) {
+	mesh, tileCache := LoadDynamicMesh("scene1.obj.tilecache.bin")
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	filter := detour.DtAllocDtQueryFilter()
+	r := rand.New(rand.NewSource(1))
+	frand := func() float32 { return r.Float32() }
+
+	var points [][3]float32
+	var obstacleRef detour.DtPolyRef
+	for i := 0; i < 16; i++ {
+		var ref detour.DtPolyRef
+		var pt [3]float32
+		status := FindRandomPoint(query, filter, frand, &ref, pt[:])
+		if detour.DtStatusFailed(status) {
+			t.Fatalf("FindRandomPoint failed: 0x%x", status)
+		}
+		points = append(points, pt)
+		obstacleRef = ref
+	}
+	obstaclePos := points[len(points)-1]
+
+	syncMesh := navigation.NewSyncNavMesh(mesh)
+	pool, err := syncMesh.NewQueryPool(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syncMesh.CheckRefs(obstacleRef); err != nil {
+		t.Fatal(err)
+	}
+
+	var ob dtcache.DtObstacleRef
+	status := tileCache.AddObstacle(obstaclePos[:], 1, 2, &ob)
+	if detour.DtStatusFailed(status) {
+		t.Fatalf("AddObstacle failed: 0x%x", status)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				start := navigation.Vec3(points[r.Intn(len(points))])
+				end := navigation.Vec3(points[r.Intn(len(points))])
+				_, err := pool.Path(start, end)
+				if err != nil && !navigation.IsIncomplete(err) && !errors.Is(err, navigation.ErrNotFound) {
+					t.Error(err)
+					return
+				}
+			}
+		}(int64(g))
+	}
+
+	for upToDate := false; !upToDate; {
+		upToDate, err = syncMesh.UpdateTileCache(tileCache, 0)
+		if err != nil {
+			t.Error(err)
+			break
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// The tile under the obstacle was rebuilt, so its old refs are stale.
+	if err := syncMesh.CheckRefs(obstacleRef); !errors.Is(err, navigation.ErrStaleRef) {
+		t.Fatalf("CheckRefs after rebuild = %v, want ErrStaleRef", err)
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math"
+	"os"
 	"reflect"
 	"unsafe"
 
 	detour "github.com/fananchong/recastnavigation-go/Detour"
 	dtcache "github.com/fananchong/recastnavigation-go/DetourTileCache"
 	"github.com/fananchong/recastnavigation-go/fastlz"
+	"github.com/fananchong/recastnavigation-go/navimport"
 )
 
 func IsEquals(a, b float32) bool {
@@ -30,7 +32,7 @@ type NavMeshSetHeader struct {
 }
 
 type NavMeshTileHeader struct {
-	tileRef  detour.DtTileRef
+	tileRef  uint32
 	dataSize int32
 }
 
@@ -49,7 +51,7 @@ type TileCacheSetHeader struct {
 }
 
 type TileCacheTileHeader struct {
-	tileRef  dtcache.DtCompressedTileRef
+	tileRef  uint32
 	dataSize int32
 }
 
@@ -82,13 +84,25 @@ func LoadStaticMesh(path string) *detour.DtNavMesh {
 		d += int32(unsafe.Sizeof(*tileHeader))
 
 		data := meshData[d : d+tileHeader.dataSize]
-		state = navMesh.AddTile(data, int(tileHeader.dataSize), detour.DT_TILE_FREE_DATA, tileHeader.tileRef, nil)
+		state = navMesh.AddTile(data, int(tileHeader.dataSize), detour.DT_TILE_FREE_DATA, detour.DtTileRef(tileHeader.tileRef), nil)
 		detour.DtAssert(detour.DtStatusSucceed(state))
 		d += tileHeader.dataSize
 	}
 	return navMesh
 }
 
+func LoadJSONMesh(path string) *detour.DtNavMesh {
+	f, err := os.Open(path)
+	detour.DtAssert(err == nil)
+	defer f.Close()
+
+	desc, err := navimport.ParseJSON(f)
+	detour.DtAssert(err == nil)
+	navMesh, err := navimport.BuildNavMesh(nil, desc)
+	detour.DtAssert(err == nil)
+	return navMesh
+}
+
 func CreateQuery(mesh *detour.DtNavMesh, maxNode int) *detour.DtNavMeshQuery {
 	query := detour.DtAllocNavMeshQuery()
 	detour.DtAssert(query != nil)
@@ -97,24 +111,6 @@ func CreateQuery(mesh *detour.DtNavMesh, maxNode int) *detour.DtNavMeshQuery {
 	return query
 }
 
-type FastLZCompressor struct{}
-
-func (this *FastLZCompressor) MaxCompressedSize(bufferSize int32) int32 {
-	return int32(float64(bufferSize) * 1.05)
-}
-func (this *FastLZCompressor) Compress(buffer []byte, bufferSize int32, compressed []byte, maxCompressedSize int32, compressedSize *int32) detour.DtStatus {
-	*compressedSize = int32(fastlz.Fastlz_compress(buffer, int(bufferSize), compressed))
-	return detour.DT_SUCCESS
-}
-func (this *FastLZCompressor) Decompress(compressed []byte, compressedSize int32, buffer []byte, maxBufferSize int32, bufferSize *int32) detour.DtStatus {
-	*bufferSize = int32(fastlz.Fastlz_decompress(compressed, int(compressedSize), buffer, int(maxBufferSize)))
-	if *bufferSize < 0 {
-		return detour.DT_FAILURE
-	} else {
-		return detour.DT_SUCCESS
-	}
-}
-
 const (
 	POLYAREA_GROUND uint8 = 0
 	POLYAREA_WATER  uint8 = 1
@@ -168,7 +164,7 @@ func LoadDynamicMesh(path string) (*detour.DtNavMesh, *dtcache.DtTileCache) {
 	state := navMesh.Init(&header.meshParams)
 	detour.DtAssert(detour.DtStatusSucceed(state))
 	tileCache := dtcache.DtAllocTileCache()
-	state = tileCache.Init(&header.cacheParams, &FastLZCompressor{}, &MeshProcess{})
+	state = tileCache.Init(&header.cacheParams, &fastlz.Compressor{}, &MeshProcess{})
 	detour.DtAssert(detour.DtStatusSucceed(state))
 
 	for i := 0; i < int(header.numTiles); i++ {
@@ -199,7 +195,7 @@ func LoadDynamicMesh(path string) (*detour.DtNavMesh, *dtcache.DtTileCache) {
 	return navMesh, tileCache
 }
 
-func FindRandomPoint(query *detour.DtNavMeshQuery, filter *detour.DtQueryFilter, frand func() float32,
+func FindRandomPoint(query *detour.DtNavMeshQuery, filter detour.DtQueryFilterI, frand func() float32,
 	randomRef *detour.DtPolyRef, randomPt []float32) detour.DtStatus {
 	m_nav := query.GetAttachedNavMesh()
 	detour.DtAssert(m_nav != nil)
@@ -210,7 +206,7 @@ func FindRandomPoint(query *detour.DtNavMeshQuery, filter *detour.DtQueryFilter,
 	for i := tileIndex; true; i++ {
 		i = i % int(m_nav.GetMaxTiles())
 		tile = m_nav.GetTile(i)
-		if tile != nil && tile.Header != nil {
+		if tile != nil && tile.Header != nil && (tile.Flags&detour.DT_TILE_OFFMESH_CONNECTION) == 0 {
 			break
 		}
 	}
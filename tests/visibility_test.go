@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fananchong/recastnavigation-go/Detour"
+	"github.com/fananchong/recastnavigation-go/navigation"
+)
+
+func Test_Visibility(t *testing.T) {
+	mesh := LoadJSONMesh("ushape.json")
+	query := CreateQuery(mesh, PATH_MAX_NODE)
+	base := mesh.GetPolyRefBase(mesh.GetTileAt(0, 0, 0))
+	filter := detour.DtAllocDtQueryFilter()
+
+	// From the middle of the left arm, the bottom left corner is in view,
+	// the right arm is hidden by the gap between the arms.
+	center := []float32{5, 0, 15}
+	startRef := base | 3
+	var verts [256 * 3]float32
+	var vertCount int
+	status := query.FindVisibilityPolygon(startRef, center, 100, filter, verts[:], &vertCount, 256)
+	if status != detour.DT_SUCCESS || vertCount < 4 {
+		t.Fatalf("FindVisibilityPolygon: status 0x%x, %d vertices", status, vertCount)
+	}
+	var area float32
+	for i, j := 0, vertCount-1; i < vertCount; j, i = i, i+1 {
+		area += detour.DtTriArea2D(center, verts[j*3:], verts[i*3:])
+	}
+	if area <= 0 {
+		t.Fatalf("visibility polygon area %v, not in the winding of the mesh", area)
+	}
+	targets := []float32{
+		12, 0, 2,
+		25, 0, 25,
+		5, 0, 28,
+		25, 0, 5,
+	}
+	want := []bool{true, false, true, false}
+	for i := range want {
+		if in := detour.DtPointInPolygon(targets[i*3:], verts[:], vertCount); in != want[i] {
+			t.Fatalf("target %v in the visibility polygon: %v", targets[i*3:i*3+3], in)
+		}
+	}
+
+	// The line of sight agrees, and targets out of range are not visible.
+	visible := make([]bool, len(want))
+	if status := query.CheckLineOfSight(startRef, center, targets, len(want), math.MaxFloat32, filter, visible); status != detour.DT_SUCCESS {
+		t.Fatalf("CheckLineOfSight: status 0x%x", status)
+	}
+	for i := range want {
+		if visible[i] != want[i] {
+			t.Fatalf("target %v visible: %v", targets[i*3:i*3+3], visible[i])
+		}
+	}
+	query.CheckLineOfSight(startRef, center, targets, len(want), 14, filter, visible)
+	if visible[0] || !visible[2] {
+		t.Fatalf("visible within 14: %v", visible)
+	}
+
+	// A small view is the circle, cut by the walls of the arm.
+	query.FindVisibilityPolygon(startRef, center, 2, filter, verts[:], &vertCount, 256)
+	if vertCount != detour.DT_VISIBILITY_ARC_SEGMENTS {
+		t.Fatalf("%d vertices for a view without walls", vertCount)
+	}
+	query.FindVisibilityPolygon(startRef, []float32{9, 0, 15}, 2, filter, verts[:], &vertCount, 256)
+	for i := 0; i < vertCount; i++ {
+		if verts[i*3] > 10+1e-3 {
+			t.Fatalf("vertex %v beyond the wall", verts[i*3:i*3+3])
+		}
+	}
+
+	nav, err := navigation.New(mesh, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if points, err := nav.Visibility(navigation.Vec3{5, 0, 15}, 100); err != nil || len(points) < 4 {
+		t.Fatalf("Visibility: %d points, %v", len(points), err)
+	}
+	seen, err := nav.LineOfSight(navigation.Vec3{5, 0, 15}, []navigation.Vec3{{12, 0, 2}, {25, 0, 25}}, 0)
+	if err != nil || !seen[0] || seen[1] {
+		t.Fatalf("LineOfSight: %v, %v", seen, err)
+	}
+}